@@ -0,0 +1,35 @@
+// Package common holds the runtime shared by the tor and onionbalance agents:
+// a typed client and a watcher for the single object an agent manages.
+package common
+
+import (
+	log "github.com/sirupsen/logrus"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	torv1alpha2 "github.com/bugfest/tor-controller/apis/tor/v1alpha2"
+)
+
+// Scheme returns a scheme knowing the tor-controller types.
+func Scheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+
+	err := torv1alpha2.AddToScheme(scheme)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return scheme
+}
+
+// GetClient returns a client for the tor-controller types.
+func GetClient() client.Client {
+	controllerClient, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: Scheme()})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return controllerClient
+}
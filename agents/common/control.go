@@ -0,0 +1,150 @@
+package common
+
+import (
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/cretz/bine/control"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	controlDialTimeout = 5 * time.Second
+	controlRetryDelay  = 10 * time.Second
+)
+
+// DialControl connects to tor's control port and authenticates. Cookie and
+// null authentication are supported. A zero deadline keeps the connection
+// open until closed.
+func DialControl(network, address string, deadline time.Time) (*control.Conn, error) {
+	conn, err := net.DialTimeout(network, address, controlDialTimeout)
+	if err != nil {
+		return nil, errors.Wrap(err, "connecting to the control port")
+	}
+
+	err = conn.SetDeadline(deadline)
+	if err != nil {
+		conn.Close()
+
+		return nil, errors.Wrap(err, "setting deadline")
+	}
+
+	ctrl := control.NewConn(textproto.NewConn(conn))
+
+	err = ctrl.Authenticate("")
+	if err != nil {
+		ctrl.Close()
+
+		return nil, errors.Wrap(err, "authenticating")
+	}
+
+	return ctrl, nil
+}
+
+// uploadedDescriptors holds the onion addresses, without the .onion suffix,
+// whose descriptor tor uploaded since the control connection was opened.
+var uploadedDescriptors = struct {
+	sync.Mutex
+	addresses map[string]bool
+}{addresses: map[string]bool{}}
+
+// DescriptorUploaded reports whether tor uploaded the descriptor of the
+// onion service to a directory since it started, as seen by
+// WatchDescriptors.
+func DescriptorUploaded(onionAddress string) bool {
+	uploadedDescriptors.Lock()
+	defer uploadedDescriptors.Unlock()
+
+	return uploadedDescriptors.addresses[strings.TrimSuffix(onionAddress, ".onion")]
+}
+
+func setDescriptorUploaded(address string) {
+	uploadedDescriptors.Lock()
+	defer uploadedDescriptors.Unlock()
+
+	uploadedDescriptors.addresses[address] = true
+}
+
+func resetDescriptorsUploaded() {
+	uploadedDescriptors.Lock()
+	defer uploadedDescriptors.Unlock()
+
+	uploadedDescriptors.addresses = map[string]bool{}
+}
+
+// WatchDescriptors counts the onion service descriptor uploads reported by
+// tor until the context is cancelled, reconnecting when tor restarts.
+func WatchDescriptors(ctx context.Context, network, address string) {
+	for {
+		err := watchDescriptors(ctx, network, address)
+		if ctx.Err() != nil {
+			return
+		}
+
+		log.Debugf("watching descriptor events: %v", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(controlRetryDelay):
+		}
+	}
+}
+
+func watchDescriptors(ctx context.Context, network, address string) error {
+	ctrl, err := DialControl(network, address, time.Time{})
+	if err != nil {
+		return err
+	}
+	defer ctrl.Close()
+
+	events := make(chan control.Event)
+
+	err = ctrl.AddEventListener(events, control.EventCodeHSDesc)
+	if err != nil {
+		return errors.Wrap(err, "subscribing to HS_DESC events")
+	}
+
+	// The control connection is local, it is lost when tor stops: a new tor
+	// process uploads the descriptors again once bootstrapped
+	resetDescriptorsUploaded()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errCh := make(chan error, 1)
+
+	go func() {
+		errCh <- ctrl.HandleEvents(ctx)
+	}()
+
+	for {
+		select {
+		case err := <-errCh:
+			return errors.Wrap(err, "handling events")
+		case event := <-events:
+			desc, ok := event.(*control.HSDescEvent)
+			if !ok {
+				continue
+			}
+
+			// Fetches are reported as well, only uploads are counted
+			switch desc.Action {
+			case "UPLOADED":
+				descriptorEvents.WithLabelValues(desc.Action).Inc()
+				setDescriptorUploaded(desc.Address)
+			case "UPLOAD":
+				descriptorEvents.WithLabelValues(desc.Action).Inc()
+			case "FAILED":
+				if desc.Reason == "UPLOAD_REJECTED" {
+					descriptorEvents.WithLabelValues(desc.Action).Inc()
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package common
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bugfest/tor-controller/agents/common/controltest"
+)
+
+func TestWatchDescriptorsUploaded(t *testing.T) {
+	const address = "abcdefghijklmnopqrstuvwxyz234567abcdefghijklmnopqrstuvwx"
+
+	server := controltest.Start(t, map[string][]string{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go WatchDescriptors(ctx, server.Network, server.Address)
+
+	// Built and being uploaded isn't enough
+	server.Event("650 HS_DESC UPLOAD " + address + " UNKNOWN $AAAA descid HSDIR_INDEX=00")
+	server.Event("650 HS_DESC FAILED " + address + " NO_AUTH $AAAA descid REASON=UPLOAD_REJECTED")
+
+	time.Sleep(100 * time.Millisecond)
+
+	if DescriptorUploaded(address + ".onion") {
+		t.Fatal("DescriptorUploaded() = true before the upload succeeded")
+	}
+
+	server.Event("650 HS_DESC UPLOADED " + address + " UNKNOWN $BBBB")
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for !DescriptorUploaded(address + ".onion") {
+		if time.Now().After(deadline) {
+			t.Fatal("DescriptorUploaded() = false after the HS_DESC UPLOADED event")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if DescriptorUploaded("other.onion") {
+		t.Error("DescriptorUploaded() = true for another onion service")
+	}
+}
@@ -0,0 +1,147 @@
+// Package controltest serves a fake tor control port for tests.
+package controltest
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Server answers the control port commands with canned replies, accepting
+// null authentication.
+type Server struct {
+	// Network and Address to dial, e.g: unix and /tmp/.../control.sock
+	Network string
+	Address string
+
+	mu      sync.Mutex
+	replies map[string][]string
+	events  chan string
+}
+
+// Start serves the control port on a unix socket until the test ends.
+// Replies maps commands, e.g: "GETINFO status/bootstrap-phase", to the lines
+// sent back. Unknown commands get a 552 error.
+func Start(t *testing.T, replies map[string][]string) *Server {
+	t.Helper()
+
+	// Unix socket paths are limited in length, t.TempDir() may be too long
+	dir, err := os.MkdirTemp("", "tor")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &Server{
+		Network: "unix",
+		Address: filepath.Join(dir, "control.sock"),
+		replies: replies,
+		events:  make(chan string, 16),
+	}
+
+	listener, err := net.Listen(server.Network, server.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		listener.Close()
+		os.RemoveAll(dir)
+	})
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go server.handle(conn)
+		}
+	}()
+
+	return server
+}
+
+// SetReply changes the reply to a command.
+func (s *Server) SetReply(command string, lines ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.replies[command] = lines
+}
+
+// Event sends an asynchronous event, e.g: "650 HS_DESC UPLOADED ...", to a
+// connection that subscribed with SETEVENTS.
+func (s *Server) Event(line string) {
+	s.events <- line
+}
+
+func (s *Server) reply(command string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case command == "PROTOCOLINFO":
+		return []string{"250-PROTOCOLINFO 1", "250-AUTH METHODS=NULL", `250-VERSION Tor="0.4.8.9"`, "250 OK"}
+	case strings.HasPrefix(command, "AUTHENTICATE"), strings.HasPrefix(command, "SETEVENTS"):
+		return []string{"250 OK"}
+	}
+
+	lines, ok := s.replies[command]
+	if !ok {
+		return []string{`552 Unrecognized key "` + command + `"`}
+	}
+
+	return lines
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	var writeMu sync.Mutex
+
+	write := func(lines []string) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+
+		for _, line := range lines {
+			if _, err := conn.Write([]byte(line + "\r\n")); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	scanner := bufio.NewScanner(conn)
+
+	for scanner.Scan() {
+		command := strings.TrimSpace(scanner.Text())
+
+		if err := write(s.reply(command)); err != nil {
+			return
+		}
+
+		if strings.HasPrefix(command, "SETEVENTS") && command != "SETEVENTS" {
+			go func() {
+				for {
+					select {
+					case <-done:
+						return
+					case event := <-s.events:
+						if write([]string{event}) != nil {
+							return
+						}
+					}
+				}
+			}()
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package common
+
+import (
+	"context"
+
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+)
+
+// WatchDirs calls onChange whenever the content of one of the directories
+// changes, until the context is cancelled. Kubernetes updates mounted
+// secrets by swapping a symlink in the mount directory, which shows up as
+// events on the directory itself. Missing directories are skipped.
+func WatchDirs(ctx context.Context, onChange func(), dirs ...string) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Errorf("Creating file watcher failed with %v", err)
+
+		return
+	}
+	defer watcher.Close()
+
+	for _, dir := range dirs {
+		err := watcher.Add(dir)
+		if err != nil {
+			log.Debugf("Not watching %s: %v", dir, err)
+
+			continue
+		}
+
+		log.Infof("Watching %s", dir)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+
+			log.Debugf("Got file event %s", event)
+			onChange()
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+
+			log.Errorf("File watcher failed with %v", err)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package common
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// MetricsPort serves the agent metrics. Tor's own metrics are on 9035.
+	MetricsPort = 9036
+
+	metricsNamespace = "tor_controller_agent"
+	metricsTimeout   = 5 * time.Second
+)
+
+var (
+	configReloads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "config_reloads_total",
+		Help:      "Number of config reloads, by result.",
+	}, []string{"result"})
+
+	processRestarts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "process_restarts_total",
+		Help:      "Number of times a child process was restarted.",
+	}, []string{"process"})
+
+	lastSuccessfulSync = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "last_successful_sync_timestamp_seconds",
+		Help:      "Unix time of the last successful sync of the watched object.",
+	})
+
+	descriptorEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "descriptor_upload_events_total",
+		Help:      "Number of onion service descriptor upload events reported by tor, by action.",
+	}, []string{"action"})
+)
+
+// ReloadSucceeded records a config reload.
+func ReloadSucceeded() {
+	configReloads.WithLabelValues("success").Inc()
+}
+
+// ReloadFailed records a config reload that failed.
+func ReloadFailed() {
+	configReloads.WithLabelValues("failure").Inc()
+}
+
+// NewGauge registers a gauge with the agent metrics.
+func NewGauge(name, help string) prometheus.Gauge {
+	return promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      name,
+		Help:      help,
+	})
+}
+
+// ServeMetrics exposes the agent metrics on MetricsPort until the context is
+// cancelled.
+func ServeMetrics(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	server := &http.Server{
+		Addr:              net.JoinHostPort("", strconv.Itoa(MetricsPort)),
+		Handler:           mux,
+		ReadHeaderTimeout: metricsTimeout,
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), metricsTimeout)
+		defer cancel()
+
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	log.Infof("Serving metrics on %s", server.Addr)
+
+	err := server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return errors.Wrap(err, "serving metrics")
+	}
+
+	return nil
+}
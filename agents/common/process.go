@@ -0,0 +1,222 @@
+package common
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	restartMinBackoff = 1 * time.Second
+	restartMaxBackoff = 1 * time.Minute
+
+	// A process running this long is considered healthy, the backoff is
+	// reset when it exits
+	restartResetAfter = 2 * time.Minute
+
+	// StopTimeout is how long a process gets to shut down cleanly before it
+	// is killed. It fits in the default pod termination grace period.
+	StopTimeout = 20 * time.Second
+)
+
+// Process keeps a command running, restarting it with an exponential backoff
+// when it exits. When the context is cancelled, the process gets SIGINT so it
+// can shut down cleanly, and is killed after StopTimeout.
+type Process struct {
+	mu      sync.Mutex
+	cmd     *exec.Cmd
+	done    chan struct{}
+	restart bool
+}
+
+// Start runs the command until ctx is cancelled. Calling it again while
+// running is a no-op.
+func (p *Process) Start(ctx context.Context, name string, args ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.done != nil {
+		return
+	}
+
+	p.done = make(chan struct{})
+
+	go p.run(ctx, name, args)
+}
+
+func (p *Process) run(ctx context.Context, name string, args []string) {
+	defer close(p.done)
+
+	var delays backoff
+
+	for {
+		log.Infof("starting %s...", name)
+
+		started := time.Now()
+
+		err := p.runOnce(ctx, name, args)
+		if err != nil {
+			log.Errorf("%s: %v", name, err)
+		}
+
+		if ctx.Err() != nil {
+			log.Infof("%s stopped", name)
+
+			return
+		}
+
+		processRestarts.WithLabelValues(name).Inc()
+
+		p.mu.Lock()
+		restart := p.restart
+		p.restart = false
+		p.mu.Unlock()
+
+		if restart {
+			delays.reset()
+
+			continue
+		}
+
+		delay := delays.next(time.Since(started))
+		log.Infof("restarting %s in %s", name, delay)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// backoff computes the delays between the restarts of a process, doubling
+// from restartMinBackoff up to restartMaxBackoff.
+type backoff struct {
+	delay time.Duration
+}
+
+// next returns the delay before restarting a process that exited after
+// running for the given time. A process that ran long enough was healthy,
+// the backoff starts over.
+func (b *backoff) next(ran time.Duration) time.Duration {
+	if b.delay == 0 || ran > restartResetAfter {
+		b.delay = restartMinBackoff
+	}
+
+	delay := b.delay
+
+	b.delay *= 2
+	if b.delay > restartMaxBackoff {
+		b.delay = restartMaxBackoff
+	}
+
+	return delay
+}
+
+// reset starts the backoff over, after a requested restart.
+func (b *backoff) reset() {
+	b.delay = 0
+}
+
+func (p *Process) runOnce(ctx context.Context, name string, args []string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Start()
+	if err != nil {
+		return errors.Wrap(err, "starting")
+	}
+
+	p.mu.Lock()
+	p.cmd = cmd
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		p.cmd = nil
+		p.mu.Unlock()
+	}()
+
+	exited := make(chan error, 1)
+
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-exited:
+		return errors.Wrap(err, "exited")
+	case <-ctx.Done():
+	}
+
+	log.Infof("stopping %s...", name)
+
+	err = cmd.Process.Signal(syscall.SIGINT)
+	if err != nil {
+		log.Errorf("error sending SIGINT to %s: %v", name, err)
+	}
+
+	select {
+	case err := <-exited:
+		return errors.Wrap(err, "stopped")
+	case <-time.After(StopTimeout):
+		log.Warnf("%s did not stop in %s, killing it", name, StopTimeout)
+
+		_ = cmd.Process.Kill()
+
+		return errors.Wrap(<-exited, "killed")
+	}
+}
+
+// Running reports whether the process is currently running.
+func (p *Process) Running() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.cmd != nil
+}
+
+// Signal sends sig to the running process.
+func (p *Process) Signal(sig os.Signal) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cmd == nil {
+		return errors.New("process is not running")
+	}
+
+	return errors.Wrap(p.cmd.Process.Signal(sig), "sending signal")
+}
+
+// Restart stops the running process with sig and starts it again right away.
+func (p *Process) Restart(sig os.Signal) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cmd == nil {
+		return errors.New("process is not running")
+	}
+
+	p.restart = true
+
+	return errors.Wrap(p.cmd.Process.Signal(sig), "sending signal")
+}
+
+// Wait blocks until the process stopped for good, after the context was
+// cancelled.
+func (p *Process) Wait() {
+	p.mu.Lock()
+	done := p.done
+	p.mu.Unlock()
+
+	if done != nil {
+		<-done
+	}
+}
@@ -0,0 +1,153 @@
+package common
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	var delays backoff
+
+	// Crashing right away doubles the delay up to the maximum
+	want := []time.Duration{
+		1 * time.Second,
+		2 * time.Second,
+		4 * time.Second,
+		8 * time.Second,
+		16 * time.Second,
+		32 * time.Second,
+		time.Minute,
+		time.Minute,
+	}
+
+	for i, delay := range want {
+		if got := delays.next(time.Second); got != delay {
+			t.Errorf("restart %d delay = %v, want %v", i, got, delay)
+		}
+	}
+
+	// A process that ran long enough starts over
+	if got := delays.next(restartResetAfter + time.Second); got != restartMinBackoff {
+		t.Errorf("delay after a healthy run = %v, want %v", got, restartMinBackoff)
+	}
+
+	if got := delays.next(time.Second); got != 2*restartMinBackoff {
+		t.Errorf("delay = %v, want %v", got, 2*restartMinBackoff)
+	}
+
+	delays.reset()
+
+	if got := delays.next(time.Second); got != restartMinBackoff {
+		t.Errorf("delay after a reset = %v, want %v", got, restartMinBackoff)
+	}
+}
+
+// countRuns returns how many times the script started, each run appending a
+// line to the file.
+func countRuns(t *testing.T, name string) int {
+	t.Helper()
+
+	data, err := os.ReadFile(name)
+	if os.IsNotExist(err) {
+		return 0
+	} else if err != nil {
+		t.Fatal(err)
+	}
+
+	return strings.Count(string(data), "\n")
+}
+
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestProcessRestartsWithBackoff(t *testing.T) {
+	runs := filepath.Join(t.TempDir(), "runs")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var process Process
+
+	process.Start(ctx, "sh", "-c", "echo >> "+runs+"; exit 1")
+
+	waitFor(t, "the first run", func() bool { return countRuns(t, runs) == 1 })
+
+	// Restarted after restartMinBackoff, not right away
+	time.Sleep(restartMinBackoff / 2)
+
+	if got := countRuns(t, runs); got != 1 {
+		t.Errorf("ran %d times before the backoff elapsed, want 1", got)
+	}
+
+	waitFor(t, "the restart", func() bool { return countRuns(t, runs) == 2 })
+
+	cancel()
+	process.Wait()
+}
+
+func TestProcessRestart(t *testing.T) {
+	runs := filepath.Join(t.TempDir(), "runs")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var process Process
+
+	process.Start(ctx, "sh", "-c", "echo >> "+runs+"; exec sleep 60")
+
+	waitFor(t, "the first run", func() bool { return process.Running() && countRuns(t, runs) == 1 })
+
+	err := process.Restart(syscall.SIGTERM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Requested restarts don't back off
+	start := time.Now()
+
+	waitFor(t, "the restart", func() bool { return process.Running() && countRuns(t, runs) == 2 })
+
+	if elapsed := time.Since(start); elapsed >= restartMinBackoff {
+		t.Errorf("restarted after %v, want it right away", elapsed)
+	}
+
+	// Cancelling stops the process for good
+	cancel()
+
+	done := make(chan struct{})
+
+	go func() {
+		process.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(StopTimeout / 2):
+		t.Fatal("process didn't stop on SIGINT")
+	}
+
+	if process.Running() {
+		t.Error("process still running after it stopped")
+	}
+
+	if err := process.Signal(syscall.SIGHUP); err == nil {
+		t.Error("Signal() = nil on a stopped process, want an error")
+	}
+}
@@ -0,0 +1,37 @@
+package common
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// SignalContext returns a context cancelled on SIGINT or SIGTERM, which
+// starts a graceful shutdown. A second one exits right away. onHangup is
+// called on every SIGHUP.
+func SignalContext(onHangup func()) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	ch := make(chan os.Signal, 2)
+	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+
+	go func() {
+		for sig := range ch {
+			log.Infof("received %s", sig)
+
+			switch {
+			case sig == syscall.SIGHUP:
+				onHangup()
+			case ctx.Err() != nil:
+				os.Exit(1)
+			default:
+				cancel()
+			}
+		}
+	}()
+
+	return ctx
+}
@@ -0,0 +1,210 @@
+package common
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	log "github.com/sirupsen/logrus"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// Events are delayed a bit so bursts of updates are handled once
+	eventDelay = 2 * time.Second
+
+	retryBaseDelay = 3 * time.Second
+	retryMaxDelay  = 5 * time.Minute
+	maxRetries     = 5
+)
+
+// SyncFunc is called with the latest version of the watched object.
+type SyncFunc func(ctx context.Context, obj client.Object) error
+
+// Watcher watches a single object and syncs it whenever it changes. Agents
+// run one replica per managed object, so there's no leader election.
+type Watcher struct {
+	// Object is the type of the watched object, e.g: &v1alpha2.OnionService{}
+	Object client.Object
+
+	Namespace string
+	Name      string
+
+	Sync SyncFunc
+
+	mu    sync.Mutex
+	queue workqueue.RateLimitingInterface
+}
+
+// Run watches the object until the context is cancelled.
+func (w *Watcher) Run(ctx context.Context) error {
+	objectCache, err := cache.New(ctrl.GetConfigOrDie(), cache.Options{
+		Scheme:    Scheme(),
+		Namespace: w.Namespace,
+		SelectorsByObject: cache.SelectorsByObject{
+			w.Object: {Field: fields.OneTermEqualSelector("metadata.name", w.Name)},
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, "creating cache")
+	}
+
+	informer, err := objectCache.GetInformer(ctx, w.Object)
+	if err != nil {
+		return errors.Wrap(err, "creating informer")
+	}
+
+	queue := workqueue.NewRateLimitingQueue(retryRateLimiter())
+	defer queue.ShutDown()
+
+	w.mu.Lock()
+	w.queue = queue
+	w.mu.Unlock()
+
+	key := w.key()
+	enqueue := func(event string) {
+		log.Infof("%s %s", event, key)
+		queue.AddAfter(key, eventDelay)
+	}
+
+	informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { enqueue("Added") },
+		UpdateFunc: func(interface{}, interface{}) { enqueue("Updated") },
+		DeleteFunc: func(interface{}) { enqueue("Deleted") },
+	})
+
+	go func() {
+		err := objectCache.Start(ctx)
+		if err != nil {
+			log.Errorf("Cache stopped with %v", err)
+		}
+	}()
+
+	if !objectCache.WaitForCacheSync(ctx) {
+		return errors.New("timed out waiting for caches to sync")
+	}
+
+	go func() {
+		<-ctx.Done()
+		log.Info("Stopping watcher")
+		queue.ShutDown()
+	}()
+
+	log.Infof("Watching %s", key)
+
+	for w.processNextItem(ctx, queue, objectCache) {
+	}
+
+	return nil
+}
+
+// Resync syncs the object again, even if it didn't change.
+func (w *Watcher) Resync() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// Before Run the first sync is yet to come
+	if w.queue != nil {
+		log.Infof("Resyncing %s", w.key())
+		w.queue.Add(w.key())
+	}
+}
+
+// ResyncAfter syncs the object again once the delay elapsed, for state that
+// isn't reported through the API server.
+func (w *Watcher) ResyncAfter(delay time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.queue != nil {
+		w.queue.AddAfter(w.key(), delay)
+	}
+}
+
+func (w *Watcher) key() types.NamespacedName {
+	return types.NamespacedName{Namespace: w.Namespace, Name: w.Name}
+}
+
+func (w *Watcher) processNextItem(ctx context.Context, queue workqueue.RateLimitingInterface, reader client.Reader) bool {
+	item, quit := queue.Get()
+	if quit {
+		return false
+	}
+
+	defer queue.Done(item)
+
+	key, ok := item.(types.NamespacedName)
+	if !ok {
+		queue.Forget(item)
+
+		return true
+	}
+
+	obj, ok := w.Object.DeepCopyObject().(client.Object)
+	if !ok {
+		log.Errorf("%T is not a client.Object", w.Object)
+
+		return false
+	}
+
+	err := reader.Get(ctx, key, obj)
+
+	switch {
+	case apierrors.IsNotFound(err):
+		log.Warnf("%s does not exist anymore", key)
+
+		err = nil
+	case err != nil:
+		err = errors.Wrapf(err, "fetching %s", key)
+	default:
+		err = w.Sync(ctx, obj)
+		if err == nil {
+			lastSuccessfulSync.SetToCurrentTime()
+		}
+	}
+
+	handleErr(queue, err, key)
+
+	return true
+}
+
+// retryRateLimiter backs off exponentially from retryBaseDelay to
+// retryMaxDelay between the retries of failed syncs.
+func retryRateLimiter() workqueue.RateLimiter {
+	return workqueue.NewItemExponentialFailureRateLimiter(retryBaseDelay, retryMaxDelay)
+}
+
+// handleErr checks if an error happened and makes sure we will retry later,
+// backing off exponentially.
+func handleErr(queue workqueue.RateLimitingInterface, err error, key types.NamespacedName) {
+	if err == nil {
+		queue.Forget(key)
+
+		return
+	}
+
+	// Retry a few times if something goes wrong. After that, stop trying
+	// until the object changes again.
+	if queue.NumRequeues(key) < maxRetries {
+		log.Errorf("Error syncing %s: %v", key, err)
+
+		queue.AddRateLimited(key)
+
+		return
+	}
+
+	queue.Forget(key)
+	// Report to an external entity that, even after several retries, we could not successfully process this key
+	runtime.HandleError(err)
+	log.Infof("Dropping %s out of the queue: %v", key, err)
+}
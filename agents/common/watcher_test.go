@@ -0,0 +1,142 @@
+package common
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	torv1alpha2 "github.com/bugfest/tor-controller/apis/tor/v1alpha2"
+)
+
+func TestRetryRateLimiter(t *testing.T) {
+	limiter := retryRateLimiter()
+	key := types.NamespacedName{Namespace: "default", Name: "example"}
+
+	want := []time.Duration{
+		retryBaseDelay,
+		2 * retryBaseDelay,
+		4 * retryBaseDelay,
+		8 * retryBaseDelay,
+	}
+
+	for i, delay := range want {
+		if got := limiter.When(key); got != delay {
+			t.Errorf("retry %d delay = %v, want %v", i, got, delay)
+		}
+	}
+
+	for i := 0; i < 20; i++ {
+		limiter.When(key)
+	}
+
+	if got := limiter.When(key); got != retryMaxDelay {
+		t.Errorf("delay = %v, want it capped to %v", got, retryMaxDelay)
+	}
+
+	limiter.Forget(key)
+
+	if got := limiter.When(key); got != retryBaseDelay {
+		t.Errorf("delay after a successful sync = %v, want %v", got, retryBaseDelay)
+	}
+}
+
+func TestWatcherRetries(t *testing.T) {
+	onionService := &torv1alpha2.OnionService{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "example"},
+	}
+	key := types.NamespacedName{Namespace: onionService.Namespace, Name: onionService.Name}
+
+	tests := []struct {
+		name    string
+		objects []client.Object
+		// results of the successive syncs
+		results   []error
+		wantSyncs int
+	}{
+		{
+			name:      "sync succeeds",
+			objects:   []client.Object{onionService},
+			results:   []error{nil},
+			wantSyncs: 1,
+		},
+		{
+			name:      "sync succeeds after a failure",
+			objects:   []client.Object{onionService},
+			results:   []error{errors.New("tor not ready"), nil},
+			wantSyncs: 2,
+		},
+		{
+			name:    "sync keeps failing",
+			objects: []client.Object{onionService},
+			results: []error{
+				errors.New("1"), errors.New("2"), errors.New("3"),
+				errors.New("4"), errors.New("5"), errors.New("6"),
+			},
+			wantSyncs: maxRetries + 1,
+		},
+		{
+			name:      "object deleted",
+			wantSyncs: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			reader := fake.NewClientBuilder().WithScheme(Scheme()).WithObjects(tt.objects...).Build()
+
+			syncs := 0
+			watcher := &Watcher{
+				Object:    &torv1alpha2.OnionService{},
+				Namespace: key.Namespace,
+				Name:      key.Name,
+				Sync: func(ctx context.Context, obj client.Object) error {
+					syncs++
+
+					if obj.GetName() != key.Name {
+						t.Errorf("synced %s, want %s", obj.GetName(), key.Name)
+					}
+
+					if syncs > len(tt.results) {
+						t.Fatalf("unexpected sync %d", syncs)
+					}
+
+					return tt.results[syncs-1]
+				},
+			}
+
+			queue := workqueue.NewRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(time.Millisecond, time.Millisecond))
+			defer queue.ShutDown()
+
+			queue.Add(key)
+
+			// Process items until nothing is requeued anymore
+			for {
+				if !watcher.processNextItem(context.Background(), queue, reader) {
+					t.Fatal("queue shut down")
+				}
+
+				time.Sleep(10 * time.Millisecond)
+
+				if queue.Len() == 0 {
+					break
+				}
+			}
+
+			if syncs != tt.wantSyncs {
+				t.Errorf("synced %d times, want %d", syncs, tt.wantSyncs)
+			}
+
+			if got := queue.NumRequeues(key); got != 0 {
+				t.Errorf("key left with %d requeues, want it forgotten", got)
+			}
+		})
+	}
+}
@@ -2,17 +2,16 @@ package onionbalancedaemon
 
 import (
 	"context"
-	"os"
-	"os/exec"
 	"syscall"
-	"time"
 
 	log "github.com/sirupsen/logrus"
+
+	common "github.com/bugfest/tor-controller/agents/common"
 )
 
 type OnionBalance struct {
-	cmd *exec.Cmd
-	ctx context.Context
+	process common.Process
+	ctx     context.Context
 }
 
 func (t *OnionBalance) SetContext(ctx context.Context) {
@@ -20,39 +19,18 @@ func (t *OnionBalance) SetContext(ctx context.Context) {
 }
 
 func (t *OnionBalance) Start() {
-	go func() {
-		for {
-			log.Println("starting onionbalance...")
-
-			t.cmd = exec.CommandContext(t.ctx,
-				"onionbalance",
-				"--config", "/run/onionbalance/config.yaml",
-				// "--verbosity", "debug",
-				"--ip", "127.0.0.1",
-				"--port", "9051",
-				"--hs-version", "v3",
-			)
-			t.cmd.Stdout = os.Stdout
-			t.cmd.Stderr = os.Stderr
-
-			err := t.cmd.Start()
-			if err != nil {
-				log.Print("error starting onionbalance: ", err)
-			}
-
-			err = t.cmd.Wait()
-			if err != nil {
-				log.Print("error running onionbalance: ", err)
-			}
-
-			//nolint:gomnd // just seconds
-			time.Sleep(time.Second * 3)
-		}
-	}()
+	t.process.Start(t.ctx,
+		"onionbalance",
+		"--config", "/run/onionbalance/config.yaml",
+		// "--verbosity", "debug",
+		"--ip", "127.0.0.1",
+		"--port", "9051",
+		"--hs-version", "v3",
+	)
 }
 
 func (t *OnionBalance) IsRunning() bool {
-	return t.cmd != nil && (t.cmd.ProcessState == nil || !t.cmd.ProcessState.Exited())
+	return t.process.Running()
 }
 
 func (t *OnionBalance) EnsureRunning() {
@@ -66,14 +44,10 @@ func (t *OnionBalance) Reload() {
 	log.Println("reloading onionbalance...")
 
 	if t.IsRunning() {
+		// onionbalance doesn't reload its config, restart it
 		log.Println("stopping existing onionbalance...")
 
-		err := t.cmd.Process.Signal(syscall.SIGHUP)
-		if err != nil {
-			log.Println()
-		}
-
-		err = t.cmd.Wait()
+		err := t.process.Restart(syscall.SIGHUP)
 		if err != nil {
 			log.Println("error stopping onionbalance: ", err)
 		}
@@ -81,3 +55,8 @@ func (t *OnionBalance) Reload() {
 
 	t.Start()
 }
+
+// Wait blocks until onionbalance stopped, once the context is cancelled.
+func (t *OnionBalance) Wait() {
+	t.process.Wait()
+}
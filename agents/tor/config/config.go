@@ -2,13 +2,19 @@ package config
 
 import (
 	"bytes"
+	"net"
+	"strconv"
 	"text/template"
 
+	networkingv1 "k8s.io/api/networking/v1"
+
+	router "github.com/bugfest/tor-controller/agents/tor/router"
 	v1alpha2 "github.com/bugfest/tor-controller/apis/tor/v1alpha2"
 	"github.com/cockroachdb/errors"
 )
 
 const configFormat = `
+DataDirectory /var/lib/tor/data
 SocksPort {{ .SocksPort }}
 ControlPort {{ .ControlPort }}
 MetricsPort {{ .MetricsPort }}
@@ -19,7 +25,17 @@ HiddenServiceOnionbalanceInstance 1
 {{ end }}
 HiddenServiceVersion {{ .Version }}
 {{ range .Ports }}
-HiddenServicePort {{ .PublicPort }} {{ .ServiceClusterIP }}:{{ .ServicePort }}
+HiddenServicePort {{ .PublicPort }} {{ if .UnixSocket }}unix:{{ .UnixSocket }}{{ else }}{{ .ServiceClusterIP }}:{{ .ServicePort }}{{ end }}
+{{ end }}
+{{ if .VanguardsLiteEnabled }}
+VanguardsLiteEnabled 1
+{{ end }}
+{{ if .ControlDir }}
+# Control socket used by the health checks and the vanguards add-on
+ControlSocket unix:{{ .ControlDir }}/control.sock GroupWritable RelaxDirModeCheck
+CookieAuthentication 1
+CookieAuthFile {{ .ControlDir }}/control_auth_cookie
+CookieAuthFileGroupReadable 1
 {{ end }}
 
 {{ if .ExtraConfig }}
@@ -35,6 +51,10 @@ MasterOnionAddress {{.MasterOnionAddress}}
 {{ end }}
 `
 
+// ClusterDomain is the DNS domain of the cluster, used to render the
+// backend Service hosts.
+var ClusterDomain = "cluster.local"
+
 var (
 	configTemplate   = template.Must(template.New("config").Parse(configFormat))
 	oBconfigTemplate = template.Must(template.New("config").Parse(oBconfigFormat))
@@ -53,24 +73,52 @@ type TorConfig struct {
 	MasterOnionAddress                string
 	HiddenServiceOnionbalanceInstance bool
 	ExtraConfig                       string
+	VanguardsLiteEnabled              bool
+	ControlDir                        string
 }
 
 type portTuple struct {
 	ServicePort      int32
 	PublicPort       int32
 	ServiceClusterIP string
+	UnixSocket       string
 }
 
 func OnionServiceInputData(onion *v1alpha2.OnionService) TorConfig {
 	ports := []portTuple{}
 
-	for _, rule := range onion.Spec.Rules {
-		port := portTuple{
-			ServicePort:      rule.Backend.Service.Port.Number,
-			PublicPort:       rule.Port.Number,
-			ServiceClusterIP: rule.Backend.Service.Name,
+	for i, rule := range onion.Spec.Rules {
+		// Served by the HTTP router of the agent
+		if len(rule.HTTP) > 0 {
+			ports = append(ports, portTuple{
+				PublicPort: rule.Port.Number,
+				UnixSocket: router.SocketPath(rule.Port.Number),
+			})
+
+			continue
+		}
+
+		if rule.Backend.Unix != nil {
+			ports = append(ports, portTuple{
+				PublicPort: rule.Port.Number,
+				UnixSocket: rule.Backend.UnixSocketPath(),
+			})
+
+			continue
 		}
-		ports = append(ports, port)
+
+		// Service backends are rendered once the controller resolved them,
+		// which checks they can be referenced
+		target := resolvedTarget(onion, i)
+		if target == nil || target.Host == "" || target.Port == 0 {
+			continue
+		}
+
+		ports = append(ports, portTuple{
+			ServicePort:      target.Port,
+			PublicPort:       rule.Port.Number,
+			ServiceClusterIP: serviceHost(target.Host),
+		})
 	}
 
 	return TorConfig{
@@ -86,9 +134,67 @@ func OnionServiceInputData(onion *v1alpha2.OnionService) TorConfig {
 		MasterOnionAddress:                onion.Spec.MasterOnionAddress,
 		HiddenServiceOnionbalanceInstance: onion.Spec.MasterOnionAddress != "",
 		ExtraConfig:                       onion.Spec.ExtraConfig,
+		VanguardsLiteEnabled:              onion.Spec.Vanguards.LiteEnabled(),
+		ControlDir:                        v1alpha2.TorControlDir,
 	}
 }
 
+// HTTPRoutes returns the routing tables of the HTTP rules, by router socket.
+func HTTPRoutes(onion *v1alpha2.OnionService) map[string][]router.Route {
+	tables := map[string][]router.Route{}
+
+	for i, rule := range onion.Spec.Rules {
+		if len(rule.HTTP) == 0 {
+			continue
+		}
+
+		// The controller resolves the backends of the routes
+		var targets []v1alpha2.OnionServiceRouteTarget
+		if target := resolvedTarget(onion, i); target != nil {
+			targets = target.Routes
+		}
+
+		routes := []router.Route{}
+
+		for j, route := range rule.HTTP {
+			if j >= len(targets) || targets[j].Host == "" || targets[j].Port == 0 {
+				continue
+			}
+
+			routePath := route.Path
+			if routePath == "" {
+				routePath = "/"
+			}
+
+			routes = append(routes, router.Route{
+				Host:   route.Host,
+				Path:   routePath,
+				Exact:  route.PathType == networkingv1.PathTypeExact,
+				Target: net.JoinHostPort(serviceHost(targets[j].Host), strconv.Itoa(int(targets[j].Port))),
+			})
+		}
+
+		tables[router.SocketPath(rule.Port.Number)] = routes
+	}
+
+	return tables
+}
+
+// resolvedTarget returns the target the controller resolved for the rule at
+// index i, or nil when it isn't resolved yet.
+func resolvedTarget(onion *v1alpha2.OnionService, i int) *v1alpha2.OnionServiceTarget {
+	if i >= len(onion.Status.Targets) || onion.Status.Targets[i].PublicPort != onion.Spec.Rules[i].Port.Number {
+		return nil
+	}
+
+	return &onion.Status.Targets[i]
+}
+
+// serviceHost qualifies a backend Service host with the cluster domain.
+func serviceHost(host string) string {
+	return host + "." + ClusterDomain
+}
+
 func TorConfigForService(onion *v1alpha2.OnionService) (string, error) {
 	s := OnionServiceInputData(onion)
 
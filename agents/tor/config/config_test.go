@@ -0,0 +1,121 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+
+	router "github.com/bugfest/tor-controller/agents/tor/router"
+	v1alpha2 "github.com/bugfest/tor-controller/apis/tor/v1alpha2"
+)
+
+func serviceBackend(name, namespace string, port int32) v1alpha2.OnionServiceBackend {
+	return v1alpha2.OnionServiceBackend{
+		IngressBackend: networkingv1.IngressBackend{
+			Service: &networkingv1.IngressServiceBackend{
+				Name: name,
+				Port: networkingv1.ServiceBackendPort{Number: port},
+			},
+		},
+		Namespace: namespace,
+	}
+}
+
+func testOnionService() *v1alpha2.OnionService {
+	onion := &v1alpha2.OnionService{}
+	onion.Name = "example"
+	onion.Namespace = "default"
+	onion.Spec.Rules = []v1alpha2.ServiceRule{
+		{
+			Port:    networkingv1.ServiceBackendPort{Number: 80},
+			Backend: serviceBackend("web", "", 8080),
+		},
+		{
+			// Not allowed by any TorReferenceGrant, so never resolved
+			Port:    networkingv1.ServiceBackendPort{Number: 5432},
+			Backend: serviceBackend("db", "other", 5432),
+		},
+		{
+			Port: networkingv1.ServiceBackendPort{Number: 443},
+			HTTP: []v1alpha2.OnionServiceHTTPRoute{
+				{Path: "/api", Backend: serviceBackend("api", "", 80)},
+				{Path: "/admin", Backend: serviceBackend("admin", "other", 80)},
+			},
+		},
+	}
+
+	return onion
+}
+
+func TestOnionServiceInputDataPorts(t *testing.T) {
+	tests := []struct {
+		name    string
+		targets []v1alpha2.OnionServiceTarget
+		want    []portTuple
+	}{
+		{
+			name: "not resolved",
+			want: []portTuple{
+				{PublicPort: 443, UnixSocket: router.SocketPath(443)},
+			},
+		},
+		{
+			name: "resolved",
+			targets: []v1alpha2.OnionServiceTarget{
+				{PublicPort: 80, Host: "web.default.svc", Port: 8080},
+				{PublicPort: 5432},
+				{PublicPort: 443},
+			},
+			want: []portTuple{
+				{PublicPort: 80, ServicePort: 8080, ServiceClusterIP: "web.default.svc.cluster.local"},
+				{PublicPort: 443, UnixSocket: router.SocketPath(443)},
+			},
+		},
+		{
+			name: "rules changed since the resolution",
+			targets: []v1alpha2.OnionServiceTarget{
+				{PublicPort: 81, Host: "web.default.svc", Port: 8080},
+			},
+			want: []portTuple{
+				{PublicPort: 443, UnixSocket: router.SocketPath(443)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			onion := testOnionService()
+			onion.Status.Targets = tt.targets
+
+			if got := OnionServiceInputData(onion).Ports; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Ports = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPRoutes(t *testing.T) {
+	onion := testOnionService()
+	onion.Status.Targets = []v1alpha2.OnionServiceTarget{
+		{PublicPort: 80, Host: "web.default.svc", Port: 8080},
+		{PublicPort: 5432},
+		{
+			PublicPort: 443,
+			Routes: []v1alpha2.OnionServiceRouteTarget{
+				{Host: "api.default.svc", Port: 8000},
+			},
+		},
+	}
+
+	want := map[string][]router.Route{
+		router.SocketPath(443): {
+			{Path: "/api", Target: "api.default.svc.cluster.local:8000"},
+		},
+	}
+
+	if got := HTTPRoutes(onion); !reflect.DeepEqual(got, want) {
+		t.Errorf("HTTPRoutes() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,231 @@
+// Package filesync keeps copies of mounted files in sync with their source.
+//
+// Tor requires strict permissions on its keys and directories, which can't
+// be set on the secret volumes mounted by kubernetes, so the agent keeps its
+// own copies. They are updated when the mounted secrets change.
+package filesync
+
+import (
+	"crypto/sha256"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// File mirrors Src into Dst. Dst is left untouched while Src doesn't exist,
+// as tor may generate it (e.g. the hostname of a given private key).
+type File struct {
+	Src  string
+	Dst  string
+	Mode os.FileMode
+}
+
+// Dir mirrors the files of Src accepted by Match into Dst. Files of Dst
+// without source are deleted.
+type Dir struct {
+	Src      string
+	Dst      string
+	Match    func(name string) bool
+	DirMode  os.FileMode
+	FileMode os.FileMode
+}
+
+// Syncer keeps a set of files and directories in sync.
+type Syncer struct {
+	Files []File
+	Dirs  []Dir
+}
+
+// Sync updates the destination files and reports whether anything changed.
+// It goes through every file even if some of them fail.
+func (s *Syncer) Sync() (bool, error) {
+	changed := false
+
+	var errs error
+
+	for _, file := range s.Files {
+		fileChanged, err := file.sync()
+		changed = changed || fileChanged
+		errs = errors.CombineErrors(errs, err)
+	}
+
+	for _, dir := range s.Dirs {
+		dirChanged, err := dir.sync()
+		changed = changed || dirChanged
+		errs = errors.CombineErrors(errs, err)
+	}
+
+	return changed, errs
+}
+
+func (f *File) sync() (bool, error) {
+	src, err := os.ReadFile(f.Src)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, errors.Wrapf(err, "reading %s", f.Src)
+	}
+
+	dst, err := os.ReadFile(f.Dst)
+	if err != nil && !os.IsNotExist(err) {
+		return false, errors.Wrapf(err, "reading %s", f.Dst)
+	}
+
+	if err == nil && sha256.Sum256(src) == sha256.Sum256(dst) {
+		// Same content, only fix the mode if needed
+		return false, chmod(f.Dst, f.Mode)
+	}
+
+	log.Infof("Updating %s from %s", f.Dst, f.Src)
+
+	err = WriteFileAtomic(f.Dst, src, f.Mode)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (d *Dir) sync() (bool, error) {
+	changed := false
+
+	_, err := os.Stat(d.Dst)
+	if os.IsNotExist(err) {
+		err = os.Mkdir(d.Dst, d.DirMode)
+		if err != nil {
+			return false, errors.Wrapf(err, "creating directory %s", d.Dst)
+		}
+
+		changed = true
+	} else if err != nil {
+		return false, errors.Wrapf(err, "checking directory %s", d.Dst)
+	}
+
+	// Mkdir is subject to umask
+	err = chmod(d.Dst, d.DirMode|os.ModeDir)
+	if err != nil {
+		return changed, err
+	}
+
+	sources := map[string]bool{}
+
+	entries, err := os.ReadDir(d.Src)
+	if err != nil && !os.IsNotExist(err) {
+		return changed, errors.Wrapf(err, "reading directory %s", d.Src)
+	}
+
+	var errs error
+
+	for _, entry := range entries {
+		// Secret volumes keep their data in hidden directories and link
+		// the files to them
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || !d.Match(entry.Name()) {
+			continue
+		}
+
+		sources[entry.Name()] = true
+
+		file := File{
+			Src:  path.Join(d.Src, entry.Name()),
+			Dst:  path.Join(d.Dst, entry.Name()),
+			Mode: d.FileMode,
+		}
+
+		fileChanged, err := file.sync()
+		changed = changed || fileChanged
+		errs = errors.CombineErrors(errs, err)
+	}
+
+	copies, err := os.ReadDir(d.Dst)
+	if err != nil {
+		return changed, errors.CombineErrors(errs, errors.Wrapf(err, "reading directory %s", d.Dst))
+	}
+
+	for _, entry := range copies {
+		if entry.IsDir() || sources[entry.Name()] {
+			continue
+		}
+
+		removed, err := remove(path.Join(d.Dst, entry.Name()))
+		changed = changed || removed
+		errs = errors.CombineErrors(errs, err)
+	}
+
+	return changed, errs
+}
+
+func remove(name string) (bool, error) {
+	err := os.Remove(name)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, errors.Wrapf(err, "removing %s", name)
+	}
+
+	log.Infof("Removed %s, its source is gone", name)
+
+	return true, nil
+}
+
+func chmod(name string, mode os.FileMode) error {
+	info, err := os.Stat(name)
+	if err != nil {
+		return errors.Wrapf(err, "checking %s", name)
+	}
+
+	if info.Mode() == mode {
+		return nil
+	}
+
+	return errors.Wrapf(os.Chmod(name, mode.Perm()), "setting mode of %s", name)
+}
+
+// WriteFileAtomic replaces name with data without ever exposing a partially
+// written file: data goes to a temporary file in the same directory, which is
+// synced and then renamed over name.
+func WriteFileAtomic(name string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(name)
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(name)+".*")
+	if err != nil {
+		return errors.Wrap(err, "creating temporary file")
+	}
+
+	// No-op once renamed
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(perm)
+	}
+
+	if err == nil {
+		err = tmp.Sync()
+	}
+
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return errors.Wrapf(err, "writing %s", tmp.Name())
+	}
+
+	err = os.Rename(tmp.Name(), name)
+	if err != nil {
+		return errors.Wrapf(err, "renaming %s", tmp.Name())
+	}
+
+	// Persist the rename itself
+	dirfd, err := os.Open(dir)
+	if err != nil {
+		return errors.Wrapf(err, "opening %s", dir)
+	}
+	defer dirfd.Close()
+
+	return errors.Wrapf(dirfd.Sync(), "syncing %s", dir)
+}
@@ -0,0 +1,196 @@
+package filesync
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, name, data string) {
+	t.Helper()
+
+	err := os.WriteFile(name, []byte(data), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeSecretFile lays out a file the way secret volumes do: the data lives
+// in a hidden directory and the file links to it.
+func writeSecretFile(t *testing.T, dir, name, data string) {
+	t.Helper()
+
+	dataDir := filepath.Join(dir, "..data")
+
+	err := os.MkdirAll(dataDir, 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, filepath.Join(dataDir, name), data)
+
+	err = os.Symlink(filepath.Join("..data", name), filepath.Join(dir, name))
+	if err != nil && !os.IsExist(err) {
+		t.Fatal(err)
+	}
+}
+
+func assertFile(t *testing.T, name, data string, mode os.FileMode) {
+	t.Helper()
+
+	info, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode() != mode {
+		t.Errorf("%s mode = %v, want %v", name, info.Mode(), mode)
+	}
+
+	if info.IsDir() {
+		return
+	}
+
+	got, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(got) != data {
+		t.Errorf("%s = %q, want %q", name, got, data)
+	}
+}
+
+func sync(t *testing.T, syncer *Syncer, wantChanged bool) {
+	t.Helper()
+
+	changed, err := syncer.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if changed != wantChanged {
+		t.Errorf("Sync() changed = %v, want %v", changed, wantChanged)
+	}
+}
+
+func TestSyncFile(t *testing.T) {
+	srcDir, dstDir := t.TempDir(), t.TempDir()
+
+	syncer := &Syncer{
+		Files: []File{
+			{Src: filepath.Join(srcDir, "key"), Dst: filepath.Join(dstDir, "key"), Mode: 0o600},
+		},
+	}
+
+	// Without source the copy is left to whoever generates it
+	writeFile(t, filepath.Join(dstDir, "key"), "generated")
+	sync(t, syncer, false)
+	assertFile(t, filepath.Join(dstDir, "key"), "generated", 0o644)
+
+	writeSecretFile(t, srcDir, "key", "secret")
+	sync(t, syncer, true)
+	assertFile(t, filepath.Join(dstDir, "key"), "secret", 0o600)
+
+	// Unchanged content is a no-op
+	sync(t, syncer, false)
+
+	// Only the mode is fixed
+	err := os.Chmod(filepath.Join(dstDir, "key"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sync(t, syncer, false)
+	assertFile(t, filepath.Join(dstDir, "key"), "secret", 0o600)
+
+	writeFile(t, filepath.Join(srcDir, "..data", "key"), "rotated")
+	sync(t, syncer, true)
+	assertFile(t, filepath.Join(dstDir, "key"), "rotated", 0o600)
+}
+
+func TestSyncDir(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := filepath.Join(t.TempDir(), "authorized_clients")
+
+	syncer := &Syncer{
+		Dirs: []Dir{
+			{
+				Src: srcDir,
+				Dst: dstDir,
+				Match: func(name string) bool {
+					return strings.HasSuffix(name, ".auth")
+				},
+				DirMode:  0o700,
+				FileMode: 0o600,
+			},
+		},
+	}
+
+	// The directory is created even without source
+	sync(t, syncer, true)
+	assertFile(t, dstDir, "", 0o700|os.ModeDir)
+
+	writeSecretFile(t, srcDir, "alice.auth", "descriptor:x25519:alice")
+	writeSecretFile(t, srcDir, "bob.auth", "descriptor:x25519:bob")
+	writeSecretFile(t, srcDir, "README", "not a client")
+	sync(t, syncer, true)
+	assertFile(t, filepath.Join(dstDir, "alice.auth"), "descriptor:x25519:alice", 0o600)
+	assertFile(t, filepath.Join(dstDir, "bob.auth"), "descriptor:x25519:bob", 0o600)
+
+	for _, name := range []string{"README", "..data"} {
+		if _, err := os.Lstat(filepath.Join(dstDir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s was copied", name)
+		}
+	}
+
+	// Unchanged content is a no-op
+	sync(t, syncer, false)
+
+	// Copies without source are deleted
+	err := os.Remove(filepath.Join(srcDir, "bob.auth"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sync(t, syncer, true)
+
+	if _, err := os.Stat(filepath.Join(dstDir, "bob.auth")); !os.IsNotExist(err) {
+		t.Errorf("bob.auth wasn't deleted: %v", err)
+	}
+
+	assertFile(t, filepath.Join(dstDir, "alice.auth"), "descriptor:x25519:alice", 0o600)
+
+	// The directory mode is fixed
+	err = os.Chmod(dstDir, 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sync(t, syncer, false)
+	assertFile(t, dstDir, "", 0o700|os.ModeDir)
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "torfile")
+
+	writeFile(t, name, "old")
+
+	err := WriteFileAtomic(name, []byte("new"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertFile(t, name, "new", 0o600)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 {
+		t.Errorf("temporary files left behind: %v", entries)
+	}
+}
@@ -0,0 +1,177 @@
+package forwarder
+
+import (
+	"context"
+	"flag"
+	"io"
+	"net"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/cockroachdb/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/proxy"
+)
+
+// Forwarder accepts TCP connections and forwards them to a remote address
+// (usually an onion service) through tor's SOCKS port.
+type Forwarder struct {
+	// Listen address, e.g: 0.0.0.0:80
+	Listen string
+
+	// Target address, e.g: abcdef...xyz.onion:80
+	Target string
+
+	Dialer proxy.ContextDialer
+}
+
+// Run listens until the context is cancelled.
+func (f *Forwarder) Run(ctx context.Context) error {
+	var listenConfig net.ListenConfig
+
+	listener, err := listenConfig.Listen(ctx, "tcp", f.Listen)
+	if err != nil {
+		return errors.Wrapf(err, "listening on %s", f.Listen)
+	}
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	log.Infof("forwarding %s to %s", f.Listen, f.Target)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return errors.Wrap(err, "accepting connection")
+		}
+
+		go f.handle(ctx, conn)
+	}
+}
+
+func (f *Forwarder) handle(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+
+	remote, err := f.Dialer.DialContext(ctx, "tcp", f.Target)
+	if err != nil {
+		log.Errorf("error connecting to %s: %v", f.Target, err)
+
+		return
+	}
+	defer remote.Close()
+
+	var wg sync.WaitGroup
+
+	//nolint:gomnd // both directions
+	wg.Add(2)
+
+	pipe := func(dst, src net.Conn) {
+		defer wg.Done()
+
+		_, err := io.Copy(dst, src)
+		if err != nil {
+			log.Debugf("error forwarding to %s: %v", f.Target, err)
+		}
+
+		// Propagate EOF to the other side
+		if tcpConn, ok := dst.(interface{ CloseWrite() error }); ok {
+			_ = tcpConn.CloseWrite()
+		}
+	}
+
+	go pipe(remote, conn)
+	go pipe(conn, remote)
+
+	wg.Wait()
+}
+
+type mappings []string
+
+func (m *mappings) String() string {
+	return strings.Join(*m, ",")
+}
+
+func (m *mappings) Set(value string) error {
+	*m = append(*m, value)
+
+	return nil
+}
+
+// Main runs the forwarders defined in args:
+//
+//	forward -socks 127.0.0.1:9050 -map 80=abcdef...xyz.onion:80 [-map ...]
+func Main(args []string) error {
+	var (
+		socksAddr string
+		maps      mappings
+	)
+
+	flags := flag.NewFlagSet("forward", flag.ExitOnError)
+	flags.StringVar(&socksAddr, "socks", "127.0.0.1:9050", "Tor SOCKS address.")
+	flags.Var(&maps, "map", "Forwarding rule: <listen port>=<target host>:<target port>. Can be repeated.")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return errors.Wrap(err, "error parsing flags")
+	}
+
+	if len(maps) == 0 {
+		return errors.New("at least one -map is required")
+	}
+
+	dialer, err := proxy.SOCKS5("tcp", socksAddr, nil, proxy.Direct)
+	if err != nil {
+		return errors.Wrap(err, "creating socks dialer")
+	}
+
+	contextDialer, ok := dialer.(proxy.ContextDialer)
+	if !ok {
+		return errors.New("socks dialer does not support contexts")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	forwarders := []*Forwarder{}
+
+	for _, m := range maps {
+		//nolint:gomnd // <listen port>=<target>
+		parts := strings.SplitN(m, "=", 2)
+		//nolint:gomnd // <listen port>=<target>
+		if len(parts) != 2 {
+			return errors.Newf("invalid -map %q", m)
+		}
+
+		forwarders = append(forwarders, &Forwarder{
+			Listen: net.JoinHostPort("0.0.0.0", parts[0]),
+			Target: parts[1],
+			Dialer: contextDialer,
+		})
+	}
+
+	errCh := make(chan error, len(forwarders))
+
+	for _, f := range forwarders {
+		go func(f *Forwarder) {
+			errCh <- f.Run(ctx)
+		}(f)
+	}
+
+	for range forwarders {
+		if err := <-errCh; err != nil {
+			stop()
+
+			return err
+		}
+	}
+
+	return nil
+}
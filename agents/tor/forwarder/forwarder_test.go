@@ -0,0 +1,192 @@
+package forwarder
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// dialerFunc dials through a function, standing in for tor's SOCKS port.
+type dialerFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+func (f dialerFunc) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return f(ctx, network, addr)
+}
+
+// echoListener accepts connections echoing back what they read.
+func echoListener(t *testing.T) net.Listener {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	return listener
+}
+
+// freeAddr returns a local address nothing is listening on.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	return listener.Addr().String()
+}
+
+func dialRetry(t *testing.T, addr string) net.Conn {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			return conn
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("forwarder not listening on %s: %v", addr, err)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestForwarderRun(t *testing.T) {
+	echo := echoListener(t)
+
+	dialed := make(chan string, 1)
+
+	forwarder := &Forwarder{
+		Listen: freeAddr(t),
+		Target: "abcdef.onion:80",
+		Dialer: dialerFunc(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dialed <- addr
+
+			var dialer net.Dialer
+
+			return dialer.DialContext(ctx, network, echo.Addr().String())
+		}),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	go func() {
+		done <- forwarder.Run(ctx)
+	}()
+
+	conn := dialRetry(t, forwarder.Listen)
+
+	_, err := conn.Write([]byte("ping"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Closing the write side must reach the target, which closes in turn
+	err = conn.(*net.TCPConn).CloseWrite()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn.Close()
+
+	if string(got) != "ping" {
+		t.Errorf("forwarded %q, want %q", got, "ping")
+	}
+
+	if addr := <-dialed; addr != forwarder.Target {
+		t.Errorf("dialed %q, want %q", addr, forwarder.Target)
+	}
+
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run() = %v after cancellation, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() didn't return after cancellation")
+	}
+}
+
+func TestForwarderDialError(t *testing.T) {
+	forwarder := &Forwarder{
+		Listen: freeAddr(t),
+		Target: "abcdef.onion:80",
+		Dialer: dialerFunc(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return nil, errors.New("general SOCKS server failure")
+		}),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		_ = forwarder.Run(ctx)
+	}()
+
+	conn := dialRetry(t, forwarder.Listen)
+	defer conn.Close()
+
+	err := conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The client connection is closed when the target is unreachable
+	_, err = conn.Read(make([]byte, 1))
+	if !errors.Is(err, io.EOF) {
+		t.Errorf("Read() = %v, want EOF", err)
+	}
+}
+
+func TestMainArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"no mappings", []string{"-socks", "127.0.0.1:9050"}},
+		{"mapping without target", []string{"-map", "80"}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Main(tt.args); err == nil {
+				t.Errorf("Main(%q) = nil, want an error", tt.args)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+// Package health serves the liveness and readiness endpoints of the tor
+// agent, checking tor through its control port.
+package health
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	log "github.com/sirupsen/logrus"
+
+	common "github.com/bugfest/tor-controller/agents/common"
+)
+
+const (
+	// Port serves /healthz and /readyz.
+	Port = 8081
+
+	checkTimeout    = 5 * time.Second
+	shutdownTimeout = 5 * time.Second
+)
+
+// Checker tells whether tor is alive and ready.
+type Checker struct {
+	// Network and Address of tor's control port, e.g: unix and
+	// /run/tor/control/control.sock. Cookie and null authentication are
+	// supported.
+	Network string
+	Address string
+
+	// Alive reports whether the tor process is running.
+	Alive func() bool
+
+	// OnionAddress returns the address of the onion service whose
+	// descriptor must be published to be ready. Tor only needs to be
+	// bootstrapped when nil.
+	OnionAddress func() (string, error)
+
+	// DescriptorUploaded reports whether tor uploaded the descriptor of the
+	// onion service, e.g: common.DescriptorUploaded.
+	DescriptorUploaded func(onionAddress string) bool
+}
+
+// Ready checks that tor bootstrapped and, for onion services, published the
+// service descriptor.
+func (c *Checker) Ready() error {
+	if !c.Alive() {
+		return errors.New("tor is not running")
+	}
+
+	ctrl, err := common.DialControl(c.Network, c.Address, time.Now().Add(checkTimeout))
+	if err != nil {
+		return err
+	}
+	defer ctrl.Close()
+
+	info, err := ctrl.GetInfo("status/bootstrap-phase")
+	if err != nil {
+		return errors.Wrap(err, "getting bootstrap phase")
+	}
+
+	if len(info) == 0 || !strings.Contains(info[0].Val, "PROGRESS=100") {
+		return errors.New("tor is bootstrapping")
+	}
+
+	if c.OnionAddress == nil {
+		return nil
+	}
+
+	onionAddress, err := c.OnionAddress()
+	if err != nil {
+		return errors.Wrap(err, "getting onion address")
+	}
+
+	// Tor builds the descriptor before uploading it, only the HS_DESC
+	// UPLOADED event tells that the service is reachable
+	if !c.DescriptorUploaded(onionAddress) {
+		return errors.New("onion service descriptor not published yet")
+	}
+
+	return nil
+}
+
+// Serve answers /healthz and /readyz until the context is cancelled.
+func Serve(ctx context.Context, checker *Checker) error {
+	server := &http.Server{
+		Addr:              net.JoinHostPort("", strconv.Itoa(Port)),
+		Handler:           handler(checker),
+		ReadHeaderTimeout: checkTimeout,
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	log.Infof("Serving health checks on %s", server.Addr)
+
+	err := server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return errors.Wrap(err, "serving health checks")
+	}
+
+	return nil
+}
+
+// handler serves the checks of checker.
+func handler(checker *Checker) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		if !checker.Alive() {
+			http.Error(w, "tor is not running", http.StatusServiceUnavailable)
+
+			return
+		}
+
+		_, _ = w.Write([]byte("ok"))
+	})
+
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, _ *http.Request) {
+		err := checker.Ready()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+
+			return
+		}
+
+		_, _ = w.Write([]byte("ok"))
+	})
+
+	return mux
+}
@@ -0,0 +1,135 @@
+package health
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/bugfest/tor-controller/agents/common/controltest"
+)
+
+const onionAddress = "abcdefghijklmnopqrstuvwxyz234567abcdefghijklmnopqrstuvwx.onion"
+
+func bootstrapped(progress string) map[string][]string {
+	return map[string][]string{
+		"GETINFO status/bootstrap-phase": {
+			`250-status/bootstrap-phase=NOTICE BOOTSTRAP PROGRESS=` + progress + ` TAG=done SUMMARY="Done"`,
+			"250 OK",
+		},
+	}
+}
+
+func TestReady(t *testing.T) {
+	uploaded := func(address string) bool { return address == onionAddress }
+	notUploaded := func(string) bool { return false }
+
+	tests := []struct {
+		name         string
+		alive        bool
+		replies      map[string][]string
+		onionAddress func() (string, error)
+		uploaded     func(string) bool
+		wantErr      bool
+	}{
+		{
+			name:    "tor not running",
+			alive:   false,
+			replies: bootstrapped("100"),
+			wantErr: true,
+		},
+		{
+			name:    "tor bootstrapping",
+			alive:   true,
+			replies: bootstrapped("50"),
+			wantErr: true,
+		},
+		{
+			name:    "tor bootstrapped",
+			alive:   true,
+			replies: bootstrapped("100"),
+		},
+		{
+			name:         "no hostname yet",
+			alive:        true,
+			replies:      bootstrapped("100"),
+			onionAddress: func() (string, error) { return "", errors.New("no such file") },
+			uploaded:     uploaded,
+			wantErr:      true,
+		},
+		{
+			name:         "descriptor not uploaded",
+			alive:        true,
+			replies:      bootstrapped("100"),
+			onionAddress: func() (string, error) { return onionAddress, nil },
+			uploaded:     notUploaded,
+			wantErr:      true,
+		},
+		{
+			name:         "descriptor uploaded",
+			alive:        true,
+			replies:      bootstrapped("100"),
+			onionAddress: func() (string, error) { return onionAddress, nil },
+			uploaded:     uploaded,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			server := controltest.Start(t, tt.replies)
+
+			checker := &Checker{
+				Network:            server.Network,
+				Address:            server.Address,
+				Alive:              func() bool { return tt.alive },
+				OnionAddress:       tt.onionAddress,
+				DescriptorUploaded: tt.uploaded,
+			}
+
+			err := checker.Ready()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Ready() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	server := controltest.Start(t, bootstrapped("50"))
+
+	alive := true
+	checker := &Checker{
+		Network: server.Network,
+		Address: server.Address,
+		Alive:   func() bool { return alive },
+	}
+
+	get := func(path string) int {
+		recorder := httptest.NewRecorder()
+		handler(checker).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+
+		return recorder.Code
+	}
+
+	if code := get("/healthz"); code != http.StatusOK {
+		t.Errorf("/healthz = %d while running, want %d", code, http.StatusOK)
+	}
+
+	if code := get("/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz = %d while bootstrapping, want %d", code, http.StatusServiceUnavailable)
+	}
+
+	server.SetReply("GETINFO status/bootstrap-phase", bootstrapped("100")["GETINFO status/bootstrap-phase"]...)
+
+	if code := get("/readyz"); code != http.StatusOK {
+		t.Errorf("/readyz = %d once bootstrapped, want %d", code, http.StatusOK)
+	}
+
+	alive = false
+
+	if code := get("/healthz"); code != http.StatusServiceUnavailable {
+		t.Errorf("/healthz = %d once stopped, want %d", code, http.StatusServiceUnavailable)
+	}
+}
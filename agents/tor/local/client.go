@@ -1,81 +1,60 @@
 package local
 
 import (
-	"context"
 	"flag"
 	"os"
-	"os/signal"
-	"syscall"
-	"time"
+	"path/filepath"
+	"strings"
 
 	"github.com/cockroachdb/errors"
 
 	log "github.com/sirupsen/logrus"
 
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
-
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/dynamic/dynamicinformer"
-	"k8s.io/client-go/informers"
-	"k8s.io/client-go/tools/cache"
-	"k8s.io/client-go/util/workqueue"
-	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	common "github.com/bugfest/tor-controller/agents/common"
+	config "github.com/bugfest/tor-controller/agents/tor/config"
+	health "github.com/bugfest/tor-controller/agents/tor/health"
+	router "github.com/bugfest/tor-controller/agents/tor/router"
 	tordaemon "github.com/bugfest/tor-controller/agents/tor/tordaemon"
 	torv1alpha2 "github.com/bugfest/tor-controller/apis/tor/v1alpha2"
 )
 
 var namespace, onionServiceName string
 
+var controlSocket = filepath.Join(torv1alpha2.TorControlDir, "control.sock")
+
 func init() {
 	flag.StringVar(&namespace, "namespace", "",
 		"The namespace of the OnionService to manage.")
 
 	flag.StringVar(&onionServiceName, "name", "",
 		"The name of the OnionService to manage.")
-}
-
-// GetClient returns a client for the torv1alpha2 OnionService CRD.
-func GetClient() client.Client {
-	scheme := runtime.NewScheme()
-	kubeconfig := ctrl.GetConfigOrDie()
-
-	err := torv1alpha2.AddToScheme(scheme)
-	if err != nil {
-		log.Println(err)
-	}
-
-	controllerClient, err := client.New(kubeconfig, client.Options{Scheme: scheme})
-	if err != nil {
-		log.Fatal(err)
 
-		return nil
-	}
-
-	return controllerClient
+	flag.StringVar(&config.ClusterDomain, "cluster-domain", config.ClusterDomain,
+		"The DNS domain of the cluster, used to reach the backend Services.")
 }
 
 // Manager is the main struct for the tor agent.
 type Manager struct {
 	kclient client.Client
 
-	stopCh chan struct{}
-
 	daemon tordaemon.Tor
 
+	// HTTP routes of the onion service
+	router router.Router
+
 	// controller loop
 	controller *Controller
+
+	// watcher of the OnionService
+	watcher *common.Watcher
 }
 
 func New() *Manager {
 	return &Manager{
-		kclient: GetClient(),
-		stopCh:  make(chan struct{}),
+		kclient: common.GetClient(),
 		daemon:  tordaemon.Tor{},
 	}
 }
@@ -95,178 +74,95 @@ func (manager *Manager) Run() error {
 		return errors.Wrap(err, "error parsing flags")
 	}
 
-	// listen to signals
-	signalCh := make(chan os.Signal, 1)
+	manager.controller = NewController(manager)
 
-	signal.Notify(signalCh, syscall.SIGTERM, syscall.SIGHUP)
-	manager.signalHandler(signalCh)
+	watcher := &common.Watcher{
+		Object:    &torv1alpha2.OnionService{},
+		Namespace: namespace,
+		Name:      onionServiceName,
+		Sync:      manager.controller.sync,
+	}
+	manager.watcher = watcher
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	// SIGINT/SIGTERM stop the agent, SIGHUP forces a config resync
+	ctx := common.SignalContext(watcher.Resync)
 	manager.daemon.SetContext(ctx)
 
-	err := os.Chmod("/run/tor/service", 0o700)
+	// tor only accepts a HiddenServiceDir owned by its user and private to it
+	err := createPrivateDir(torServiceDir)
 	if err != nil {
-		log.Error(err, "error changing /run/tor/service permissions")
+		return err
 	}
 
-	// start watching for API server events that trigger applies
-	manager.onionServiceCRDWatcher(namespace)
-
-	// Wait for all goroutines to exit
-	<-manager.stopCh
-
-	return nil
-}
-
-func (manager *Manager) Must(err error) *Manager {
+	err = os.MkdirAll(torv1alpha2.TorControlDir, defaultUnixDirPermission)
 	if err != nil {
-		log.Println(err)
-		os.Exit(1)
+		log.Error(err, "error creating the tor control directory")
 	}
 
-	return manager
-}
-
-func (manager *Manager) signalHandler(ch chan os.Signal) {
 	go func() {
-		select {
-		case <-manager.stopCh:
-			break
-		case sig := <-ch:
-			switch sig {
-			case syscall.SIGHUP:
-				log.Println("received SIGHUP")
-
-			case syscall.SIGINT:
-				log.Println("received SIGINT")
-				close(manager.stopCh)
-
-			case syscall.SIGTERM:
-				log.Println("received SIGTERM")
-				close(manager.stopCh)
-			}
+		err := common.ServeMetrics(ctx)
+		if err != nil {
+			log.Error(err)
 		}
 	}()
-}
 
-func GetDynamicInformer(resourceType, namespace string) (informers.GenericInformer, error) {
-	cfg := ctrl.GetConfigOrDie()
+	go common.WatchDescriptors(ctx, "unix", controlSocket)
 
-	// Grab a dynamic interface that we can create informers from
-	dynamicConfig, err := dynamic.NewForConfig(cfg)
-	if err != nil {
-		return nil, errors.Wrap(err, "error creating dynamic client")
-	}
+	// Rotated keys and authorized clients are copied to tor's service
+	// directory on sync
+	go common.WatchDirs(ctx, watcher.Resync, privateKeyMountDir, authorizedClientsMountDir)
 
-	// Create a factory object that can generate informers for resource types
-	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(dynamicConfig, 0,
-		namespace,
-		func(x *metav1.ListOptions) {
-			x.FieldSelector = "metadata.name=" + onionServiceName
+	go func() {
+		err := health.Serve(ctx, &health.Checker{
+			Network:            "unix",
+			Address:            controlSocket,
+			Alive:              manager.daemon.Running,
+			OnionAddress:       onionAddress,
+			DescriptorUploaded: common.DescriptorUploaded,
 		})
+		if err != nil {
+			log.Error(err)
+		}
+	}()
 
-	// "GroupVersionResource" to say what to watch e.g. "deployments.v1.apps" or "seldondeployments.v1.machinelearning.seldon.io"
-	gvr, _ := schema.ParseResourceArg(resourceType)
+	// start watching for API server events that trigger applies
+	err = watcher.Run(ctx)
 
-	// Finally, create our informer for deployments!
-	informer := factory.ForResource(*gvr)
+	// Give the daemon a chance to shut down cleanly
+	manager.daemon.Wait()
 
-	return informer, nil
+	return errors.Wrap(err, "watching OnionService")
 }
 
-func parseOnionService(obj interface{}) (torv1alpha2.OnionService, error) {
-	service := torv1alpha2.OnionService{}
-	// try following https://erwinvaneyk.nl/kubernetes-unstructured-to-typed/
-	unstructuredObj, ok := obj.(*unstructured.Unstructured)
-	if !ok {
-		log.Println("could not convert obj to OnionService")
-
-		return service, errors.New("could not convert obj to OnionService")
-	}
-
-	err := runtime.DefaultUnstructuredConverter.
-		FromUnstructured(unstructuredObj.UnstructuredContent(), &service)
+// createPrivateDir creates the directory, or restricts the permissions of an
+// existing one, so that only the agent user can access it.
+func createPrivateDir(dir string) error {
+	err := os.MkdirAll(dir, defaultUnixDirPermission)
 	if err != nil {
-		log.Println("could not convert obj to OnionService")
-		log.Print(err)
-
-		return service, errors.Wrap(err, "could not convert obj to OnionService")
+		return errors.Wrapf(err, "creating %s", dir)
 	}
 
-	return service, nil
-}
-
-// onionServiceCRDWatcher watches for OnionService CRD events.
-func (manager *Manager) runOnionServiceCRDInformer(stopCh <-chan struct{}, sharedIndexInformer cache.SharedIndexInformer, _ string) {
-	// create the workqueue
-	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
-
-	indexers := cache.Indexers{}
-
-	handlers := cache.ResourceEventHandlerFuncs{
-		AddFunc: func(obj interface{}) {
-			log.Debug("OnionService added")
-			onionservice, err := parseOnionService(obj)
-			if err == nil {
-				log.Infof("Added OnionService: %s/%s", onionservice.Namespace, onionservice.Name)
-				key, err := cache.MetaNamespaceKeyFunc(onionservice.GetObjectMeta())
-				if err != nil {
-					log.Error(err)
-				}
-				queue.AddAfter(key, 2*time.Second)
-			}
-		},
-		UpdateFunc: func(oldObj, newObj interface{}) {
-			log.Debug("OnionService updated")
-			onionservice, err := parseOnionService(newObj)
-			if err == nil {
-				log.Infof("Updated OnionService: %s/%s", onionservice.Namespace, onionservice.Name)
-				key, err := cache.MetaNamespaceKeyFunc(onionservice.GetObjectMeta())
-				if err == nil {
-					queue.AddAfter(key, 2*time.Second)
-				}
-			}
-		},
-		DeleteFunc: func(obj interface{}) {
-			log.Debug("OnionService deleted")
-			onionservice, err := parseOnionService(obj)
-			if err == nil {
-				log.Infof("Deleted OnionService: %s/%s", onionservice.Namespace, onionservice.Name)
-				key, err := cache.MetaNamespaceKeyFunc(onionservice.GetObjectMeta())
-				if err == nil {
-					queue.AddAfter(key, 2*time.Second)
-				}
-			}
-		},
-	}
+	// MkdirAll leaves existing directories alone and honours the umask
+	err = os.Chmod(dir, defaultUnixDirPermission)
 
-	sharedIndexInformer.AddEventHandler(handlers)
+	return errors.Wrapf(err, "changing %s permissions", dir)
+}
 
-	err := sharedIndexInformer.AddIndexers(indexers)
+// onionAddress reads the address tor generated for the service.
+func onionAddress() (string, error) {
+	hostname, err := os.ReadFile("/run/tor/service/hostname")
 	if err != nil {
-		log.Errorf("Error adding indexers: %s", err)
+		return "", errors.Wrap(err, "reading hostname")
 	}
 
-	go sharedIndexInformer.Run(stopCh)
-
-	log.Info("Listening for events")
-
-	manager.controller = NewController(queue, sharedIndexInformer, manager)
-
-	log.Info("Running event controller")
-
-	go manager.controller.Run(1, manager.stopCh)
-
-	<-stopCh
+	return strings.TrimSpace(string(hostname)), nil
 }
 
-func (manager *Manager) onionServiceCRDWatcher(namespace string) {
-	// dynamic informer needs to be told which type to watch
-	onionserviceinformer, _ := GetDynamicInformer("onionservices.v1alpha2.tor.k8s.torproject.org", namespace)
-
-	stopper := make(chan struct{})
-	defer close(stopper)
+func (manager *Manager) Must(err error) *Manager {
+	if err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
 
-	manager.runOnionServiceCRDInformer(stopper, onionserviceinformer.Informer(), namespace)
+	return manager
 }
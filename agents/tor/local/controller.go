@@ -2,229 +2,158 @@ package local
 
 import (
 	"context"
-	"io"
 	"os"
 	"path"
 	"strings"
-	"time"
 
 	"github.com/cockroachdb/errors"
 	log "github.com/sirupsen/logrus"
 
-	"k8s.io/apimachinery/pkg/util/runtime"
-	"k8s.io/apimachinery/pkg/util/wait"
-	"k8s.io/client-go/tools/cache"
-	"k8s.io/client-go/util/workqueue"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	common "github.com/bugfest/tor-controller/agents/common"
 	config "github.com/bugfest/tor-controller/agents/tor/config"
+	filesync "github.com/bugfest/tor-controller/agents/tor/filesync"
 	v1alpha2 "github.com/bugfest/tor-controller/apis/tor/v1alpha2"
 )
 
 const (
-	authorizedClientsDir  = "/run/tor/service/authorized_clients"
-	torFilePath           = "/run/tor/torfile"
-	torServiceDir         = "/run/tor/service/"
-	defaultUnixPermission = 0o600
+	authorizedClientsDir      = "/run/tor/service/authorized_clients"
+	authorizedClientsMountDir = "/run/tor-secrets/authorized_clients"
+	torFilePath               = "/run/tor/torfile"
+	torServiceDir             = "/run/tor/service/"
+	privateKeyMountDir        = "/run/tor-secrets/key"
+	obConfigPath              = "/run/tor/service/ob_config"
+	defaultUnixPermission     = 0o600
+	defaultUnixDirPermission  = 0o700
 )
 
 type Controller struct {
-	indexer      cache.Indexer
-	queue        workqueue.RateLimitingInterface
-	informer     cache.Controller
 	localManager *Manager
 }
 
-func NewController(queue workqueue.RateLimitingInterface, informer cache.SharedIndexInformer, localManager *Manager) *Controller {
+func NewController(localManager *Manager) *Controller {
 	return &Controller{
-		informer:     informer,
-		indexer:      informer.GetIndexer(),
-		queue:        queue,
 		localManager: localManager,
 	}
 }
 
-func (c *Controller) processNextItem() bool {
-	key, quit := c.queue.Get()
-	if quit {
-		log.Info("Queue quits")
-
-		return false
-	}
-
-	defer c.queue.Done(key)
-
-	keyString, ok := key.(string)
-	if !ok {
-		log.Errorf("Key is not a string: %v", key)
-
-		return false
-	}
-
-	err := c.sync(keyString)
-
-	c.handleErr(err, key)
-
-	return true
-}
-
 //nolint:gocognit,nestif // this function is long for a reason
-func (c *Controller) sync(key string) error {
-	log.Infof("Getting key %s", key)
-
-	// Get the OnionService resource with this key
-	obj, exists, err := c.indexer.GetByKey(key)
-	if err != nil {
-		log.Errorf("Fetching object with key %s from store failed with %v", key, err)
-
-		return errors.Wrap(err, "fetching object from store")
-	}
-
-	if !exists {
-		log.Warnf("OnionService %s does not exist anymore", key)
-
-		return nil
+func (c *Controller) sync(ctx context.Context, obj client.Object) error {
+	onionService, ok := obj.(*v1alpha2.OnionService)
+	if !ok {
+		return errors.Newf("%T is not an OnionService", obj)
 	}
 
-	log.Debugf("%v", obj)
+	log.Infof("Syncing OnionService %s/%s", onionService.Namespace, onionService.Name)
+	log.Debugf("%v", onionService)
 
-	onionService, err := parseOnionService(obj)
+	// torfile
+	torConfig, err := config.TorConfigForService(onionService)
 	if err != nil {
-		log.Errorf("Error in parseOnionService: %s", err)
+		log.Errorf("Generating config failed with %v", err)
 
-		return errors.Wrap(err, "parsing onion service")
+		return errors.Wrap(err, "generating config")
 	}
 
-	// torfile
-	torConfig, err := config.TorConfigForService(&onionService)
+	// Tor forwards the HTTP rules to the router sockets
+	err = c.localManager.router.Update(config.HTTPRoutes(onionService))
 	if err != nil {
-		log.Errorf("Generating config failed with %v", err)
+		log.Errorf("Updating HTTP routes failed with %v", err)
 
-		return errors.Wrap(err, "generating config")
+		return errors.Wrap(err, "updating HTTP routes")
 	}
 
-	reload := false
+	torReload := false
 
 	torfile, err := os.ReadFile(torFilePath)
 
 	switch {
 	case os.IsNotExist(err):
-		reload = true
+		torReload = true
 	case err != nil:
 		return errors.Wrap(err, "reading torfile")
 	case string(torfile) != torConfig:
-		reload = true
-	}
-
-	if reload {
-		err = serviceReload(&onionService, []byte(torConfig))
-		if err != nil {
-			log.Errorf("Reloading service failed with %v", err)
-
-			return errors.Wrap(err, "reloading service")
-		}
+		torReload = true
 	}
 
-	// update hostname
-	err = copyIfNotExist(
-		"/run/tor/service/key/hostname",
-		"/run/tor/service/hostname",
-	)
+	// update hostname, keys and authorized clients
+	reload, err := serviceFiles(onionService).Sync()
 	if err != nil {
-		log.Errorf("Updating hostname failed with %v", err)
+		log.Errorf("Updating service files failed with %v", err)
 	}
 
-	// update private and public keys
-	publicKeyFileName := "hs_ed25519_public_key"
-	privateKeyFileName := "hs_ed25519_secret_key"
-
-	if onionService.Spec.GetVersion() == 2 {
-		publicKeyFileName = "public_key"
-		privateKeyFileName = "private_key"
-	}
-
-	err = copyIfNotExist(
-		path.Join(torServiceDir, "key", publicKeyFileName),
-		path.Join(torServiceDir, publicKeyFileName),
-	)
-	if err != nil {
-		log.Errorf("Updating public key failed with %v", err)
-	}
-
-	err = copyIfNotExist(
-		path.Join(torServiceDir, "key", privateKeyFileName),
-		path.Join(torServiceDir, privateKeyFileName),
-	)
-	if err != nil {
-		log.Errorf("Updating private key failed with %v", err)
-	}
-
-	// copy authorized keys to the correct directory (/run/tor/service/authorized_keys)
-	// as Tor requires this directory to be only accessible for the current user (0700)
-	// and k8s does not allow to set the permissions of the directory where the projected
-	// secrets are mounted
-	files, err := os.ReadDir("/run/tor/service/.authorized_clients/")
-	if err != nil {
-		log.Info("No authorized keys found")
-	} else {
-		// Create `authorized_clients_dir` directory if it does not exist
-		if _, err := os.Stat(authorizedClientsDir); errors.Is(err, os.ErrNotExist) {
-			err := os.Mkdir(authorizedClientsDir, 0o700)
-			if err != nil {
-				log.Fatalf("Creating directory %s failed with %v", authorizedClientsDir, err)
-			}
-		}
-
-		// Copy *.auth files from mounted secrets into `authorized_clients_dir` directory
-		for _, file := range files {
-			if !file.IsDir() && strings.HasSuffix(file.Name(), ".auth") {
-				err = copyIfNotExist(
-					path.Join("/run/tor/service/.authorized_clients/", file.Name()),
-					path.Join("/run/tor/service/authorized_clients/", file.Name()),
-				)
-				if err != nil {
-					log.Errorf("Copying authorized keys failed with %v", err)
-				}
-			}
-		}
-	}
+	updateAuthorizedClients()
 
 	// ob_config needs to be created if this Hidden Service have a Master one in front
 	if len(onionService.Spec.MasterOnionAddress) > 0 {
-		obConfig, err := config.ObConfigForService(&onionService)
+		obConfig, err := config.ObConfigForService(onionService)
 		if err != nil {
 			log.Errorf("Generating ob_config failed with %v", err)
 
 			return errors.Wrap(err, "generating ob_config")
 		}
 
-		obfile, err := os.ReadFile("/run/tor/service/ob_config")
+		obReload := false
+
+		obfile, err := os.ReadFile(obConfigPath)
 
 		switch {
 		case os.IsNotExist(err):
-			reload = true
+			obReload = true
 		case err != nil:
 			return errors.Wrap(err, "reading ob_config")
 		case string(obfile) != obConfig:
-			reload = true
+			obReload = true
 		}
 
-		if reload {
+		if obReload {
 			log.Infof("Updating onionbalance config for %s/%s", onionService.Namespace, onionService.Name)
 
-			err = os.WriteFile("/run/tor/service/ob_config", []byte(obConfig), defaultUnixPermission)
+			err = filesync.WriteFileAtomic(obConfigPath, []byte(obConfig), defaultUnixPermission)
 			if err != nil {
 				log.Errorf("Writing config failed with %v", err)
+				common.ReloadFailed()
 
 				return errors.Wrap(err, "writing ob_config")
 			}
+
+			reload = true
 		}
 	}
 
-	if reload {
+	// The torfile goes last, so tor verifies it along with the files above
+	var configErr error
+
+	if torReload {
+		configErr = serviceReload(onionService, []byte(torConfig))
+
+		switch {
+		case errors.Is(configErr, errConfigRejected):
+			// Retrying won't help, the error is reported in the status until
+			// the OnionService is fixed
+			log.Errorf("Reloading service failed with %v", configErr)
+			common.ReloadFailed()
+		case configErr != nil:
+			log.Errorf("Reloading service failed with %v", configErr)
+			common.ReloadFailed()
+
+			return errors.Wrap(configErr, "reloading service")
+		default:
+			reload = true
+		}
+	}
+
+	// A rejected torfile isn't written: reloading would start tor without
+	// config on the first start, the other files are picked up once fixed
+	if reload && configErr == nil {
 		c.localManager.daemon.Reload()
+		common.ReloadSucceeded()
 	}
 
-	err = c.updateOnionServiceStatus(&onionService)
+	err = c.updateOnionServiceStatus(ctx, onionService, configErr)
 	if err != nil {
 		log.Errorf("Updating status failed with %v", err)
 
@@ -234,23 +163,56 @@ func (c *Controller) sync(key string) error {
 	return nil
 }
 
-func (c *Controller) updateOnionServiceStatus(onionService *v1alpha2.OnionService) error {
-	hostname, err := os.ReadFile("/run/tor/service/hostname")
-	if err != nil {
-		log.Errorf("Got this error when trying to find hostname: %v", err)
+func (c *Controller) updateOnionServiceStatus(ctx context.Context, onionService *v1alpha2.OnionService, configErr error) error {
+	// A config rejected on the first start leaves no hostname, the condition
+	// is reported anyway
+	newHostname := onionService.Status.Hostname
 
-		return errors.Wrap(err, "reading hostname")
+	hostname, hostnameErr := os.ReadFile("/run/tor/service/hostname")
+	if hostnameErr != nil {
+		log.Errorf("Got this error when trying to find hostname: %v", hostnameErr)
+	} else {
+		newHostname = strings.TrimSpace(string(hostname))
 	}
 
-	newHostname := strings.TrimSpace(string(hostname))
+	condition := metav1.Condition{
+		Type:               v1alpha2.OnionServiceConfigValid,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: onionService.Generation,
+		Reason:             "Verified",
+		Message:            "tor accepted the config",
+	}
+
+	if configErr != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "VerificationFailed"
+		condition.Message = configErr.Error()
+	}
+
+	conditionChanged := setCondition(&onionService.Status.Conditions, condition)
+
+	vanguards := vanguardsCondition(onionService, "unix", controlSocket)
+
+	switch {
+	case vanguards != nil:
+		conditionChanged = setCondition(&onionService.Status.Conditions, *vanguards) || conditionChanged
+
+		if vanguards.Status != metav1.ConditionTrue {
+			c.localManager.watcher.ResyncAfter(vanguardsRecheckDelay)
+		}
+	case meta.FindStatusCondition(onionService.Status.Conditions, v1alpha2.OnionServiceVanguardsActive) != nil:
+		meta.RemoveStatusCondition(&onionService.Status.Conditions, v1alpha2.OnionServiceVanguardsActive)
+
+		conditionChanged = true
+	}
 
-	if newHostname != onionService.Status.Hostname {
+	if newHostname != onionService.Status.Hostname || conditionChanged {
 		log.Infof("Got new hostname: %s", newHostname)
 		onionService.Status.Hostname = newHostname
 
 		log.Debugf("Updating onionService to: %v", onionService)
 
-		err = c.localManager.kclient.Status().Update(context.Background(), onionService)
+		err := c.localManager.kclient.Status().Update(ctx, onionService)
 		if err != nil {
 			log.Errorf("Error updating onionService: %s", err)
 
@@ -258,112 +220,81 @@ func (c *Controller) updateOnionServiceStatus(onionService *v1alpha2.OnionServic
 		}
 	}
 
-	return nil
-}
-
-// handleErr checks if an error happened and makes sure we will retry later.
-func (c *Controller) handleErr(err error, key interface{}) {
-	if err == nil {
-		c.queue.Forget(key)
-
-		return
+	// tor may not have written the hostname yet, retry unless the config
+	// was rejected
+	if hostnameErr != nil && configErr == nil {
+		return errors.Wrap(hostnameErr, "reading hostname")
 	}
 
-	// This controller retries 5 times if something goes wrong. After that, it stops trying.
-	//nolint:gomnd // 5 is a reasonable number of retries
-	if c.queue.NumRequeues(key) < 5 {
-		log.Errorf("Error syncing onionservice %v: %v", key, err)
-
-		// Re-enqueue the key rate limited. Based on the rate limiter on the
-		// queue and the re-enqueue history, the key will be processed later again.
-		// c.queue.AddRateLimited(key)
-		c.queue.AddAfter(key, 3*time.Second)
-
-		return
-	}
-
-	c.queue.Forget(key)
-	// Report to an external entity that, even after several retries, we could not successfully process this key
-	runtime.HandleError(err)
-	log.Infof("Dropping onionservice %q out of the queue: %v", key, err)
+	return nil
 }
 
-func (c *Controller) Run(threadiness int, stopCh chan struct{}) {
-	defer runtime.HandleCrash()
-
-	// Let the workers stop when we are done
-	defer c.queue.ShutDown()
-	log.Info("Starting controller")
+// setCondition sets the condition in conditions, telling whether it changed.
+func setCondition(conditions *[]metav1.Condition, condition metav1.Condition) bool {
+	current := meta.FindStatusCondition(*conditions, condition.Type)
+	changed := current == nil || current.Status != condition.Status || current.Message != condition.Message
 
-	go c.informer.Run(stopCh)
+	meta.SetStatusCondition(conditions, condition)
 
-	// Wait for all involved caches to be synced, before processing items from the queue is started
-	if !cache.WaitForCacheSync(stopCh, c.informer.HasSynced) {
-		runtime.HandleError(errors.New("timed out waiting for caches to sync"))
-
-		return
-	}
-
-	for i := 0; i < threadiness; i++ {
-		go wait.Until(c.runWorker, time.Second, stopCh)
-	}
-
-	<-stopCh
-	log.Info("Stopping controller")
+	return changed
 }
 
-func (c *Controller) runWorker() {
-	for c.processNextItem() {
-	}
-}
+// serviceFiles copies the hostname, keys and authorized clients from the
+// mounted secrets to tor's service directory.
+func serviceFiles(onionService *v1alpha2.OnionService) *filesync.Syncer {
+	publicKeyFileName := "hs_ed25519_public_key"
+	privateKeyFileName := "hs_ed25519_secret_key"
 
-func copyIfNotExist(src, dst string) error {
-	_, err := os.Stat(dst)
-	if !os.IsNotExist(err) {
-		return errors.Wrap(err, "checking if file exists")
+	if onionService.Spec.GetVersion() == 2 {
+		publicKeyFileName = "public_key"
+		privateKeyFileName = "private_key"
 	}
 
-	log.Infof("Creating copy of %s at %s", src, dst)
+	files := []filesync.File{}
 
-	srcfd, err := os.Open(src)
-	if err != nil {
-		return errors.Wrap(err, "opening source file")
+	for _, name := range []string{"hostname", publicKeyFileName, privateKeyFileName} {
+		files = append(files, filesync.File{
+			Src:  path.Join(privateKeyMountDir, name),
+			Dst:  path.Join(torServiceDir, name),
+			Mode: defaultUnixPermission,
+		})
 	}
-	defer srcfd.Close()
 
-	dstfd, err := os.Create(dst)
-	if err != nil {
-		return errors.Wrap(err, "creating destination file")
+	return &filesync.Syncer{
+		Files: files,
+		// Tor requires the authorized clients directory to be only accessible
+		// for the current user (0700), which k8s does not allow to set on the
+		// directory where the secrets are mounted
+		Dirs: []filesync.Dir{
+			{
+				Src: authorizedClientsMountDir,
+				Dst: authorizedClientsDir,
+				Match: func(name string) bool {
+					return strings.HasSuffix(name, ".auth")
+				},
+				DirMode:  defaultUnixDirPermission,
+				FileMode: defaultUnixPermission,
+			},
+		},
 	}
-	defer dstfd.Close()
+}
 
-	_, err = io.Copy(dstfd, srcfd)
+// updateAuthorizedClients counts the authorized clients tor was given.
+func updateAuthorizedClients() {
+	entries, err := os.ReadDir(authorizedClientsDir)
 	if err != nil {
-		return errors.Wrap(err, "copying file")
-	}
+		log.Debugf("Listing authorized clients failed with %v", err)
 
-	srcinfo, err := os.Stat(src)
-	if err != nil {
-		return errors.Wrap(err, "getting source file info")
-	}
-
-	err = dstfd.Chmod(srcinfo.Mode())
-	if err != nil {
-		return errors.Wrap(err, "setting destination file mode")
+		return
 	}
 
-	return nil
-}
+	count := 0
 
-func serviceReload(onionService *v1alpha2.OnionService, configData []byte) error {
-	log.Infof("Updating tor config for %s/%s", onionService.Namespace, onionService.Name)
-
-	err := os.WriteFile(torFilePath, configData, defaultUnixPermission)
-	if err != nil {
-		log.Errorf("Writing config failed with %v", err)
-
-		return errors.Wrap(err, "writing config")
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".auth") {
+			count++
+		}
 	}
 
-	return nil
+	authorizedClients.Set(float64(count))
 }
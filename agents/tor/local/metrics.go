@@ -0,0 +1,8 @@
+package local
+
+import (
+	common "github.com/bugfest/tor-controller/agents/common"
+)
+
+var authorizedClients = common.NewGauge("authorized_clients",
+	"Number of authorized clients of the onion service.")
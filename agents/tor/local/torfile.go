@@ -0,0 +1,125 @@
+package local
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	log "github.com/sirupsen/logrus"
+
+	filesync "github.com/bugfest/tor-controller/agents/tor/filesync"
+	v1alpha2 "github.com/bugfest/tor-controller/apis/tor/v1alpha2"
+)
+
+const (
+	// torFileLastGoodPath keeps the last config tor accepted
+	torFileLastGoodPath = torFilePath + ".last-good"
+
+	verifyConfigTimeout = 30 * time.Second
+)
+
+// errConfigRejected marks the errors returned when tor refuses a config.
+var errConfigRejected = errors.New("tor rejected the config")
+
+// verifyTorConfig asks tor to validate the config in name. When it is
+// rejected, the returned error holds tor's warnings and is marked as
+// errConfigRejected.
+func verifyTorConfig(name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), verifyConfigTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "tor", "--verify-config", "-f", name).CombinedOutput()
+	if err == nil {
+		return nil
+	}
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return errors.Wrap(err, "running tor --verify-config")
+	}
+
+	return errors.Mark(errors.Newf("%s", torWarnings(output)), errConfigRejected)
+}
+
+// torWarnings keeps the warning and error lines of tor's output, which
+// otherwise includes every notice printed while loading the config.
+func torWarnings(output []byte) string {
+	warnings := []string{}
+
+	for _, line := range strings.Split(string(bytes.TrimSpace(output)), "\n") {
+		if strings.Contains(line, "[warn]") || strings.Contains(line, "[err]") {
+			warnings = append(warnings, strings.TrimSpace(line))
+		}
+	}
+
+	if len(warnings) == 0 {
+		return strings.TrimSpace(string(output))
+	}
+
+	return strings.Join(warnings, "\n")
+}
+
+// serviceReload swaps the torfile for configData once tor verified it. When
+// the new config is rejected, the last good one is put back in place so a
+// tor restart keeps serving the onion service.
+func serviceReload(onionService *v1alpha2.OnionService, configData []byte) error {
+	log.Infof("Updating tor config for %s/%s", onionService.Namespace, onionService.Name)
+
+	candidate := torFilePath + ".new"
+
+	err := filesync.WriteFileAtomic(candidate, configData, defaultUnixPermission)
+	if err != nil {
+		return errors.Wrap(err, "writing config")
+	}
+	defer os.Remove(candidate)
+
+	err = verifyTorConfig(candidate)
+	if err != nil {
+		if errors.Is(err, errConfigRejected) {
+			log.Errorf("tor rejected the new config: %v", err)
+
+			rollbackErr := restoreLastGoodConfig()
+			if rollbackErr != nil {
+				log.Errorf("Restoring the last good config failed with %v", rollbackErr)
+			}
+		}
+
+		return errors.Wrap(err, "verifying config")
+	}
+
+	err = filesync.WriteFileAtomic(torFilePath, configData, defaultUnixPermission)
+	if err != nil {
+		return errors.Wrap(err, "replacing config")
+	}
+
+	err = filesync.WriteFileAtomic(torFileLastGoodPath, configData, defaultUnixPermission)
+	if err != nil {
+		log.Warnf("Saving the last good config failed with %v", err)
+	}
+
+	return nil
+}
+
+// restoreLastGoodConfig puts the last verified config back if the torfile
+// differs from it.
+func restoreLastGoodConfig() error {
+	lastGood, err := os.ReadFile(torFileLastGoodPath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return errors.Wrap(err, "reading last good config")
+	}
+
+	current, err := os.ReadFile(torFilePath)
+	if err == nil && bytes.Equal(current, lastGood) {
+		return nil
+	}
+
+	log.Warnf("Rolling back %s to the last good config", torFilePath)
+
+	return filesync.WriteFileAtomic(torFilePath, lastGood, defaultUnixPermission)
+}
@@ -0,0 +1,87 @@
+package local
+
+import (
+	"time"
+
+	"github.com/cockroachdb/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	common "github.com/bugfest/tor-controller/agents/common"
+	v1alpha2 "github.com/bugfest/tor-controller/apis/tor/v1alpha2"
+)
+
+const (
+	vanguardsCheckTimeout = 5 * time.Second
+
+	// Until they're active, vanguards are checked again after this delay:
+	// tor may still be starting and the add-on connects to tor afterwards
+	vanguardsRecheckDelay = 30 * time.Second
+)
+
+// vanguardsCondition asks tor whether the vanguards configured in the spec
+// are in effect. Vanguards-lite is a tor option, while the add-on pins the
+// layer 2 guards through the control port once connected. Nil is returned
+// when vanguards are disabled.
+func vanguardsCondition(onionService *v1alpha2.OnionService, network, address string) *metav1.Condition {
+	var option string
+
+	switch {
+	case onionService.Spec.Vanguards.LiteEnabled():
+		option = "VanguardsLiteEnabled"
+	case onionService.Spec.Vanguards.FullEnabled():
+		option = "HSLayer2Nodes"
+	default:
+		return nil
+	}
+
+	condition := &metav1.Condition{
+		Type:               v1alpha2.OnionServiceVanguardsActive,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: onionService.Generation,
+	}
+
+	value, err := getConf(network, address, option)
+
+	switch {
+	case err != nil:
+		condition.Status = metav1.ConditionUnknown
+		condition.Reason = "TorUnreachable"
+		condition.Message = err.Error()
+	case option == "VanguardsLiteEnabled" && value == "1":
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "VanguardsLite"
+		condition.Message = "tor enabled vanguards-lite"
+	case option == "VanguardsLiteEnabled":
+		condition.Reason = "VanguardsLite"
+		condition.Message = "tor didn't enable vanguards-lite"
+	case value != "":
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "VanguardsAddon"
+		condition.Message = "the vanguards add-on pinned the layer 2 guards"
+	default:
+		condition.Reason = "VanguardsAddon"
+		condition.Message = "the vanguards add-on didn't connect to tor yet"
+	}
+
+	return condition
+}
+
+// getConf reads the value of a tor option through the control port.
+func getConf(network, address, option string) (string, error) {
+	ctrl, err := common.DialControl(network, address, time.Now().Add(vanguardsCheckTimeout))
+	if err != nil {
+		return "", err
+	}
+	defer ctrl.Close()
+
+	values, err := ctrl.GetConf(option)
+	if err != nil {
+		return "", errors.Wrapf(err, "getting %s", option)
+	}
+
+	if len(values) == 0 {
+		return "", nil
+	}
+
+	return values[0].Val, nil
+}
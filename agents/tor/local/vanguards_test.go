@@ -0,0 +1,82 @@
+package local
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/bugfest/tor-controller/agents/common/controltest"
+	v1alpha2 "github.com/bugfest/tor-controller/apis/tor/v1alpha2"
+)
+
+func TestVanguardsCondition(t *testing.T) {
+	tests := []struct {
+		name        string
+		vanguards   v1alpha2.VanguardsSpec
+		replies     map[string][]string
+		unreachable bool
+		want        metav1.ConditionStatus
+	}{
+		{
+			name:      "lite enabled",
+			vanguards: v1alpha2.VanguardsSpec{Enable: true},
+			replies:   map[string][]string{"GETCONF VanguardsLiteEnabled": {"250 VanguardsLiteEnabled=1"}},
+			want:      metav1.ConditionTrue,
+		},
+		{
+			name:      "lite left to auto",
+			vanguards: v1alpha2.VanguardsSpec{Enable: true},
+			replies:   map[string][]string{"GETCONF VanguardsLiteEnabled": {"250 VanguardsLiteEnabled=auto"}},
+			want:      metav1.ConditionFalse,
+		},
+		{
+			name:      "add-on connected",
+			vanguards: v1alpha2.VanguardsSpec{Enable: true, Mode: v1alpha2.VanguardsFull},
+			replies:   map[string][]string{"GETCONF HSLayer2Nodes": {"250 HSLayer2Nodes=$AAAA,$BBBB"}},
+			want:      metav1.ConditionTrue,
+		},
+		{
+			name:      "add-on not connected yet",
+			vanguards: v1alpha2.VanguardsSpec{Enable: true, Mode: v1alpha2.VanguardsFull},
+			replies:   map[string][]string{"GETCONF HSLayer2Nodes": {"250 HSLayer2Nodes"}},
+			want:      metav1.ConditionFalse,
+		},
+		{
+			name:        "tor not running",
+			vanguards:   v1alpha2.VanguardsSpec{Enable: true},
+			unreachable: true,
+			want:        metav1.ConditionUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			server := controltest.Start(t, tt.replies)
+
+			address := server.Address
+			if tt.unreachable {
+				address += ".missing"
+			}
+
+			onionService := &v1alpha2.OnionService{Spec: v1alpha2.OnionServiceSpec{Vanguards: tt.vanguards}}
+
+			got := vanguardsCondition(onionService, server.Network, address)
+			if got == nil {
+				t.Fatal("vanguardsCondition() = nil")
+			}
+
+			if got.Status != tt.want {
+				t.Errorf("vanguardsCondition() status = %s (%s), want %s", got.Status, got.Message, tt.want)
+			}
+		})
+	}
+}
+
+func TestVanguardsConditionDisabled(t *testing.T) {
+	onionService := &v1alpha2.OnionService{}
+
+	if got := vanguardsCondition(onionService, "unix", "/nonexistent"); got != nil {
+		t.Errorf("vanguardsCondition() = %v, want nil", got)
+	}
+}
@@ -2,14 +2,37 @@ package main
 
 import (
 	"flag"
+	"os"
 
 	log "github.com/sirupsen/logrus"
 
+	forwarder "github.com/bugfest/tor-controller/agents/tor/forwarder"
 	local "github.com/bugfest/tor-controller/agents/tor/local"
+	standalone "github.com/bugfest/tor-controller/agents/tor/standalone"
 )
 
 // tor-manager main.
 func main() {
+	// forward mode: TCP to onion forwarder used by OnionEndpoints
+	if len(os.Args) > 1 && os.Args[1] == "forward" {
+		err := forwarder.Main(os.Args[2:])
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+
+		return
+	}
+
+	// standalone mode: tor with a static config, used by OnionBalancedServices
+	if len(os.Args) > 1 && os.Args[1] == "standalone" {
+		err := standalone.Main(os.Args[2:])
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+
+		return
+	}
+
 	flag.Parse()
 
 	localManager := local.New()
@@ -0,0 +1,250 @@
+// Package router is the reverse proxy serving the HTTP routes of the onion
+// services. Tor forwards each HTTP port to a unix socket of the router,
+// which proxies the requests to the backend of the best matching route.
+package router
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// Dir holds the sockets of the router.
+	Dir = "/run/tor/http"
+
+	dirPermission     = 0o700
+	readHeaderTimeout = 30 * time.Second
+)
+
+// SocketPath returns the socket serving the routes of a public port.
+func SocketPath(publicPort int32) string {
+	return path.Join(Dir, fmt.Sprintf("%d.sock", publicPort))
+}
+
+// Route sends the requests matching Host and Path to Target.
+type Route struct {
+	// Host to match, the first label may be a "*" wildcard. Any host
+	// matches when empty.
+	Host string
+
+	// Path to match, as a prefix of path elements unless Exact.
+	Path  string
+	Exact bool
+
+	// Target address, e.g: web.default.svc.cluster.local:80
+	Target string
+}
+
+// Router serves the routing tables on their sockets.
+type Router struct {
+	mu      sync.RWMutex
+	tables  map[string][]Route
+	proxies map[string]*httputil.ReverseProxy
+	servers map[string]*http.Server
+}
+
+// Update replaces the routing tables, by socket. Sockets are created and
+// closed as needed, the others keep their connections.
+func (r *Router) Update(tables map[string][]Route) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	proxies := map[string]*httputil.ReverseProxy{}
+
+	for _, routes := range tables {
+		for _, route := range routes {
+			if proxy, ok := r.proxies[route.Target]; ok {
+				proxies[route.Target] = proxy
+
+				continue
+			}
+
+			proxies[route.Target] = httputil.NewSingleHostReverseProxy(&url.URL{
+				Scheme: "http",
+				Host:   route.Target,
+			})
+		}
+	}
+
+	r.tables = tables
+	r.proxies = proxies
+
+	if r.servers == nil {
+		r.servers = map[string]*http.Server{}
+	}
+
+	for socket, server := range r.servers {
+		if _, ok := tables[socket]; ok {
+			continue
+		}
+
+		log.Infof("closing HTTP router socket %s", socket)
+
+		err := server.Close()
+		if err != nil {
+			log.Errorf("error closing %s: %v", socket, err)
+		}
+
+		delete(r.servers, socket)
+	}
+
+	for socket := range tables {
+		if _, ok := r.servers[socket]; ok {
+			continue
+		}
+
+		server, err := r.listen(socket)
+		if err != nil {
+			return err
+		}
+
+		r.servers[socket] = server
+	}
+
+	return nil
+}
+
+func (r *Router) listen(socket string) (*http.Server, error) {
+	err := os.MkdirAll(path.Dir(socket), dirPermission)
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating %s", path.Dir(socket))
+	}
+
+	// Left by a previous run
+	err = os.Remove(socket)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "removing %s", socket)
+	}
+
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, errors.Wrapf(err, "listening on %s", socket)
+	}
+
+	server := &http.Server{
+		Handler:           r.handler(socket),
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	log.Infof("serving HTTP routes on %s", socket)
+
+	go func() {
+		err := server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("error serving %s: %v", socket, err)
+		}
+	}()
+
+	return server, nil
+}
+
+func (r *Router) handler(socket string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var proxy *httputil.ReverseProxy
+
+		r.mu.RLock()
+		route := match(r.tables[socket], req.Host, req.URL.Path)
+
+		if route != nil {
+			proxy = r.proxies[route.Target]
+		}
+		r.mu.RUnlock()
+
+		if proxy == nil {
+			http.NotFound(w, req)
+
+			return
+		}
+
+		proxy.ServeHTTP(w, req)
+	})
+}
+
+// match returns the route of the most specific host, then of the longest
+// path, exact paths winning over prefixes. Ties go to the first route.
+func match(routes []Route, host, requestPath string) *Route {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	host = strings.ToLower(host)
+
+	var (
+		best      *Route
+		bestScore []int
+	)
+
+	for i := range routes {
+		route := &routes[i]
+
+		hostScore := matchHost(route.Host, host)
+		if hostScore < 0 || !matchPath(route, requestPath) {
+			continue
+		}
+
+		exact := 0
+		if route.Exact {
+			exact = 1
+		}
+
+		score := []int{hostScore, len(route.Path), exact}
+		if best == nil || higher(score, bestScore) {
+			best, bestScore = route, score
+		}
+	}
+
+	return best
+}
+
+// matchHost returns how specific the match is: 2 for the same host, 1 for a
+// wildcard, 0 for any host, -1 when it doesn't match.
+func matchHost(pattern, host string) int {
+	pattern = strings.ToLower(pattern)
+
+	switch {
+	case pattern == "":
+		return 0
+	case pattern == host:
+		//nolint:gomnd // see above
+		return 2
+	case strings.HasPrefix(pattern, "*."):
+		// The wildcard matches a single label
+		label := strings.TrimSuffix(host, pattern[1:])
+		if label != host && label != "" && !strings.Contains(label, ".") {
+			return 1
+		}
+	}
+
+	return -1
+}
+
+func matchPath(route *Route, requestPath string) bool {
+	if route.Exact {
+		return requestPath == route.Path
+	}
+
+	prefix := strings.TrimSuffix(route.Path, "/")
+
+	return requestPath == prefix || strings.HasPrefix(requestPath, prefix+"/")
+}
+
+func higher(score, than []int) bool {
+	for i := range score {
+		if score[i] != than[i] {
+			return score[i] > than[i]
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,109 @@
+package router
+
+import (
+	"testing"
+)
+
+func TestMatchHost(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		host    string
+		want    int
+	}{
+		{"any host", "", "abc.onion", 0},
+		{"same host", "abc.onion", "abc.onion", 2},
+		{"same host, other case", "ABC.onion", "abc.onion", 2},
+		{"other host", "example.com", "abc.onion", -1},
+		{"wildcard", "*.abc.onion", "www.abc.onion", 1},
+		{"wildcard matches a single label", "*.abc.onion", "a.www.abc.onion", -1},
+		{"wildcard doesn't match the domain", "*.abc.onion", "abc.onion", -1},
+		{"wildcard of another domain", "*.example.com", "www.abc.onion", -1},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchHost(tt.pattern, tt.host); got != tt.want {
+				t.Errorf("matchHost(%q, %q) = %d, want %d", tt.pattern, tt.host, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		name  string
+		route Route
+		path  string
+		want  bool
+	}{
+		{"root prefix", Route{Path: "/"}, "/anything", true},
+		{"prefix", Route{Path: "/foo"}, "/foo", true},
+		{"prefix subpath", Route{Path: "/foo"}, "/foo/bar", true},
+		{"prefix with trailing slash", Route{Path: "/foo/"}, "/foo", true},
+		{"prefix is per path element", Route{Path: "/foo"}, "/foobar", false},
+		{"exact", Route{Path: "/foo", Exact: true}, "/foo", true},
+		{"exact subpath", Route{Path: "/foo", Exact: true}, "/foo/bar", false},
+		{"exact trailing slash", Route{Path: "/foo", Exact: true}, "/foo/", false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			route := tt.route
+			if got := matchPath(&route, tt.path); got != tt.want {
+				t.Errorf("matchPath(%+v, %q) = %v, want %v", tt.route, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	routes := []Route{
+		{Path: "/", Target: "default:80"},
+		{Path: "/foo", Target: "foo-prefix:80"},
+		{Path: "/foo", Exact: true, Target: "foo-exact:80"},
+		{Path: "/foobar", Target: "foobar:80"},
+		{Host: "*.abc.onion", Path: "/", Target: "wildcard:80"},
+		{Host: "api.abc.onion", Path: "/", Target: "api:80"},
+		{Host: "example.com", Path: "/", Target: "clearnet:80"},
+	}
+
+	tests := []struct {
+		name   string
+		routes []Route
+		host   string
+		path   string
+		want   string
+	}{
+		{"any host", routes, "abc.onion", "/", "default:80"},
+		{"host with port", routes, "abc.onion:80", "/", "default:80"},
+		{"exact wins over prefix", routes, "abc.onion", "/foo", "foo-exact:80"},
+		{"prefix subpath", routes, "abc.onion", "/foo/bar", "foo-prefix:80"},
+		{"longest prefix", routes, "abc.onion", "/foobar", "foobar:80"},
+		{"prefix is per path element", routes, "abc.onion", "/foobarbaz", "default:80"},
+		{"wildcard host wins over paths", routes, "www.abc.onion", "/foo", "wildcard:80"},
+		{"exact host wins over wildcard", routes, "api.abc.onion", "/", "api:80"},
+		{"clearnet host never matches the onion address", routes[6:], "abc.onion", "/", ""},
+		{"no route", routes[1:2], "abc.onion", "/bar", ""},
+		{"ties go to the first route", []Route{
+			{Path: "/", Target: "first:80"},
+			{Path: "/", Target: "second:80"},
+		}, "abc.onion", "/", "first:80"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if route := match(tt.routes, tt.host, tt.path); route != nil {
+				got = route.Target
+			}
+
+			if got != tt.want {
+				t.Errorf("match(%q, %q) = %q, want %q", tt.host, tt.path, got, tt.want)
+			}
+		})
+	}
+}
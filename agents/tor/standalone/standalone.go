@@ -0,0 +1,66 @@
+// Package standalone supervises a tor daemon running with a static config,
+// as in OnionBalancedService pods, and serves its health checks.
+package standalone
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	common "github.com/bugfest/tor-controller/agents/common"
+	health "github.com/bugfest/tor-controller/agents/tor/health"
+	tordaemon "github.com/bugfest/tor-controller/agents/tor/tordaemon"
+)
+
+// Main runs tor as defined in args:
+//
+//	standalone -f /run/tor/torfile -control 127.0.0.1:9051
+//
+// The control address can also be a unix socket: unix:/path/to/socket.
+func Main(args []string) error {
+	var torfile, controlAddr string
+
+	flags := flag.NewFlagSet("standalone", flag.ExitOnError)
+	flags.StringVar(&torfile, "f", tordaemon.DefaultTorfile, "Tor config file.")
+	flags.StringVar(&controlAddr, "control", "127.0.0.1:9051", "Tor control port address.")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return errors.Wrap(err, "error parsing flags")
+	}
+
+	daemon := tordaemon.Tor{Torfile: torfile}
+
+	// SIGINT/SIGTERM stop tor, SIGHUP makes it reload its config
+	ctx := common.SignalContext(daemon.Reload)
+	daemon.SetContext(ctx)
+	daemon.Start()
+
+	network, address := controlNetwork(controlAddr)
+	checker := &health.Checker{
+		Network: network,
+		Address: address,
+		Alive:   daemon.Running,
+	}
+
+	err = health.Serve(ctx, checker)
+	if err != nil {
+		return err
+	}
+
+	// Give the daemon a chance to shut down cleanly
+	daemon.Wait()
+
+	return nil
+}
+
+// controlNetwork splits the -control flag into the network and address to
+// dial: unix sockets are prefixed with unix:, anything else is TCP.
+func controlNetwork(controlAddr string) (string, string) {
+	if path := strings.TrimPrefix(controlAddr, "unix:"); path != controlAddr {
+		return "unix", path
+	}
+
+	return "tcp", controlAddr
+}
@@ -0,0 +1,28 @@
+package standalone
+
+import (
+	"testing"
+)
+
+func TestControlNetwork(t *testing.T) {
+	tests := []struct {
+		controlAddr string
+		network     string
+		address     string
+	}{
+		{"127.0.0.1:9051", "tcp", "127.0.0.1:9051"},
+		{"[::1]:9051", "tcp", "[::1]:9051"},
+		{"unix:/run/tor/control/control.sock", "unix", "/run/tor/control/control.sock"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.controlAddr, func(t *testing.T) {
+			network, address := controlNetwork(tt.controlAddr)
+			if network != tt.network || address != tt.address {
+				t.Errorf("controlNetwork(%q) = %q, %q, want %q, %q",
+					tt.controlAddr, network, address, tt.network, tt.address)
+			}
+		})
+	}
+}
@@ -2,17 +2,22 @@ package tordaemon
 
 import (
 	"context"
-	"os"
-	"os/exec"
 	"syscall"
-	"time"
 
 	log "github.com/sirupsen/logrus"
+
+	common "github.com/bugfest/tor-controller/agents/common"
 )
 
+// DefaultTorfile is the config tor runs with unless Torfile is set.
+const DefaultTorfile = "/run/tor/torfile"
+
 type Tor struct {
-	cmd *exec.Cmd
-	ctx context.Context
+	// Torfile is the path of tor's config
+	Torfile string
+
+	process common.Process
+	ctx     context.Context
 }
 
 func (t *Tor) SetContext(ctx context.Context) {
@@ -20,47 +25,42 @@ func (t *Tor) SetContext(ctx context.Context) {
 }
 
 func (t *Tor) Start() {
-	go func() {
-		for {
-			log.Println("starting tor...")
-
-			t.cmd = exec.CommandContext(t.ctx,
-				"tor",
-				"-f", "/run/tor/torfile",
-				// "--allow-missing-torrc",
-			)
-
-			t.cmd.Stdout = os.Stdout
-			t.cmd.Stderr = os.Stderr
-
-			err := t.cmd.Start()
-			if err != nil {
-				log.Print(err)
-			}
-
-			err = t.cmd.Wait()
-			if err != nil {
-				log.Print(err)
-			}
+	torfile := t.Torfile
+	if torfile == "" {
+		torfile = DefaultTorfile
+	}
 
-			//nolint:gomnd // 3 seconds
-			time.Sleep(time.Second * 3)
-		}
-	}()
+	t.process.Start(t.ctx,
+		"tor",
+		"-f", torfile,
+		// "--allow-missing-torrc",
+	)
 }
 
 func (t *Tor) Reload() {
-	if t.cmd == nil || (t.cmd.ProcessState != nil && t.cmd.ProcessState.Exited()) {
+	if !t.process.Running() {
 		// tor is not running
 		t.Start()
-	} else {
-		// restart if already running
-		log.Println("reloading tor...")
-		// https://manpages.debian.org/testing/tor/tor.1.en.html#SIGNALS
-		// SIGHUP tells tor to reload the config
-		err := t.cmd.Process.Signal(syscall.SIGHUP)
-		if err != nil {
-			log.Print("error sending SIGHUP to tor: ", err)
-		}
+
+		return
+	}
+
+	log.Println("reloading tor...")
+	// https://manpages.debian.org/testing/tor/tor.1.en.html#SIGNALS
+	// SIGHUP tells tor to reload the config
+	err := t.process.Signal(syscall.SIGHUP)
+	if err != nil {
+		log.Print("error sending SIGHUP to tor: ", err)
 	}
 }
+
+// Running reports whether tor is running.
+func (t *Tor) Running() bool {
+	return t.process.Running()
+}
+
+// Wait blocks until tor stopped, once the context is cancelled. Tor gets
+// SIGINT first, so onion services shut down cleanly.
+func (t *Tor) Wait() {
+	t.process.Wait()
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package v2
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	cfg "sigs.k8s.io/controller-runtime/pkg/config/v1alpha1"
 )
@@ -50,24 +51,167 @@ type ProjectConfig struct {
 
 	// +optional
 	Namespace string `json:"namespace,omitempty"`
+
+	// Namespaces watched by the controller, along with Namespace. The
+	// controller watches the whole cluster when no namespace is set.
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// NamespaceSelector adds the namespaces matching it to the watched
+	// namespaces. They are resolved when the controller starts.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// MaxConcurrentReconciles of each controller. Unset ones use the
+	// controller groupKindConcurrency, or 1.
+	// +optional
+	MaxConcurrentReconciles ControllerConcurrency `json:"maxConcurrentReconciles,omitempty"`
+
+	// Sharding splits the resources between several controller replicas.
+	// +optional
+	Sharding *ShardingConfig `json:"sharding,omitempty"`
+
+	// APIServerCIDRs are the addresses of the API server the tor agents may
+	// reach. The kubernetes Service endpoints are read when empty.
+	// +optional
+	APIServerCIDRs []string `json:"apiServerCIDRs,omitempty"`
+}
+
+// ControllerConcurrency is the number of resources each controller can
+// reconcile at once.
+type ControllerConcurrency struct {
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	OnionService int `json:"onionService,omitempty"`
+
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	OnionBalancedService int `json:"onionBalancedService,omitempty"`
+
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	Tor int `json:"tor,omitempty"`
+
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	OnionEndpoint int `json:"onionEndpoint,omitempty"`
+
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	Ingress int `json:"ingress,omitempty"`
+
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	Gateway int `json:"gateway,omitempty"`
+
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	OnionLocation int `json:"onionLocation,omitempty"`
+}
+
+// ShardKey is the part of a resource hashed to pick its shard.
+type ShardKey string
+
+const (
+	// ShardKeyNamespace keeps all the resources of a namespace in a shard.
+	ShardKeyNamespace ShardKey = "Namespace"
+	// ShardKeyName spreads the resources of a namespace across shards.
+	ShardKeyName ShardKey = "Name"
+)
+
+// ShardingConfig assigns every resource to one of Shards shards, by the
+// tor.k8s.torproject.org/shard label when set or by the hash of its Key.
+// Each shard has its own leader election.
+type ShardingConfig struct {
+	// Shards is the number of shards the resources are split into
+	// +kubebuilder:validation:Minimum=1
+	Shards int `json:"shards"`
+
+	// Shard reconciled by this replica, overridden by the --shard flag
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	Shard int `json:"shard,omitempty"`
+
+	// Key hashed to assign the resources to shards
+	// +optional
+	// +kubebuilder:validation:Enum=Namespace;Name
+	// +kubebuilder:default:=Namespace
+	Key ShardKey `json:"key,omitempty"`
 }
 
 type TorDaemonType struct {
 	// +optional
 	// +kubebuilder:default:="quay.io/bugfest/tor-daemon:latest"
 	Image string `json:"image,omitempty"`
+
+	// Digest pins the image, e.g. sha256:4a1c...
+	// +optional
+	Digest string `json:"digest,omitempty"`
+
+	// ImagePullPolicy defaults to IfNotPresent for digests, Always otherwise
+	// +optional
+	// +kubebuilder:validation:Enum=Always;IfNotPresent;Never
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
+
+	ComponentDefaults `json:",inline"`
 }
 
 type TorDaemonManagerType struct {
 	// +optional
 	// +kubebuilder:default:="quay.io/bugfest/tor-daemon-manager:latest"
 	Image string `json:"image,omitempty"`
+
+	// Digest pins the image, e.g. sha256:4a1c...
+	// +optional
+	Digest string `json:"digest,omitempty"`
+
+	// ImagePullPolicy defaults to IfNotPresent for digests, Always otherwise
+	// +optional
+	// +kubebuilder:validation:Enum=Always;IfNotPresent;Never
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
+
+	ComponentDefaults `json:",inline"`
 }
 
 type TorOnionbalanceManagerType struct {
 	// +optional
 	// +kubebuilder:default:="quay.io/bugfest/tor-onionbalance-manager:latest"
 	Image string `json:"image,omitempty"`
+
+	// Digest pins the image, e.g. sha256:4a1c...
+	// +optional
+	Digest string `json:"digest,omitempty"`
+
+	// ImagePullPolicy defaults to IfNotPresent for digests, Always otherwise
+	// +optional
+	// +kubebuilder:validation:Enum=Always;IfNotPresent;Never
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
+
+	ComponentDefaults `json:",inline"`
+}
+
+// ComponentDefaults are applied to the containers of a component and to the
+// pods running them, unless the resource's pod template sets them.
+type ComponentDefaults struct {
+	// Resources of the component containers
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// SecurityContext fields override the restricted profile defaults
+	// +optional
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// +optional
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
 }
 
 // // +kubebuilder:object:root=true
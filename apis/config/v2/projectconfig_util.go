@@ -0,0 +1,57 @@
+package v2
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ImageRef returns the tor daemon image, pinned by digest when set.
+func (t *TorDaemonType) ImageRef() string {
+	return imageRef(t.Image, t.Digest)
+}
+
+// PullPolicy returns the pull policy of the tor daemon image.
+func (t *TorDaemonType) PullPolicy() corev1.PullPolicy {
+	return pullPolicy(t.ImagePullPolicy, t.Digest)
+}
+
+// ImageRef returns the tor daemon manager image, pinned by digest when set.
+func (t *TorDaemonManagerType) ImageRef() string {
+	return imageRef(t.Image, t.Digest)
+}
+
+// PullPolicy returns the pull policy of the tor daemon manager image.
+func (t *TorDaemonManagerType) PullPolicy() corev1.PullPolicy {
+	return pullPolicy(t.ImagePullPolicy, t.Digest)
+}
+
+// ImageRef returns the onionbalance manager image, pinned by digest when set.
+func (t *TorOnionbalanceManagerType) ImageRef() string {
+	return imageRef(t.Image, t.Digest)
+}
+
+// PullPolicy returns the pull policy of the onionbalance manager image.
+func (t *TorOnionbalanceManagerType) PullPolicy() corev1.PullPolicy {
+	return pullPolicy(t.ImagePullPolicy, t.Digest)
+}
+
+func imageRef(image, digest string) string {
+	if digest == "" || strings.Contains(image, "@") {
+		return image
+	}
+
+	return image + "@" + digest
+}
+
+func pullPolicy(policy corev1.PullPolicy, digest string) corev1.PullPolicy {
+	switch {
+	case policy != "":
+		return policy
+	case digest != "":
+		// a digest always resolves to the same image
+		return corev1.PullIfNotPresent
+	default:
+		return corev1.PullAlways
+	}
+}
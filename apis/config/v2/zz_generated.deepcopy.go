@@ -22,17 +22,95 @@ limitations under the License.
 package v2
 
 import (
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ComponentDefaults) DeepCopyInto(out *ComponentDefaults) {
+	*out = *in
+	in.Resources.DeepCopyInto(&out.Resources)
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(v1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]v1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ImagePullSecrets != nil {
+		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
+		*out = make([]v1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ComponentDefaults.
+func (in *ComponentDefaults) DeepCopy() *ComponentDefaults {
+	if in == nil {
+		return nil
+	}
+	out := new(ComponentDefaults)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ControllerConcurrency) DeepCopyInto(out *ControllerConcurrency) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ControllerConcurrency.
+func (in *ControllerConcurrency) DeepCopy() *ControllerConcurrency {
+	if in == nil {
+		return nil
+	}
+	out := new(ControllerConcurrency)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ProjectConfig) DeepCopyInto(out *ProjectConfig) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ControllerManagerConfigurationSpec.DeepCopyInto(&out.ControllerManagerConfigurationSpec)
-	out.TorDaemon = in.TorDaemon
-	out.TorDaemonManager = in.TorDaemonManager
-	out.TorOnionbalanceManager = in.TorOnionbalanceManager
+	in.TorDaemon.DeepCopyInto(&out.TorDaemon)
+	in.TorDaemonManager.DeepCopyInto(&out.TorDaemonManager)
+	in.TorOnionbalanceManager.DeepCopyInto(&out.TorOnionbalanceManager)
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	out.MaxConcurrentReconciles = in.MaxConcurrentReconciles
+	if in.Sharding != nil {
+		in, out := &in.Sharding, &out.Sharding
+		*out = new(ShardingConfig)
+		**out = **in
+	}
+	if in.APIServerCIDRs != nil {
+		in, out := &in.APIServerCIDRs, &out.APIServerCIDRs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProjectConfig.
@@ -53,9 +131,25 @@ func (in *ProjectConfig) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ShardingConfig) DeepCopyInto(out *ShardingConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ShardingConfig.
+func (in *ShardingConfig) DeepCopy() *ShardingConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ShardingConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TorDaemonManagerType) DeepCopyInto(out *TorDaemonManagerType) {
 	*out = *in
+	in.ComponentDefaults.DeepCopyInto(&out.ComponentDefaults)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TorDaemonManagerType.
@@ -71,6 +165,7 @@ func (in *TorDaemonManagerType) DeepCopy() *TorDaemonManagerType {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TorDaemonType) DeepCopyInto(out *TorDaemonType) {
 	*out = *in
+	in.ComponentDefaults.DeepCopyInto(&out.ComponentDefaults)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TorDaemonType.
@@ -86,6 +181,7 @@ func (in *TorDaemonType) DeepCopy() *TorDaemonType {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TorOnionbalanceManagerType) DeepCopyInto(out *TorOnionbalanceManagerType) {
 	*out = *in
+	in.ComponentDefaults.DeepCopyInto(&out.ComponentDefaults)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TorOnionbalanceManagerType.
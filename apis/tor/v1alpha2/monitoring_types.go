@@ -0,0 +1,71 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+)
+
+// MonitorKind is the kind of Prometheus Operator monitor scraping tor pods.
+// +kubebuilder:validation:Enum=ServiceMonitor;PodMonitor
+type MonitorKind string
+
+const (
+	// MonitorKindServiceMonitor scrapes the pods through the metrics Service.
+	MonitorKindServiceMonitor MonitorKind = "ServiceMonitor"
+
+	// MonitorKindPodMonitor scrapes every pod directly, which helps with
+	// multi-replica Tor deployments.
+	MonitorKindPodMonitor MonitorKind = "PodMonitor"
+)
+
+// MonitoringSpec configures the Prometheus Operator monitor created for the
+// tor pods.
+type MonitoringSpec struct {
+	// Create the monitor.
+	// +optional
+	// +kubebuilder:default:=false
+	Enable bool `json:"enable,omitempty"`
+
+	// Kind of monitor to create.
+	// +optional
+	// +kubebuilder:default:=ServiceMonitor
+	Kind MonitorKind `json:"kind,omitempty"`
+
+	// Extra labels of the monitor, like the release label Prometheus selects
+	// by.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Interval at which metrics are scraped. Prometheus' default if empty.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^(0|(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?)$`
+	Interval string `json:"interval,omitempty"`
+
+	// Timeout after which the scrape is ended. Prometheus' default if empty.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^(0|(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?)$`
+	ScrapeTimeout string `json:"scrapeTimeout,omitempty"`
+
+	// Relabelings applied to the targets before scraping.
+	// +optional
+	Relabelings []monitoringv1.RelabelConfig `json:"relabelings,omitempty"`
+
+	// Relabelings applied to the samples before ingestion.
+	// +optional
+	MetricRelabelings []monitoringv1.RelabelConfig `json:"metricRelabelings,omitempty"`
+}
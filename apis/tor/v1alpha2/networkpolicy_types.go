@@ -0,0 +1,47 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NetworkPolicySpec restricts the traffic of the tor pods. Egress is allowed
+// to DNS, the internet (public addresses) and the backends; ingress to the
+// metrics ports from the selected namespaces.
+type NetworkPolicySpec struct {
+	// Create a NetworkPolicy for the tor pods.
+	// +optional
+	// +kubebuilder:default:=false
+	Enable bool `json:"enable,omitempty"`
+
+	// Namespaces allowed to scrape the metrics ports, e.g: Prometheus'
+	// namespace. Scraping is denied when unset; an empty selector allows all
+	// namespaces.
+	// +optional
+	MetricsNamespaceSelector *metav1.LabelSelector `json:"metricsNamespaceSelector,omitempty"`
+
+	// Ingress rules added to the generated ones.
+	// +optional
+	ExtraIngress []networkingv1.NetworkPolicyIngressRule `json:"extraIngress,omitempty"`
+
+	// Egress rules added to the generated ones, e.g: to reach the Kubernetes
+	// API server if it has a private address.
+	// +optional
+	ExtraEgress []networkingv1.NetworkPolicyEgressRule `json:"extraEgress,omitempty"`
+}
@@ -41,10 +41,19 @@ type OnionBalancedServiceSpec struct {
 	// +kubebuilder:default:=3
 	Version int32 `json:"version"`
 
+	// Create a ServiceMonitor with default settings, see monitoring.
 	// +optional
 	// +kubebuilder:default:=false
 	ServiceMonitor bool `json:"serviceMonitor,omitempty"`
 
+	// Prometheus Operator monitor scraping the tor pods.
+	// +optional
+	Monitoring MonitoringSpec `json:"monitoring,omitempty"`
+
+	// NetworkPolicy restricting the traffic of the tor pods.
+	// +optional
+	NetworkPolicy NetworkPolicySpec `json:"networkPolicy,omitempty"`
+
 	// +optional
 	Template TemplateReference `json:"template,omitempty"`
 
@@ -12,6 +12,7 @@ const (
 	onionbalanceServiceNameFmt        = "%s-tor-svc"
 	onionbalanceRoleNameFmt           = "%s-tor-role"
 	onionbalanceServiceAccountNameFmt = "%s-tor-sa"
+	onionbalanceNetworkPolicyNameFmt  = "%s-tor-network-policy"
 	onionbalanceConfigMapFmt          = "%s-tor-config"
 )
 
@@ -68,6 +69,11 @@ func (s *OnionBalancedService) ServiceMetricsSelector() map[string]string {
 	}
 }
 
+// Monitoring returns the settings of the monitor scraping the tor pods.
+func (s *OnionBalancedService) Monitoring() *MonitoringSpec {
+	return monitoringSpec(s.Spec.ServiceMonitor, &s.Spec.Monitoring)
+}
+
 func (s *OnionBalancedService) DeploymentLabels() map[string]string {
 	return s.ServiceSelector()
 }
@@ -80,6 +86,10 @@ func (s *OnionBalancedService) ServiceAccountName() string {
 	return fmt.Sprintf(osServiceAccountNameFmt, s.Name)
 }
 
+func (s *OnionBalancedService) NetworkPolicyName() string {
+	return fmt.Sprintf(onionbalanceNetworkPolicyNameFmt, s.Name)
+}
+
 func (s *OnionBalancedService) IsSynced() bool {
 	// All backends must exist
 	if len(s.Status.Backends) != s.Spec.GetBackends() {
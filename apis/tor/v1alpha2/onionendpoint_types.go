@@ -0,0 +1,100 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// OnionEndpointSpec defines the desired state of OnionEndpoint.
+type OnionEndpointSpec struct {
+	// Onion address of the remote service, e.g: abcdef...xyz.onion
+	// +kubebuilder:validation:Pattern=`^[a-z2-7]{56}\.onion$`
+	Address string `json:"address"`
+
+	// Ports exposed by the in-cluster service.
+	// +kubebuilder:validation:MinItems:=1
+	Ports []OnionEndpointPort `json:"ports"`
+
+	// Reference to a key of a secret containing the client authorization
+	// x25519 private key (base32) for the remote onion service. The key
+	// defaults to privateKey.
+	// +optional
+	ClientAuthKeySecret SecretReference `json:"clientAuthKeySecret,omitempty"`
+
+	// Template describes the pods of the managed Tor client.
+	// +optional
+	Template TorPodTemplate `json:"template,omitempty"`
+}
+
+// OnionEndpointPort maps a port of the in-cluster service to a port of the
+// remote onion service.
+type OnionEndpointPort struct {
+	// Name of the in-cluster service port. Defaults to port-<port>.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Port exposed by the in-cluster service.
+	// +kubebuilder:validation:Minimum:=1
+	// +kubebuilder:validation:Maximum:=65535
+	Port int32 `json:"port"`
+
+	// Port of the remote onion service. Defaults to port.
+	// +optional
+	// +kubebuilder:validation:Minimum:=1
+	// +kubebuilder:validation:Maximum:=65535
+	TargetPort int32 `json:"targetPort,omitempty"`
+}
+
+// OnionEndpointStatus defines the observed state of OnionEndpoint.
+type OnionEndpointStatus struct {
+	// +optional
+	ServiceName string `json:"serviceName,omitempty"`
+
+	// +optional
+	ClusterIP string `json:"clusterIP,omitempty"`
+}
+
+// +kubebuilder:resource:shortName={"onionep","oep"}
+// +kubebuilder:storageversion
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Address",type=string,JSONPath=`.spec.address`
+// +kubebuilder:printcolumn:name="ClusterIP",type=string,JSONPath=`.status.clusterIP`
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// OnionEndpoint is the Schema for the onionendpoints API.
+type OnionEndpoint struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   OnionEndpointSpec   `json:"spec,omitempty"`
+	Status OnionEndpointStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// OnionEndpointList contains a list of OnionEndpoint.
+type OnionEndpointList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []OnionEndpoint `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&OnionEndpoint{}, &OnionEndpointList{})
+}
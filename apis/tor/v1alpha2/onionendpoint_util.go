@@ -0,0 +1,53 @@
+package v1alpha2
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	oepTorNameFmt  = "%s-onion-endpoint"
+	oepPortNameFmt = "port-%d"
+
+	// OnionEndpointAuthDir is where the client authorization keys are
+	// written for tor's ClientOnionAuthDir, inside tor's data volume.
+	OnionEndpointAuthDir = "/var/lib/tor/onion-auth"
+)
+
+// TorName is the name of the Tor client managed for this endpoint.
+func (s *OnionEndpoint) TorName() string {
+	return fmt.Sprintf(oepTorNameFmt, s.Name)
+}
+
+// ServiceName is the name of the in-cluster service: workloads reach the
+// onion service at <name>.<namespace>.svc.
+func (s *OnionEndpoint) ServiceName() string {
+	return s.Name
+}
+
+// ServiceSelector selects the pods of the managed Tor client.
+func (s *OnionEndpoint) ServiceSelector() map[string]string {
+	tor := Tor{ObjectMeta: metav1.ObjectMeta{Name: s.TorName()}}
+
+	return tor.DeploymentLabels()
+}
+
+// GetTargetPort returns the port of the remote onion service.
+func (p *OnionEndpointPort) GetTargetPort() int32 {
+	if p.TargetPort == 0 {
+		return p.Port
+	}
+
+	return p.TargetPort
+}
+
+// GetName returns the name of the in-cluster service port, defaulting to
+// port-<port> as services with several ports require named ports.
+func (p *OnionEndpointPort) GetName() string {
+	if p.Name == "" {
+		return fmt.Sprintf(oepPortNameFmt, p.Port)
+	}
+
+	return p.Name
+}
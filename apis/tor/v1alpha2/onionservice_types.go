@@ -63,12 +63,139 @@ type OnionServiceSpec struct {
 	// +optional
 	MasterOnionAddress string `json:"masterOnionAddress,omitempty"`
 
+	// Create a ServiceMonitor with default settings, see monitoring.
 	// +optional
 	// +kubebuilder:default:=false
 	ServiceMonitor bool `json:"serviceMonitor,omitempty"`
 
+	// Prometheus Operator monitor scraping the tor pods.
+	// +optional
+	Monitoring MonitoringSpec `json:"monitoring,omitempty"`
+
+	// NetworkPolicy restricting the traffic of the tor pods.
+	// +optional
+	NetworkPolicy NetworkPolicySpec `json:"networkPolicy,omitempty"`
+
 	// +optional
 	ExtraConfig string `json:"extraConfig,omitempty"`
+
+	// Vanguards protects the onion service against guard discovery attacks.
+	// +optional
+	Vanguards VanguardsSpec `json:"vanguards,omitempty"`
+
+	// Publish writes the onion address where the apps that can't read
+	// OnionServices find it.
+	// +optional
+	Publish PublishSpec `json:"publish,omitempty"`
+}
+
+// VanguardsMode selects how vanguards are provided.
+// +kubebuilder:validation:Enum=Lite;Full
+type VanguardsMode string
+
+const (
+	// VanguardsLite uses tor's built-in vanguards-lite (VanguardsLiteEnabled).
+	VanguardsLite VanguardsMode = "Lite"
+
+	// VanguardsFull runs the vanguards add-on as a sidecar.
+	VanguardsFull VanguardsMode = "Full"
+)
+
+type VanguardsSpec struct {
+	// +optional
+	// +kubebuilder:default:=false
+	Enable bool `json:"enable,omitempty"`
+
+	// Lite renders tor's built-in VanguardsLiteEnabled option. Full runs the
+	// vanguards add-on next to tor, talking to it through a local control
+	// socket.
+	// +optional
+	// +kubebuilder:default:=Lite
+	Mode VanguardsMode `json:"mode,omitempty"`
+
+	// Number of layer2 guards (Full mode only).
+	// +optional
+	// +kubebuilder:validation:Minimum:=1
+	Layer2Size int32 `json:"layer2Size,omitempty"`
+
+	// Number of layer3 guards (Full mode only).
+	// +optional
+	// +kubebuilder:validation:Minimum:=1
+	Layer3Size int32 `json:"layer3Size,omitempty"`
+
+	// Lifetime of the layer2 and layer3 guards (Full mode only).
+	// +optional
+	Rotation VanguardsRotationSpec `json:"rotation,omitempty"`
+}
+
+// VanguardsRotationSpec bounds the guards lifetime, rounded to hours. The
+// add-on defaults are used for unset values.
+type VanguardsRotationSpec struct {
+	// +optional
+	MinLayer2Lifetime *metav1.Duration `json:"minLayer2Lifetime,omitempty"`
+
+	// +optional
+	MaxLayer2Lifetime *metav1.Duration `json:"maxLayer2Lifetime,omitempty"`
+
+	// +optional
+	MinLayer3Lifetime *metav1.Duration `json:"minLayer3Lifetime,omitempty"`
+
+	// +optional
+	MaxLayer3Lifetime *metav1.Duration `json:"maxLayer3Lifetime,omitempty"`
+}
+
+// PublishSpec lists where the onion address is written once known.
+type PublishSpec struct {
+	// Targets are the ConfigMaps and Secrets the onion address is written
+	// to. Targets in other namespaces must be allowed by a
+	// TorReferenceGrant there.
+	// +optional
+	Targets []PublishTarget `json:"targets,omitempty"`
+
+	// DNSEndpoint has ExternalDNS publish the onion address in a TXT
+	// record.
+	// +optional
+	DNSEndpoint *PublishDNSEndpoint `json:"dnsEndpoint,omitempty"`
+}
+
+// PublishTarget is a ConfigMap or Secret holding the onion address in its
+// hostname key. It is created when missing, the other keys are kept.
+type PublishTarget struct {
+	// +optional
+	// +kubebuilder:validation:Enum=ConfigMap;Secret
+	// +kubebuilder:default:=ConfigMap
+	Kind string `json:"kind,omitempty"`
+
+	Name string `json:"name"`
+
+	// Namespace of the target. Defaults to the OnionService namespace
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// KeyPrefix is prepended to the written keys, so several OnionServices
+	// can share a target
+	// +optional
+	KeyPrefix string `json:"keyPrefix,omitempty"`
+
+	// PublicKey also writes the hs_ed25519_public_key of the onion service
+	// +optional
+	PublicKey bool `json:"publicKey,omitempty"`
+
+	// AuthorizedClients also writes the client-<index>.auth_private files
+	// of the authorized clients whose Secret holds a privateKey. Secrets
+	// only
+	// +optional
+	AuthorizedClients bool `json:"authorizedClients,omitempty"`
+}
+
+// PublishDNSEndpoint is an ExternalDNS DNSEndpoint, named after the
+// OnionService, with a TXT record holding onion-location=http://<hostname>.
+type PublishDNSEndpoint struct {
+	// DNSName of the TXT record, e.g: example.com
+	DNSName string `json:"dnsName"`
+
+	// +optional
+	RecordTTL int64 `json:"recordTTL,omitempty"`
 }
 
 type ServiceRule struct {
@@ -76,7 +203,61 @@ type ServiceRule struct {
 	Port networkingv1.ServiceBackendPort `json:"port,omitempty"`
 
 	// Backend selector
-	Backend networkingv1.IngressBackend `json:"backend,omitempty"`
+	Backend OnionServiceBackend `json:"backend,omitempty"`
+
+	// HTTP routes the requests to this port by host and path, through a
+	// reverse proxy run by the tor agent. Backend is ignored when set
+	// +optional
+	HTTP []OnionServiceHTTPRoute `json:"http,omitempty"`
+}
+
+// OnionServiceHTTPRoute sends the HTTP requests matching a host and a path to
+// a backend Service. The most specific host, then the longest path win.
+type OnionServiceHTTPRoute struct {
+	// Host header to match, the first label may be a "*" wildcard. Any host
+	// matches when empty
+	// +optional
+	Host string `json:"host,omitempty"`
+
+	// Path to match, defaults to /
+	// +optional
+	// +kubebuilder:validation:Pattern=`^/`
+	Path string `json:"path,omitempty"`
+
+	// PathType is Exact, or Prefix to match the path elements
+	// +optional
+	// +kubebuilder:validation:Enum=Exact;Prefix
+	// +kubebuilder:default:=Prefix
+	PathType networkingv1.PathType `json:"pathType,omitempty"`
+
+	// Backend Service of the route
+	Backend OnionServiceBackend `json:"backend"`
+}
+
+// OnionServiceBackend is an IngressBackend whose Service may live in another
+// namespace. A TorReferenceGrant in that namespace must allow it.
+type OnionServiceBackend struct {
+	networkingv1.IngressBackend `json:",inline"`
+
+	// Namespace of the backend Service. Defaults to the OnionService namespace
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Unix is a socket served by a container of the pod template, used
+	// instead of a Service
+	// +optional
+	Unix *UnixSocketBackend `json:"unix,omitempty"`
+}
+
+// UnixSocketBackend is a unix socket in a volume shared by tor and a
+// container of the pod. Both mount it at /run/onion-sockets.
+type UnixSocketBackend struct {
+	// Container of spec.template listening on the socket
+	Container string `json:"container"`
+
+	// Path of the socket, relative to the shared volume
+	// +kubebuilder:validation:Pattern=`^[^/]`
+	Path string `json:"path"`
 }
 
 type ServicePort struct {
@@ -113,6 +294,64 @@ type OnionServiceStatus struct {
 
 	// +optional
 	TargetClusterIP string `json:"targetClusterIP,omitempty"`
+
+	// Targets are the resolved backend ports of the rules, in the same
+	// order, rendered as HiddenServicePort by the tor agent
+	// +optional
+	Targets []OnionServiceTarget `json:"targets,omitempty"`
+
+	// Published are the resources holding the onion address, and the keys
+	// written to them
+	// +optional
+	Published []PublishedTarget `json:"published,omitempty"`
+
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// PublishedTarget is a resource the onion address was written to.
+type PublishedTarget struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+
+	// +optional
+	Keys []string `json:"keys,omitempty"`
+}
+
+// OnionServiceTarget is the backend port tor forwards the connections to a
+// public port to.
+type OnionServiceTarget struct {
+	PublicPort int32 `json:"publicPort"`
+
+	// Host of the backend Service, without the cluster domain the tor agent
+	// appends
+	// +optional
+	Host string `json:"host,omitempty"`
+
+	// Port of the backend Service, or of its endpoints for headless Services
+	// +optional
+	Port int32 `json:"port,omitempty"`
+
+	// Unix socket of the backend
+	// +optional
+	Unix string `json:"unix,omitempty"`
+
+	// Routes are the resolved backends of the HTTP routes, in the same order
+	// +optional
+	Routes []OnionServiceRouteTarget `json:"routes,omitempty"`
+}
+
+// OnionServiceRouteTarget is the backend an HTTP route forwards the requests
+// to.
+type OnionServiceRouteTarget struct {
+	// Host of the backend Service, without the cluster domain
+	Host string `json:"host"`
+
+	// Port of the backend Service, or of its endpoints for headless Services
+	Port int32 `json:"port"`
 }
 
 // +kubebuilder:resource:shortName={"onion","os"}
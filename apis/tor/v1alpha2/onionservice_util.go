@@ -2,6 +2,7 @@ package v1alpha2
 
 import (
 	"fmt"
+	"path"
 
 	corev1 "k8s.io/api/core/v1"
 )
@@ -14,7 +15,32 @@ const (
 	osMetricsServiceNameFmt          = "%s-tor-metrics-svc"
 	osRoleNameFmt                    = "%s-tor-role"
 	osServiceAccountNameFmt          = "%s-tor-sa"
+	osNetworkPolicyNameFmt           = "%s-tor-network-policy"
 	osServiceBackendNameFmt          = "%s-tor-obb-%d"
+
+	// OnionServiceVanguardsConfigured is the status condition telling
+	// whether vanguards are configured in the generated tor deployment.
+	OnionServiceVanguardsConfigured = "VanguardsConfigured"
+
+	// OnionServiceVanguardsActive is the status condition set by the tor
+	// agent once tor reports the configured vanguards in effect.
+	OnionServiceVanguardsActive = "VanguardsActive"
+
+	// OnionServiceConfigValid is the status condition set by the tor agent
+	// once tor verified the generated config.
+	OnionServiceConfigValid = "TorConfigValid"
+
+	// OnionServiceResolvedRefs is the status condition telling whether the
+	// backend Services of the rules exist and may be referenced.
+	OnionServiceResolvedRefs = "ResolvedRefs"
+
+	// OnionServicePublished is the status condition telling whether the
+	// onion address is written to the publish targets.
+	OnionServicePublished = "Published"
+
+	// UnixSocketsDir is the volume shared by tor and the containers serving
+	// unix socket backends.
+	UnixSocketsDir = "/run/onion-sockets"
 )
 
 func (s *OnionServiceSpec) GetVersion() int {
@@ -49,6 +75,11 @@ func (s *OnionService) ServiceMetricsSelector() map[string]string {
 	}
 }
 
+// Monitoring returns the settings of the monitor scraping the tor pods.
+func (s *OnionService) Monitoring() *MonitoringSpec {
+	return monitoringSpec(s.Spec.ServiceMonitor, &s.Spec.Monitoring)
+}
+
 func (s *OnionService) SecretName() string {
 	if len(s.Spec.PrivateKeySecret.Name) > 0 {
 		return s.Spec.PrivateKeySecret.Name
@@ -82,6 +113,10 @@ func (s *OnionService) ServiceAccountName() string {
 	return fmt.Sprintf(osServiceAccountNameFmt, s.Name)
 }
 
+func (s *OnionService) NetworkPolicyName() string {
+	return fmt.Sprintf(osNetworkPolicyNameFmt, s.Name)
+}
+
 func (s *OnionService) PodTemplate() corev1.PodTemplateSpec {
 	return corev1.PodTemplateSpec{
 		ObjectMeta: s.Spec.Template.ObjectMeta,
@@ -92,3 +127,59 @@ func (s *OnionService) PodTemplate() corev1.PodTemplateSpec {
 func (s *OnionService) Resources() corev1.ResourceRequirements {
 	return s.Spec.Template.Resources
 }
+
+// ServiceNamespace returns the namespace of the backend Service, namespace
+// being the one of the OnionService.
+func (b *OnionServiceBackend) ServiceNamespace(namespace string) string {
+	if b.Namespace != "" {
+		return b.Namespace
+	}
+
+	return namespace
+}
+
+// UnixSocketPath returns the path of the unix socket backend, or an empty
+// string for Service backends.
+func (b *OnionServiceBackend) UnixSocketPath() string {
+	if b.Unix == nil {
+		return ""
+	}
+
+	return path.Join(UnixSocketsDir, path.Clean("/"+b.Unix.Path))
+}
+
+// ServiceHost returns the name of the backend Service in the cluster,
+// <name>.<namespace>.svc, namespace being the one of the OnionService.
+func (b *OnionServiceBackend) ServiceHost(namespace string) string {
+	if b.Service == nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%s.%s.svc", b.Service.Name, b.ServiceNamespace(namespace))
+}
+
+// Backends returns the backend of the rule, or the backends of its HTTP
+// routes.
+func (r *ServiceRule) Backends() []*OnionServiceBackend {
+	if len(r.HTTP) == 0 {
+		return []*OnionServiceBackend{&r.Backend}
+	}
+
+	backends := make([]*OnionServiceBackend, 0, len(r.HTTP))
+
+	for i := range r.HTTP {
+		backends = append(backends, &r.HTTP[i].Backend)
+	}
+
+	return backends
+}
+
+// LiteEnabled reports whether tor's built-in vanguards-lite must be enabled.
+func (s *VanguardsSpec) LiteEnabled() bool {
+	return s.Enable && s.Mode != VanguardsFull
+}
+
+// FullEnabled reports whether the vanguards add-on must run next to tor.
+func (s *VanguardsSpec) FullEnabled() bool {
+	return s.Enable && s.Mode == VanguardsFull
+}
@@ -66,11 +66,19 @@ type TorSpec struct {
 	// +optional
 	Metrics TorGenericPortWithFlagSpec `json:"metrics,omitempty"`
 
-	// Create service monitor.
+	// Create a ServiceMonitor with default settings, see monitoring.
 	// +optional
 	// +kubebuilder:default:=false
 	ServiceMonitor bool `json:"serviceMonitor,omitempty"`
 
+	// Prometheus Operator monitor scraping the tor pods.
+	// +optional
+	Monitoring MonitoringSpec `json:"monitoring,omitempty"`
+
+	// NetworkPolicy restricting the traffic of the tor pods.
+	// +optional
+	NetworkPolicy NetworkPolicySpec `json:"networkPolicy,omitempty"`
+
 	// Custom/advanced options.
 	// Tor latest man page (asciidoc): https://gitlab.torproject.org/tpo/core/tor/-/blob/main/doc/man/tor.1.txt
 	// +optional
@@ -83,13 +91,36 @@ type TorSpec struct {
 	// Extra arguments to pass Tor's executable
 	// +optional
 	ExtraArgs []string `json:"extraArgs,omitempty"`
+
+	// How configuration changes are applied to running pods.
+	// Rollout restarts the pods; Reload sends SIGHUP to the running tor process.
+	// +optional
+	// +kubebuilder:validation:Enum=Rollout;Reload
+	// +kubebuilder:default:=Rollout
+	ConfigReloadStrategy TorConfigReloadStrategy `json:"configReloadStrategy,omitempty"`
 }
 
+// TorConfigReloadStrategy describes how configuration changes are applied.
+type TorConfigReloadStrategy string
+
+const (
+	// ConfigReloadRollout restarts the pods when the configuration changes.
+	ConfigReloadRollout TorConfigReloadStrategy = "Rollout"
+
+	// ConfigReloadReload signals the running tor process to reload its configuration.
+	ConfigReloadReload TorConfigReloadStrategy = "Reload"
+)
+
 // TorStatus defines the observed state of Tor.
 type TorStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 	Config string `json:"config,omitempty"`
+
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:resource:shortName={"tor"}
@@ -176,14 +207,49 @@ type TorServerSpec struct {
 type TorControlSpec struct {
 	TorGenericPortWithFlagSpec `json:",inline"`
 
-	// Allowed control passwords as string
+	// Deprecated: plaintext passwords are ignored, use secretRef instead.
 	// +optional
 	Secret []string `json:"secret,omitempty"`
 
 	// Allowed Control passwords as Secret object references
-	// Reference to a key of a secret containing the hashed password
+	// Reference to a key of a secret containing the password
 	// +optional
 	SecretRef []corev1.SecretKeySelector `json:"secretRef,omitempty"`
+
+	// Rotation of the generated control password.
+	// Only used if neither secret nor secretRef are set.
+	// +optional
+	Rotation TorControlRotationSpec `json:"rotation,omitempty"`
+
+	// CookieAuthentication. The cookie is written to the shared control
+	// volume, mounted at /run/tor/control in every container of the pod.
+	// +optional
+	CookieAuthentication bool `json:"cookieAuthentication,omitempty"`
+
+	// ControlSocket unix:/run/tor/control/control.sock. The socket is created
+	// in the shared control volume.
+	// +optional
+	Socket TorControlSocketSpec `json:"socket,omitempty"`
+}
+
+type TorControlSocketSpec struct {
+	// +optional
+	Enable bool `json:"enable,omitempty"`
+
+	// Only listen on the socket. The TCP ControlPort is not opened nor
+	// exposed on the service.
+	// +optional
+	Only bool `json:"only,omitempty"`
+}
+
+type TorControlRotationSpec struct {
+	// +optional
+	Enable bool `json:"enable,omitempty"`
+
+	// Time between password rotations, e.g: 720h
+	// +optional
+	// +kubebuilder:default:="720h"
+	Interval metav1.Duration `json:"interval,omitempty"`
 }
 
 // type TorMetricsSpec struct {
@@ -13,8 +13,17 @@ const (
 	torMetricsServiceNameFmt = "%s-tor-metrics-svc"
 	torRoleNameFmt           = "%s-tor-role"
 	torServiceAccountNameFmt = "%s-tor-sa"
+	torNetworkPolicyNameFmt  = "%s-tor-network-policy"
 	torConfigMapFmt          = "%s-tor-config"
 
+	// TorControlDir is the shared volume holding the control socket and
+	// the authentication cookie.
+	TorControlDir = "/run/tor/control"
+
+	// TorControlSecretIgnored is the status condition telling that the
+	// deprecated spec.control.secret passwords are set and ignored.
+	TorControlSecretIgnored = "ControlSecretIgnored"
+
 	dnsPort        = 53
 	natdPort       = 8082
 	httpTunnelPort = 8080
@@ -77,6 +86,10 @@ func (tor *Tor) ServiceAccountName() string {
 	return fmt.Sprintf(torServiceAccountNameFmt, tor.Name)
 }
 
+func (tor *Tor) NetworkPolicyName() string {
+	return fmt.Sprintf(torNetworkPolicyNameFmt, tor.Name)
+}
+
 // Set default vaules port all the Tor ports.
 func (tor *Tor) SetTorDefaults() {
 	tor.Spec.Client.DNS.setPortsDefaults(dnsPort)
@@ -131,12 +144,15 @@ func (torPort *TorGenericPortWithFlagSpec) setPortsDefaults(portDefault int32) {
 
 // Retrieves an array of TorGenericPortDef with their protocols and port details.
 func (tor *Tor) GetAllPorts() []TorGenericPortDef {
+	controlPortSpec := tor.Spec.Control.TorGenericPortSpec
+	controlPortSpec.Enable = tor.ControlPortEnabled()
+
 	return []TorGenericPortDef{
 		// Control
 		{
 			Name:     "control",
 			Protocol: "TCP",
-			Port:     tor.Spec.Control.TorGenericPortSpec,
+			Port:     controlPortSpec,
 		},
 
 		// Metrics
@@ -186,13 +202,52 @@ func (tor *Tor) GetAllPorts() []TorGenericPortDef {
 	}
 }
 
+// PodTemplate returns a copy of the pod template, safe to be modified.
 func (tor *Tor) PodTemplate() corev1.PodTemplateSpec {
 	return corev1.PodTemplateSpec{
-		ObjectMeta: tor.Spec.Template.ObjectMeta,
-		Spec:       tor.Spec.Template.Spec,
+		ObjectMeta: *tor.Spec.Template.ObjectMeta.DeepCopy(),
+		Spec:       *tor.Spec.Template.Spec.DeepCopy(),
 	}
 }
 
 func (tor *Tor) Resources() corev1.ResourceRequirements {
 	return tor.Spec.Template.Resources
 }
+
+// ControlPortEnabled returns true if the TCP ControlPort must be opened.
+func (tor *Tor) ControlPortEnabled() bool {
+	control := tor.Spec.Control
+
+	return control.Enable && !(control.Socket.Enable && control.Socket.Only)
+}
+
+// ControlVolumeEnabled returns true if the shared control volume is needed.
+func (tor *Tor) ControlVolumeEnabled() bool {
+	control := tor.Spec.Control
+
+	return control.Socket.Enable || (control.CookieAuthentication && tor.ControlPortEnabled())
+}
+
+// monitoringSpec merges the boolean serviceMonitor field into spec: true
+// creates the monitor with the settings of spec.
+func monitoringSpec(serviceMonitor bool, spec *MonitoringSpec) *MonitoringSpec {
+	merged := spec.DeepCopy()
+	merged.Enable = merged.Enable || serviceMonitor
+
+	return merged
+}
+
+// Monitoring returns the settings of the monitor scraping the tor pods.
+func (tor *Tor) Monitoring() *MonitoringSpec {
+	return monitoringSpec(tor.Spec.ServiceMonitor, &tor.Spec.Monitoring)
+}
+
+// ServiceMonitorEnabled reports whether a ServiceMonitor must be created.
+func (s *MonitoringSpec) ServiceMonitorEnabled() bool {
+	return s.Enable && s.Kind != MonitorKindPodMonitor
+}
+
+// PodMonitorEnabled reports whether a PodMonitor must be created.
+func (s *MonitoringSpec) PodMonitorEnabled() bool {
+	return s.Enable && s.Kind == MonitorKindPodMonitor
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	configv2 "github.com/bugfest/tor-controller/apis/config/v2"
+)
+
+// TorControllerPolicySpec defines the defaults applied to the pods the
+// controller creates in the policy namespace. They override the defaults
+// of the controller ProjectConfig, and the pod templates of the resources
+// override them.
+type TorControllerPolicySpec struct {
+	// Defaults for the tor daemon containers of Tor resources.
+	// +optional
+	TorDaemon configv2.ComponentDefaults `json:"torDaemon,omitempty"`
+
+	// Defaults for the tor containers of OnionServices and
+	// OnionBalancedServices.
+	// +optional
+	TorDaemonManager configv2.ComponentDefaults `json:"torDaemonManager,omitempty"`
+
+	// Defaults for the onionbalance and vanguards containers.
+	// +optional
+	TorOnionbalanceManager configv2.ComponentDefaults `json:"torOnionbalanceManager,omitempty"`
+}
+
+// +kubebuilder:resource:shortName={"torpolicy"}
+// +kubebuilder:storageversion
+// +kubebuilder:object:root=true
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// TorControllerPolicy is the Schema for the torcontrollerpolicies API.
+type TorControllerPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec TorControllerPolicySpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// TorControllerPolicyList contains a list of TorControllerPolicy.
+type TorControllerPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []TorControllerPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&TorControllerPolicy{}, &TorControllerPolicyList{})
+}
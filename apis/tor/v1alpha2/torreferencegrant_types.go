@@ -0,0 +1,89 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TorReferenceGrantSpec lists who may reference which resources of the
+// grant namespace, like the Gateway API ReferenceGrant.
+type TorReferenceGrantSpec struct {
+	// From are the resources allowed to reference the resources in To.
+	// +kubebuilder:validation:MinItems=1
+	From []ReferenceGrantFrom `json:"from"`
+
+	// To are the resources of this namespace that may be referenced.
+	// +kubebuilder:validation:MinItems=1
+	To []ReferenceGrantTo `json:"to"`
+}
+
+// ReferenceGrantFrom selects the referencing resources by kind and
+// namespace.
+type ReferenceGrantFrom struct {
+	// +optional
+	// +kubebuilder:default:="tor.k8s.torproject.org"
+	Group string `json:"group"`
+
+	// +optional
+	// +kubebuilder:default:="OnionService"
+	Kind string `json:"kind"`
+
+	Namespace string `json:"namespace"`
+}
+
+// ReferenceGrantTo selects the referenced resources by kind and, optionally,
+// name.
+type ReferenceGrantTo struct {
+	// Group of the resource, empty for the core API group
+	// +optional
+	Group string `json:"group"`
+
+	// +optional
+	// +kubebuilder:default:="Service"
+	Kind string `json:"kind"`
+
+	// Name of the resource. Every resource of the kind when empty
+	// +optional
+	Name string `json:"name,omitempty"`
+}
+
+// +kubebuilder:resource:shortName={"torgrant"}
+// +kubebuilder:storageversion
+// +kubebuilder:object:root=true
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// TorReferenceGrant is the Schema for the torreferencegrants API.
+type TorReferenceGrant struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec TorReferenceGrantSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// TorReferenceGrantList contains a list of TorReferenceGrant.
+type TorReferenceGrantList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []TorReferenceGrant `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&TorReferenceGrant{}, &TorReferenceGrantList{})
+}
@@ -22,7 +22,10 @@ limitations under the License.
 package v1alpha2
 
 import (
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -45,6 +48,76 @@ func (in *BalancerTemplate) DeepCopy() *BalancerTemplate {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MonitoringSpec) DeepCopyInto(out *MonitoringSpec) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Relabelings != nil {
+		in, out := &in.Relabelings, &out.Relabelings
+		*out = make([]monitoringv1.RelabelConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.MetricRelabelings != nil {
+		in, out := &in.MetricRelabelings, &out.MetricRelabelings
+		*out = make([]monitoringv1.RelabelConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MonitoringSpec.
+func (in *MonitoringSpec) DeepCopy() *MonitoringSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MonitoringSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkPolicySpec) DeepCopyInto(out *NetworkPolicySpec) {
+	*out = *in
+	if in.MetricsNamespaceSelector != nil {
+		in, out := &in.MetricsNamespaceSelector, &out.MetricsNamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ExtraIngress != nil {
+		in, out := &in.ExtraIngress, &out.ExtraIngress
+		*out = make([]networkingv1.NetworkPolicyIngressRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ExtraEgress != nil {
+		in, out := &in.ExtraEgress, &out.ExtraEgress
+		*out = make([]networkingv1.NetworkPolicyEgressRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkPolicySpec.
+func (in *NetworkPolicySpec) DeepCopy() *NetworkPolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkPolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OnionBalancedService) DeepCopyInto(out *OnionBalancedService) {
 	*out = *in
@@ -108,6 +181,8 @@ func (in *OnionBalancedServiceList) DeepCopyObject() runtime.Object {
 func (in *OnionBalancedServiceSpec) DeepCopyInto(out *OnionBalancedServiceSpec) {
 	*out = *in
 	out.PrivateKeySecret = in.PrivateKeySecret
+	in.Monitoring.DeepCopyInto(&out.Monitoring)
+	in.NetworkPolicy.DeepCopyInto(&out.NetworkPolicy)
 	in.Template.DeepCopyInto(&out.Template)
 	in.BalancerTemplate.DeepCopyInto(&out.BalancerTemplate)
 }
@@ -145,7 +220,7 @@ func (in *OnionBalancedServiceStatus) DeepCopy() *OnionBalancedServiceStatus {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *OnionService) DeepCopyInto(out *OnionService) {
+func (in *OnionEndpoint) DeepCopyInto(out *OnionEndpoint) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
@@ -153,6 +228,117 @@ func (in *OnionService) DeepCopyInto(out *OnionService) {
 	out.Status = in.Status
 }
 
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OnionEndpoint.
+func (in *OnionEndpoint) DeepCopy() *OnionEndpoint {
+	if in == nil {
+		return nil
+	}
+	out := new(OnionEndpoint)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *OnionEndpoint) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OnionEndpointList) DeepCopyInto(out *OnionEndpointList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]OnionEndpoint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OnionEndpointList.
+func (in *OnionEndpointList) DeepCopy() *OnionEndpointList {
+	if in == nil {
+		return nil
+	}
+	out := new(OnionEndpointList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *OnionEndpointList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OnionEndpointPort) DeepCopyInto(out *OnionEndpointPort) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OnionEndpointPort.
+func (in *OnionEndpointPort) DeepCopy() *OnionEndpointPort {
+	if in == nil {
+		return nil
+	}
+	out := new(OnionEndpointPort)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OnionEndpointSpec) DeepCopyInto(out *OnionEndpointSpec) {
+	*out = *in
+	if in.Ports != nil {
+		in, out := &in.Ports, &out.Ports
+		*out = make([]OnionEndpointPort, len(*in))
+		copy(*out, *in)
+	}
+	out.ClientAuthKeySecret = in.ClientAuthKeySecret
+	in.Template.DeepCopyInto(&out.Template)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OnionEndpointSpec.
+func (in *OnionEndpointSpec) DeepCopy() *OnionEndpointSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(OnionEndpointSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OnionEndpointStatus) DeepCopyInto(out *OnionEndpointStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OnionEndpointStatus.
+func (in *OnionEndpointStatus) DeepCopy() *OnionEndpointStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(OnionEndpointStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OnionService) DeepCopyInto(out *OnionService) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OnionService.
 func (in *OnionService) DeepCopy() *OnionService {
 	if in == nil {
@@ -171,6 +357,43 @@ func (in *OnionService) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OnionServiceBackend) DeepCopyInto(out *OnionServiceBackend) {
+	*out = *in
+	in.IngressBackend.DeepCopyInto(&out.IngressBackend)
+	if in.Unix != nil {
+		in, out := &in.Unix, &out.Unix
+		*out = new(UnixSocketBackend)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OnionServiceBackend.
+func (in *OnionServiceBackend) DeepCopy() *OnionServiceBackend {
+	if in == nil {
+		return nil
+	}
+	out := new(OnionServiceBackend)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OnionServiceHTTPRoute) DeepCopyInto(out *OnionServiceHTTPRoute) {
+	*out = *in
+	in.Backend.DeepCopyInto(&out.Backend)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OnionServiceHTTPRoute.
+func (in *OnionServiceHTTPRoute) DeepCopy() *OnionServiceHTTPRoute {
+	if in == nil {
+		return nil
+	}
+	out := new(OnionServiceHTTPRoute)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OnionServiceList) DeepCopyInto(out *OnionServiceList) {
 	*out = *in
@@ -203,6 +426,21 @@ func (in *OnionServiceList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OnionServiceRouteTarget) DeepCopyInto(out *OnionServiceRouteTarget) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OnionServiceRouteTarget.
+func (in *OnionServiceRouteTarget) DeepCopy() *OnionServiceRouteTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(OnionServiceRouteTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OnionServiceSpec) DeepCopyInto(out *OnionServiceSpec) {
 	*out = *in
@@ -220,6 +458,10 @@ func (in *OnionServiceSpec) DeepCopyInto(out *OnionServiceSpec) {
 		*out = make([]SecretReference, len(*in))
 		copy(*out, *in)
 	}
+	in.Monitoring.DeepCopyInto(&out.Monitoring)
+	in.NetworkPolicy.DeepCopyInto(&out.NetworkPolicy)
+	in.Vanguards.DeepCopyInto(&out.Vanguards)
+	in.Publish.DeepCopyInto(&out.Publish)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OnionServiceSpec.
@@ -235,6 +477,27 @@ func (in *OnionServiceSpec) DeepCopy() *OnionServiceSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OnionServiceStatus) DeepCopyInto(out *OnionServiceStatus) {
 	*out = *in
+	if in.Targets != nil {
+		in, out := &in.Targets, &out.Targets
+		*out = make([]OnionServiceTarget, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Published != nil {
+		in, out := &in.Published, &out.Published
+		*out = make([]PublishedTarget, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OnionServiceStatus.
@@ -247,6 +510,131 @@ func (in *OnionServiceStatus) DeepCopy() *OnionServiceStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OnionServiceTarget) DeepCopyInto(out *OnionServiceTarget) {
+	*out = *in
+	if in.Routes != nil {
+		in, out := &in.Routes, &out.Routes
+		*out = make([]OnionServiceRouteTarget, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OnionServiceTarget.
+func (in *OnionServiceTarget) DeepCopy() *OnionServiceTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(OnionServiceTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PublishDNSEndpoint) DeepCopyInto(out *PublishDNSEndpoint) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PublishDNSEndpoint.
+func (in *PublishDNSEndpoint) DeepCopy() *PublishDNSEndpoint {
+	if in == nil {
+		return nil
+	}
+	out := new(PublishDNSEndpoint)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PublishSpec) DeepCopyInto(out *PublishSpec) {
+	*out = *in
+	if in.Targets != nil {
+		in, out := &in.Targets, &out.Targets
+		*out = make([]PublishTarget, len(*in))
+		copy(*out, *in)
+	}
+	if in.DNSEndpoint != nil {
+		in, out := &in.DNSEndpoint, &out.DNSEndpoint
+		*out = new(PublishDNSEndpoint)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PublishSpec.
+func (in *PublishSpec) DeepCopy() *PublishSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PublishSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PublishTarget) DeepCopyInto(out *PublishTarget) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PublishTarget.
+func (in *PublishTarget) DeepCopy() *PublishTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(PublishTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PublishedTarget) DeepCopyInto(out *PublishedTarget) {
+	*out = *in
+	if in.Keys != nil {
+		in, out := &in.Keys, &out.Keys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PublishedTarget.
+func (in *PublishedTarget) DeepCopy() *PublishedTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(PublishedTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReferenceGrantFrom) DeepCopyInto(out *ReferenceGrantFrom) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReferenceGrantFrom.
+func (in *ReferenceGrantFrom) DeepCopy() *ReferenceGrantFrom {
+	if in == nil {
+		return nil
+	}
+	out := new(ReferenceGrantFrom)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReferenceGrantTo) DeepCopyInto(out *ReferenceGrantTo) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReferenceGrantTo.
+func (in *ReferenceGrantTo) DeepCopy() *ReferenceGrantTo {
+	if in == nil {
+		return nil
+	}
+	out := new(ReferenceGrantTo)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SecretReference) DeepCopyInto(out *SecretReference) {
 	*out = *in
@@ -300,6 +688,13 @@ func (in *ServiceRule) DeepCopyInto(out *ServiceRule) {
 	*out = *in
 	out.Port = in.Port
 	in.Backend.DeepCopyInto(&out.Backend)
+	if in.HTTP != nil {
+		in, out := &in.HTTP, &out.HTTP
+		*out = make([]OnionServiceHTTPRoute, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceRule.
@@ -334,7 +729,7 @@ func (in *Tor) DeepCopyInto(out *Tor) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Tor.
@@ -375,6 +770,21 @@ func (in *TorClientSpec) DeepCopy() *TorClientSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TorControlSocketSpec) DeepCopyInto(out *TorControlSocketSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TorControlSocketSpec.
+func (in *TorControlSocketSpec) DeepCopy() *TorControlSocketSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(TorControlSocketSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TorControlSpec) DeepCopyInto(out *TorControlSpec) {
 	*out = *in
@@ -391,6 +801,8 @@ func (in *TorControlSpec) DeepCopyInto(out *TorControlSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	out.Rotation = in.Rotation
+	out.Socket = in.Socket
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TorControlSpec.
@@ -403,6 +815,98 @@ func (in *TorControlSpec) DeepCopy() *TorControlSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TorControlRotationSpec) DeepCopyInto(out *TorControlRotationSpec) {
+	*out = *in
+	out.Interval = in.Interval
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TorControlRotationSpec.
+func (in *TorControlRotationSpec) DeepCopy() *TorControlRotationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(TorControlRotationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TorControllerPolicy) DeepCopyInto(out *TorControllerPolicy) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TorControllerPolicy.
+func (in *TorControllerPolicy) DeepCopy() *TorControllerPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(TorControllerPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *TorControllerPolicy) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TorControllerPolicyList) DeepCopyInto(out *TorControllerPolicyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]TorControllerPolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TorControllerPolicyList.
+func (in *TorControllerPolicyList) DeepCopy() *TorControllerPolicyList {
+	if in == nil {
+		return nil
+	}
+	out := new(TorControllerPolicyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *TorControllerPolicyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TorControllerPolicySpec) DeepCopyInto(out *TorControllerPolicySpec) {
+	*out = *in
+	in.TorDaemon.DeepCopyInto(&out.TorDaemon)
+	in.TorDaemonManager.DeepCopyInto(&out.TorDaemonManager)
+	in.TorOnionbalanceManager.DeepCopyInto(&out.TorOnionbalanceManager)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TorControllerPolicySpec.
+func (in *TorControllerPolicySpec) DeepCopy() *TorControllerPolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(TorControllerPolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TorGenericPortDef) DeepCopyInto(out *TorGenericPortDef) {
 	*out = *in
@@ -515,6 +1019,89 @@ func (in *TorPodTemplate) DeepCopy() *TorPodTemplate {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TorReferenceGrant) DeepCopyInto(out *TorReferenceGrant) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TorReferenceGrant.
+func (in *TorReferenceGrant) DeepCopy() *TorReferenceGrant {
+	if in == nil {
+		return nil
+	}
+	out := new(TorReferenceGrant)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *TorReferenceGrant) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TorReferenceGrantList) DeepCopyInto(out *TorReferenceGrantList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]TorReferenceGrant, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TorReferenceGrantList.
+func (in *TorReferenceGrantList) DeepCopy() *TorReferenceGrantList {
+	if in == nil {
+		return nil
+	}
+	out := new(TorReferenceGrantList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *TorReferenceGrantList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TorReferenceGrantSpec) DeepCopyInto(out *TorReferenceGrantSpec) {
+	*out = *in
+	if in.From != nil {
+		in, out := &in.From, &out.From
+		*out = make([]ReferenceGrantFrom, len(*in))
+		copy(*out, *in)
+	}
+	if in.To != nil {
+		in, out := &in.To, &out.To
+		*out = make([]ReferenceGrantTo, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TorReferenceGrantSpec.
+func (in *TorReferenceGrantSpec) DeepCopy() *TorReferenceGrantSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(TorReferenceGrantSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TorServerSpec) DeepCopyInto(out *TorServerSpec) {
 	*out = *in
@@ -539,6 +1126,8 @@ func (in *TorSpec) DeepCopyInto(out *TorSpec) {
 	in.Server.DeepCopyInto(&out.Server)
 	in.Control.DeepCopyInto(&out.Control)
 	in.Metrics.DeepCopyInto(&out.Metrics)
+	in.Monitoring.DeepCopyInto(&out.Monitoring)
+	in.NetworkPolicy.DeepCopyInto(&out.NetworkPolicy)
 	if in.ConfigMapKeyRef != nil {
 		in, out := &in.ConfigMapKeyRef, &out.ConfigMapKeyRef
 		*out = make([]v1.ConfigMapKeySelector, len(*in))
@@ -566,6 +1155,13 @@ func (in *TorSpec) DeepCopy() *TorSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TorStatus) DeepCopyInto(out *TorStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TorStatus.
@@ -577,3 +1173,69 @@ func (in *TorStatus) DeepCopy() *TorStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UnixSocketBackend) DeepCopyInto(out *UnixSocketBackend) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UnixSocketBackend.
+func (in *UnixSocketBackend) DeepCopy() *UnixSocketBackend {
+	if in == nil {
+		return nil
+	}
+	out := new(UnixSocketBackend)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VanguardsRotationSpec) DeepCopyInto(out *VanguardsRotationSpec) {
+	*out = *in
+	if in.MinLayer2Lifetime != nil {
+		in, out := &in.MinLayer2Lifetime, &out.MinLayer2Lifetime
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.MaxLayer2Lifetime != nil {
+		in, out := &in.MaxLayer2Lifetime, &out.MaxLayer2Lifetime
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.MinLayer3Lifetime != nil {
+		in, out := &in.MinLayer3Lifetime, &out.MinLayer3Lifetime
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.MaxLayer3Lifetime != nil {
+		in, out := &in.MaxLayer3Lifetime, &out.MaxLayer3Lifetime
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VanguardsRotationSpec.
+func (in *VanguardsRotationSpec) DeepCopy() *VanguardsRotationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(VanguardsRotationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VanguardsSpec) DeepCopyInto(out *VanguardsSpec) {
+	*out = *in
+	in.Rotation.DeepCopyInto(&out.Rotation)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VanguardsSpec.
+func (in *VanguardsSpec) DeepCopy() *VanguardsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(VanguardsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
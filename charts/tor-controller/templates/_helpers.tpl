@@ -61,11 +61,50 @@ Create the name of the service account to use
 {{- end }}
 {{- end }}
 
+{{/*
+Whether permissions are restricted to the watched namespaces
+*/}}
+{{- define "tor-controller.namespaced" -}}
+{{- if or .Values.namespaced .Values.watchNamespaces .Values.watchNamespaceSelector }}
+{{- "true" }}
+{{- end }}
+{{- end }}
+
+{{/*
+JSON list of the namespaces watched by the controller. Namespaces matching
+watchNamespaceSelector are looked up at install/upgrade time
+*/}}
+{{- define "tor-controller.watchNamespaces" -}}
+{{- $namespaces := list }}
+{{- if .Values.namespaced }}
+{{- $namespaces = append $namespaces .Release.Namespace }}
+{{- end }}
+{{- range .Values.watchNamespaces }}
+{{- $namespaces = append $namespaces . }}
+{{- end }}
+{{- with .Values.watchNamespaceSelector }}
+{{- $selector := . }}
+{{- range (lookup "v1" "Namespace" "" "").items }}
+{{- $labels := .metadata.labels | default dict }}
+{{- $match := true }}
+{{- range $key, $value := $selector }}
+{{- if ne (get $labels $key) (toString $value) }}
+{{- $match = false }}
+{{- end }}
+{{- end }}
+{{- if $match }}
+{{- $namespaces = append $namespaces .metadata.name }}
+{{- end }}
+{{- end }}
+{{- end }}
+{{- $namespaces | uniq | toJson }}
+{{- end }}
+
 {{/*
 Helper to dynamically create cluster-wide or namespaced roles & rolebindings
 */}}
 {{- define "tor-controller.roleKind" -}}
-{{- if .Values.namespaced }}
+{{- if include "tor-controller.namespaced" . }}
 {{- "Role" }}
 {{- else }}
 {{- "ClusterRole" }}
@@ -76,7 +115,7 @@ Helper to dynamically create cluster-wide or namespaced roles & rolebindings
 Helper to dynamically create cluster-wide or namespaced roles & rolebindings
 */}}
 {{- define "tor-controller.roleBindingKind" -}}
-{{- if .Values.namespaced }}
+{{- if include "tor-controller.namespaced" . }}
 {{- "RoleBinding" }}
 {{- else }}
 {{- "ClusterRoleBinding" }}
@@ -0,0 +1,748 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tor
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	k8slog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	configv2 "github.com/bugfest/tor-controller/apis/config/v2"
+	torv1alpha2 "github.com/bugfest/tor-controller/apis/tor/v1alpha2"
+	"github.com/cockroachdb/errors"
+)
+
+// GatewayControllerName is the controller of the GatewayClasses exposed
+// through onion services.
+const GatewayControllerName = "tor.k8s.torproject.org/gateway-controller"
+
+// GatewayReconciler exposes the Gateways of the tor GatewayClasses, and their
+// HTTPRoutes, through generated OnionServices.
+type GatewayReconciler struct {
+	client.Client
+	Scheme        *runtime.Scheme
+	ProjectConfig configv2.ProjectConfig
+}
+
+// routeAttachment is a parent reference of an HTTPRoute to the Gateway.
+type routeAttachment struct {
+	route     *gatewayv1alpha2.HTTPRoute
+	parentRef gatewayv1alpha2.ParentRef
+
+	// listeners accepting the route, by index
+	listeners []int
+
+	// why no listener accepts the route
+	reason, message string
+}
+
+//+kubebuilder:rbac:groups="gateway.networking.k8s.io",resources=gatewayclasses,verbs=get
+//+kubebuilder:rbac:groups="gateway.networking.k8s.io",resources=gateways,verbs=get;list;watch
+//+kubebuilder:rbac:groups="gateway.networking.k8s.io",resources=gateways/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups="gateway.networking.k8s.io",resources=httproutes,verbs=get;list;watch
+//+kubebuilder:rbac:groups="gateway.networking.k8s.io",resources=httproutes/status,verbs=get;update;patch
+
+// Reconcile keeps the OnionService of a Gateway in sync with its HTTP
+// listeners and routes, and publishes the onion address in the Gateway
+// status.
+func (r *GatewayReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var gateway gatewayv1alpha2.Gateway
+
+	err := r.Get(ctx, req.NamespacedName, &gateway)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(client.IgnoreNotFound(err), "unable to fetch Gateway")
+	}
+
+	served, err := r.servedGateway(ctx, &gateway)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if !served {
+		_, err = reconcileGeneratedOnionService(ctx, r.Client, &gateway, nil)
+
+		return ctrl.Result{}, err
+	}
+
+	var routes gatewayv1alpha2.HTTPRouteList
+
+	err = r.List(ctx, &routes)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "unable to list HTTPRoutes")
+	}
+
+	attachments := gatewayAttachments(&gateway, routes.Items)
+
+	desired := gatewayOnionService(&gateway, attachments)
+
+	onion, err := reconcileGeneratedOnionService(ctx, r.Client, &gateway, desired)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Listeners sharing a port share the rule of the OnionService
+	conflicts := map[int32][]string{}
+
+	for i := range desired.Spec.Rules {
+		rule := &desired.Spec.Rules[i]
+		conflicts[rule.Port.Number] = routeConflicts(rule)
+	}
+
+	err = r.updateRouteStatuses(ctx, &gateway, routes.Items, attachments)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	hostname := ""
+	if onion != nil {
+		hostname = onion.Status.Hostname
+	}
+
+	return ctrl.Result{}, r.updateGatewayStatus(ctx, &gateway, attachments, conflicts, hostname)
+}
+
+// servedGateway tells whether the GatewayClass of gateway is a tor one.
+func (r *GatewayReconciler) servedGateway(ctx context.Context, gateway *gatewayv1alpha2.Gateway) (bool, error) {
+	var class gatewayv1alpha2.GatewayClass
+
+	err := r.Get(ctx, types.NamespacedName{Name: string(gateway.Spec.GatewayClassName)}, &class)
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, errors.Wrapf(err, "failed to get GatewayClass %s", gateway.Spec.GatewayClassName)
+	}
+
+	return class.Spec.ControllerName == GatewayControllerName, nil
+}
+
+// gatewayAttachments returns the parent references of the routes to gateway,
+// oldest routes first as they take precedence.
+func gatewayAttachments(gateway *gatewayv1alpha2.Gateway, routes []gatewayv1alpha2.HTTPRoute) []routeAttachment {
+	attachments := []routeAttachment{}
+
+	for i := range routes {
+		route := &routes[i]
+
+		for _, parentRef := range route.Spec.ParentRefs {
+			if !parentRefersTo(parentRef, route.Namespace, gateway) {
+				continue
+			}
+
+			attachment := routeAttachment{
+				route:     route,
+				parentRef: parentRef,
+				reason:    "NotAllowedByListeners",
+				message:   "no HTTP listener of the Gateway allows the route",
+			}
+
+			for j := range gateway.Spec.Listeners {
+				listener := &gateway.Spec.Listeners[j]
+
+				if parentRef.SectionName != nil && *parentRef.SectionName != listener.Name {
+					continue
+				}
+
+				if !listenerAllowsRoute(listener, gateway.Namespace, route.Namespace) {
+					continue
+				}
+
+				if len(routeHostnames(listener.Hostname, route.Spec.Hostnames)) == 0 {
+					attachment.reason = "NoMatchingListenerHostname"
+					attachment.message = "no listener hostname matches the route hostnames"
+
+					continue
+				}
+
+				attachment.listeners = append(attachment.listeners, j)
+			}
+
+			attachments = append(attachments, attachment)
+		}
+	}
+
+	sort.SliceStable(attachments, func(i, j int) bool {
+		a, b := attachments[i].route, attachments[j].route
+		if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+			return a.CreationTimestamp.Before(&b.CreationTimestamp)
+		}
+
+		return a.Namespace+"/"+a.Name < b.Namespace+"/"+b.Name
+	})
+
+	return attachments
+}
+
+// parentRefersTo tells whether a parent reference of a route in
+// routeNamespace is gateway.
+func parentRefersTo(parentRef gatewayv1alpha2.ParentRef, routeNamespace string, gateway *gatewayv1alpha2.Gateway) bool {
+	if parentRef.Group != nil && *parentRef.Group != gatewayv1alpha2.GroupName {
+		return false
+	}
+
+	if parentRef.Kind != nil && *parentRef.Kind != "Gateway" {
+		return false
+	}
+
+	namespace := routeNamespace
+	if parentRef.Namespace != nil {
+		namespace = string(*parentRef.Namespace)
+	}
+
+	return string(parentRef.Name) == gateway.Name && namespace == gateway.Namespace
+}
+
+// listenerAllowsRoute tells whether an HTTPRoute in routeNamespace may attach
+// to listener. Namespace selectors aren't supported.
+func listenerAllowsRoute(listener *gatewayv1alpha2.Listener, gatewayNamespace, routeNamespace string) bool {
+	if listener.Protocol != gatewayv1alpha2.HTTPProtocolType {
+		return false
+	}
+
+	from := gatewayv1alpha2.NamespacesFromSame
+	allowedKinds := []gatewayv1alpha2.RouteGroupKind{}
+
+	if allowed := listener.AllowedRoutes; allowed != nil {
+		if allowed.Namespaces != nil && allowed.Namespaces.From != nil {
+			from = *allowed.Namespaces.From
+		}
+
+		allowedKinds = allowed.Kinds
+	}
+
+	switch {
+	case from == gatewayv1alpha2.NamespacesFromSame && routeNamespace != gatewayNamespace:
+		return false
+	case from != gatewayv1alpha2.NamespacesFromSame && from != gatewayv1alpha2.NamespacesFromAll:
+		return false
+	}
+
+	if len(allowedKinds) == 0 {
+		return true
+	}
+
+	for _, kind := range allowedKinds {
+		if kind.Kind == "HTTPRoute" && (kind.Group == nil || *kind.Group == gatewayv1alpha2.GroupName) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// routeHostnames returns the hosts a route answers on a listener, none when
+// they don't intersect. An empty host matches any.
+func routeHostnames(listenerHostname *gatewayv1alpha2.Hostname, hostnames []gatewayv1alpha2.Hostname) []string {
+	listenerHost := ""
+	if listenerHostname != nil {
+		listenerHost = string(*listenerHostname)
+	}
+
+	if len(hostnames) == 0 {
+		return []string{listenerHost}
+	}
+
+	hosts := []string{}
+
+	for _, hostname := range hostnames {
+		host := string(hostname)
+
+		switch {
+		case listenerHost == "" || listenerHost == host || wildcardMatches(listenerHost, host):
+			hosts = append(hosts, host)
+		case wildcardMatches(host, listenerHost):
+			hosts = append(hosts, listenerHost)
+		}
+	}
+
+	return hosts
+}
+
+// wildcardMatches tells whether a "*." pattern matches host, the wildcard
+// being a single label.
+func wildcardMatches(pattern, host string) bool {
+	if !strings.HasPrefix(pattern, "*.") {
+		return false
+	}
+
+	label := strings.TrimSuffix(host, pattern[1:])
+
+	return label != host && label != "" && !strings.Contains(label, ".")
+}
+
+// gatewayOnionService routes the HTTP requests to each listener port of the
+// onion address like the attached routes do.
+func gatewayOnionService(gateway *gatewayv1alpha2.Gateway, attachments []routeAttachment) *torv1alpha2.OnionService {
+	routesByPort := map[int32][]torv1alpha2.OnionServiceHTTPRoute{}
+
+	for _, attachment := range attachments {
+		for _, j := range attachment.listeners {
+			listener := &gateway.Spec.Listeners[j]
+			port := int32(listener.Port)
+
+			routesByPort[port] = append(routesByPort[port],
+				httpRoutes(attachment.route, listener, gateway.Namespace)...)
+		}
+	}
+
+	ports := make([]int32, 0, len(routesByPort))
+
+	for port, routes := range routesByPort {
+		if len(routes) > 0 {
+			ports = append(ports, port)
+		}
+	}
+
+	sort.Slice(ports, func(i, j int) bool { return ports[i] < ports[j] })
+
+	rules := make([]torv1alpha2.ServiceRule, 0, len(ports))
+
+	for _, port := range ports {
+		rules = append(rules, torv1alpha2.ServiceRule{
+			Port: networkingv1.ServiceBackendPort{Number: port},
+			HTTP: routesByPort[port],
+		})
+	}
+
+	return generatedOnionService(gateway, gatewayv1alpha2.SchemeGroupVersion.WithKind("Gateway"), rules)
+}
+
+// httpRoutes translates the rules of route on listener. Only the first
+// Service backend of each rule is used.
+func httpRoutes(
+	route *gatewayv1alpha2.HTTPRoute,
+	listener *gatewayv1alpha2.Listener,
+	gatewayNamespace string,
+) []torv1alpha2.OnionServiceHTTPRoute {
+	routes := []torv1alpha2.OnionServiceHTTPRoute{}
+	hosts := onionRouteHosts(routeHostnames(listener.Hostname, route.Spec.Hostnames))
+
+	for i := range route.Spec.Rules {
+		rule := &route.Spec.Rules[i]
+
+		backend := ruleBackend(rule, route.Namespace, gatewayNamespace)
+		if backend == nil {
+			continue
+		}
+
+		matches := rule.Matches
+		if len(matches) == 0 {
+			matches = []gatewayv1alpha2.HTTPRouteMatch{{}}
+		}
+
+		for j := range matches {
+			path, pathType, ok := matchPath(&matches[j])
+			if !ok {
+				continue
+			}
+
+			for _, host := range hosts {
+				routes = append(routes, torv1alpha2.OnionServiceHTTPRoute{
+					Host:     host,
+					Path:     path,
+					PathType: pathType,
+					Backend:  *backend,
+				})
+			}
+		}
+	}
+
+	return routes
+}
+
+// ruleBackend returns the first Service the rule sends traffic to.
+func ruleBackend(
+	rule *gatewayv1alpha2.HTTPRouteRule,
+	routeNamespace, gatewayNamespace string,
+) *torv1alpha2.OnionServiceBackend {
+	for _, ref := range rule.BackendRefs {
+		if ref.Weight != nil && *ref.Weight == 0 {
+			continue
+		}
+
+		if (ref.Group != nil && *ref.Group != "") || (ref.Kind != nil && *ref.Kind != "Service") || ref.Port == nil {
+			continue
+		}
+
+		namespace := routeNamespace
+		if ref.Namespace != nil {
+			namespace = string(*ref.Namespace)
+		}
+
+		backend := &torv1alpha2.OnionServiceBackend{
+			IngressBackend: networkingv1.IngressBackend{
+				Service: &networkingv1.IngressServiceBackend{
+					Name: string(ref.Name),
+					Port: networkingv1.ServiceBackendPort{Number: int32(*ref.Port)},
+				},
+			},
+		}
+
+		// Backends of other namespaces need a TorReferenceGrant
+		if namespace != gatewayNamespace {
+			backend.Namespace = namespace
+		}
+
+		return backend
+	}
+
+	return nil
+}
+
+// matchPath returns the path of a match. Matches on headers, query
+// parameters, methods or regular expressions aren't supported.
+func matchPath(match *gatewayv1alpha2.HTTPRouteMatch) (string, networkingv1.PathType, bool) {
+	if len(match.Headers) > 0 || len(match.QueryParams) > 0 || match.Method != nil {
+		return "", "", false
+	}
+
+	path, pathType := "/", networkingv1.PathTypePrefix
+
+	if match.Path == nil {
+		return path, pathType, true
+	}
+
+	if match.Path.Value != nil {
+		path = *match.Path.Value
+	}
+
+	if match.Path.Type != nil {
+		switch *match.Path.Type {
+		case gatewayv1alpha2.PathMatchExact:
+			pathType = networkingv1.PathTypeExact
+		case gatewayv1alpha2.PathMatchPathPrefix:
+		default:
+			return "", "", false
+		}
+	}
+
+	return path, pathType, true
+}
+
+// updateRouteStatuses sets the Accepted condition of the routes referencing
+// gateway, and removes it from the routes that don't anymore.
+func (r *GatewayReconciler) updateRouteStatuses(
+	ctx context.Context,
+	gateway *gatewayv1alpha2.Gateway,
+	routes []gatewayv1alpha2.HTTPRoute,
+	attachments []routeAttachment,
+) error {
+	for i := range routes {
+		route := &routes[i]
+
+		// The statuses of other Gateways are kept
+		parents := []gatewayv1alpha2.RouteParentStatus{}
+
+		for _, parent := range route.Status.Parents {
+			if parent.ControllerName != GatewayControllerName || !parentRefersTo(parent.ParentRef, route.Namespace, gateway) {
+				parents = append(parents, parent)
+			}
+		}
+
+		for j := range attachments {
+			if attachments[j].route == route {
+				parents = append(parents, routeParentStatus(route, &attachments[j]))
+			}
+		}
+
+		if reflect.DeepEqual(route.Status.Parents, parents) ||
+			(len(route.Status.Parents) == 0 && len(parents) == 0) {
+			continue
+		}
+
+		route.Status.Parents = parents
+
+		err := r.Status().Update(ctx, route)
+		if err != nil {
+			return errors.Wrapf(err, "unable to update HTTPRoute %s/%s status", route.Namespace, route.Name)
+		}
+	}
+
+	return nil
+}
+
+// routeParentStatus returns the status of an attachment, keeping the
+// transition time of the current condition.
+func routeParentStatus(route *gatewayv1alpha2.HTTPRoute, attachment *routeAttachment) gatewayv1alpha2.RouteParentStatus {
+	conditions := []metav1.Condition{}
+
+	for _, parent := range route.Status.Parents {
+		if parent.ControllerName == GatewayControllerName && reflect.DeepEqual(parent.ParentRef, attachment.parentRef) {
+			conditions = append(conditions, parent.Conditions...)
+		}
+	}
+
+	accepted := metav1.Condition{
+		Type:               string(gatewayv1alpha2.ConditionRouteAccepted),
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: route.Generation,
+		Reason:             "Accepted",
+		Message:            "the route is exposed by the onion service of the Gateway",
+	}
+
+	if len(attachment.listeners) == 0 {
+		accepted.Status = metav1.ConditionFalse
+		accepted.Reason = attachment.reason
+		accepted.Message = attachment.message
+	}
+
+	meta.SetStatusCondition(&conditions, accepted)
+
+	return gatewayv1alpha2.RouteParentStatus{
+		ParentRef:      attachment.parentRef,
+		ControllerName: GatewayControllerName,
+		Conditions:     conditions,
+	}
+}
+
+// updateGatewayStatus publishes the onion address and the state of the
+// listeners, conflicts being the routes shadowed on each port.
+func (r *GatewayReconciler) updateGatewayStatus(
+	ctx context.Context,
+	gateway *gatewayv1alpha2.Gateway,
+	attachments []routeAttachment,
+	conflicts map[int32][]string,
+	hostname string,
+) error {
+	status := gateway.Status.DeepCopy()
+
+	scheduled := metav1.Condition{
+		Type:               string(gatewayv1alpha2.GatewayConditionScheduled),
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: gateway.Generation,
+		Reason:             string(gatewayv1alpha2.GatewayReasonScheduled),
+		Message:            "exposed by the OnionService " + gateway.Name,
+	}
+
+	ready := metav1.Condition{
+		Type:               string(gatewayv1alpha2.GatewayConditionReady),
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: gateway.Generation,
+		Reason:             string(gatewayv1alpha2.GatewayReasonAddressNotAssigned),
+		Message:            "waiting for the onion address",
+	}
+
+	status.Addresses = nil
+
+	if hostname != "" {
+		hostnameType := gatewayv1alpha2.HostnameAddressType
+		status.Addresses = []gatewayv1alpha2.GatewayAddress{{Type: &hostnameType, Value: hostname}}
+		ready.Status = metav1.ConditionTrue
+		ready.Reason = string(gatewayv1alpha2.GatewayReasonReady)
+		ready.Message = "the onion address is assigned"
+	}
+
+	meta.SetStatusCondition(&status.Conditions, scheduled)
+	meta.SetStatusCondition(&status.Conditions, ready)
+
+	listeners := make([]gatewayv1alpha2.ListenerStatus, 0, len(gateway.Spec.Listeners))
+
+	for j := range gateway.Spec.Listeners {
+		port := int32(gateway.Spec.Listeners[j].Port)
+		listeners = append(listeners, listenerStatus(gateway, j, attachments, conflicts[port]))
+	}
+
+	status.Listeners = listeners
+
+	if reflect.DeepEqual(&gateway.Status, status) {
+		return nil
+	}
+
+	gateway.Status = *status
+
+	err := r.Status().Update(ctx, gateway)
+	if err != nil {
+		return errors.Wrap(err, "unable to update Gateway status")
+	}
+
+	return nil
+}
+
+// listenerStatus returns the status of the listener j, keeping the
+// transition time of the current conditions. conflicts are the routes
+// shadowed on its port.
+func listenerStatus(
+	gateway *gatewayv1alpha2.Gateway,
+	j int,
+	attachments []routeAttachment,
+	conflicts []string,
+) gatewayv1alpha2.ListenerStatus {
+	listener := &gateway.Spec.Listeners[j]
+	group := gatewayv1alpha2.Group(gatewayv1alpha2.GroupName)
+
+	status := gatewayv1alpha2.ListenerStatus{
+		Name:           listener.Name,
+		SupportedKinds: []gatewayv1alpha2.RouteGroupKind{{Group: &group, Kind: "HTTPRoute"}},
+		Conditions:     []metav1.Condition{},
+	}
+
+	for _, current := range gateway.Status.Listeners {
+		if current.Name == listener.Name {
+			status.Conditions = append(status.Conditions, current.Conditions...)
+		}
+	}
+
+	for _, attachment := range attachments {
+		for _, k := range attachment.listeners {
+			if k == j {
+				status.AttachedRoutes++
+			}
+		}
+	}
+
+	detached := metav1.Condition{
+		Type:               string(gatewayv1alpha2.ListenerConditionDetached),
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: gateway.Generation,
+		Reason:             string(gatewayv1alpha2.ListenerReasonAttached),
+		Message:            "the listener is a port of the onion service",
+	}
+
+	ready := metav1.Condition{
+		Type:               string(gatewayv1alpha2.ListenerConditionReady),
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: gateway.Generation,
+		Reason:             string(gatewayv1alpha2.ListenerReasonReady),
+		Message:            "the listener is a port of the onion service",
+	}
+
+	conflicted := metav1.Condition{
+		Type:               string(gatewayv1alpha2.ListenerConditionConflicted),
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: gateway.Generation,
+		Reason:             string(gatewayv1alpha2.ListenerReasonNoConflicts),
+		Message:            "no routes conflict",
+	}
+
+	// Only the first of the conflicting routes is served
+	if len(conflicts) > 0 {
+		conflicted.Status = metav1.ConditionTrue
+		conflicted.Reason = string(gatewayv1alpha2.ListenerReasonHostnameConflict)
+		conflicted.Message = strings.Join(conflicts, "; ")
+	}
+
+	if listener.Protocol != gatewayv1alpha2.HTTPProtocolType {
+		status.SupportedKinds = []gatewayv1alpha2.RouteGroupKind{}
+		detached.Status = metav1.ConditionTrue
+		detached.Reason = string(gatewayv1alpha2.ListenerReasonUnsupportedProtocol)
+		detached.Message = "only HTTP listeners are supported"
+		ready.Status = metav1.ConditionFalse
+		ready.Reason = string(gatewayv1alpha2.ListenerReasonInvalid)
+		ready.Message = detached.Message
+	}
+
+	meta.SetStatusCondition(&status.Conditions, detached)
+	meta.SetStatusCondition(&status.Conditions, conflicted)
+	meta.SetStatusCondition(&status.Conditions, ready)
+
+	return status
+}
+
+// findGatewaysForRoute maps an HTTPRoute to the Gateways it references, or
+// used to.
+func findGatewaysForRoute(object client.Object) []reconcile.Request {
+	route, ok := object.(*gatewayv1alpha2.HTTPRoute)
+	if !ok {
+		return nil
+	}
+
+	parentRefs := append([]gatewayv1alpha2.ParentRef{}, route.Spec.ParentRefs...)
+
+	for _, parent := range route.Status.Parents {
+		if parent.ControllerName == GatewayControllerName {
+			parentRefs = append(parentRefs, parent.ParentRef)
+		}
+	}
+
+	requests := []reconcile.Request{}
+
+	for _, parentRef := range parentRefs {
+		if (parentRef.Group != nil && *parentRef.Group != gatewayv1alpha2.GroupName) ||
+			(parentRef.Kind != nil && *parentRef.Kind != "Gateway") {
+			continue
+		}
+
+		namespace := route.Namespace
+		if parentRef.Namespace != nil {
+			namespace = string(*parentRef.Namespace)
+		}
+
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: string(parentRef.Name), Namespace: namespace},
+		})
+	}
+
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager. It is skipped
+// when the Gateway API CRDs aren't installed.
+func (r *GatewayReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	for _, kind := range []string{"Gateway", "HTTPRoute"} {
+		_, err := mgr.GetRESTMapper().RESTMapping(schema.GroupKind{
+			Group: gatewayv1alpha2.GroupName,
+			Kind:  kind,
+		}, gatewayv1alpha2.GroupVersion.Version)
+		if err != nil {
+			k8slog.Log.Info("Gateway API CRDs not found, Gateways are not served", "kind", kind)
+
+			return nil
+		}
+	}
+
+	// Status updates are skipped, the annotations and labels are rendered
+	pred := predicate.Or(
+		predicate.GenerationChangedPredicate{},
+		predicate.AnnotationChangedPredicate{},
+		predicate.LabelChangedPredicate{},
+	)
+
+	// OnionServices changes carry the onion address
+	err := ctrl.NewControllerManagedBy(mgr).
+		For(&gatewayv1alpha2.Gateway{}, builder.WithPredicates(pred)).
+		Owns(&torv1alpha2.OnionService{}).
+		Watches(
+			&source.Kind{Type: &gatewayv1alpha2.HTTPRoute{}},
+			handler.EnqueueRequestsFromMapFunc(findGatewaysForRoute),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}),
+		).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.ProjectConfig.MaxConcurrentReconciles.Gateway}).
+		Complete(withSharding(mgr, r.ProjectConfig.Sharding, &gatewayv1alpha2.Gateway{}, r))
+	if err != nil {
+		return errors.Wrap(err, "unable to create Gateway controller")
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tor
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+)
+
+func TestHTTPRoutesHosts(t *testing.T) {
+	port := gatewayv1alpha2.PortNumber(80)
+	route := &gatewayv1alpha2.HTTPRoute{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: gatewayv1alpha2.HTTPRouteSpec{
+			Hostnames: []gatewayv1alpha2.Hostname{"example.com", "www.example.com", "abc.onion"},
+			Rules: []gatewayv1alpha2.HTTPRouteRule{
+				{
+					BackendRefs: []gatewayv1alpha2.HTTPBackendRef{
+						{
+							BackendRef: gatewayv1alpha2.BackendRef{
+								BackendObjectReference: gatewayv1alpha2.BackendObjectReference{
+									Name: "web",
+									Port: &port,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	routes := httpRoutes(route, &gatewayv1alpha2.Listener{}, "default")
+
+	hosts := []string{}
+	for _, route := range routes {
+		hosts = append(hosts, route.Host)
+	}
+
+	// The clearnet hosts become a single route matching any host
+	want := []string{"", "abc.onion"}
+	if !reflect.DeepEqual(hosts, want) {
+		t.Errorf("httpRoutes() hosts = %v, want %v", hosts, want)
+	}
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tor
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	k8slog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	torv1alpha2 "github.com/bugfest/tor-controller/apis/tor/v1alpha2"
+	"github.com/cockroachdb/errors"
+)
+
+// privateKeySecretAnnotation names the Secret holding the onion service keys
+// of an Ingress or a Gateway, keeping its address when it is re-created.
+const privateKeySecretAnnotation = "tor.k8s.torproject.org/private-key-secret"
+
+// generatedOnionService returns the OnionService exposing owner, with the
+// same name and namespace.
+func generatedOnionService(
+	owner client.Object,
+	ownerKind schema.GroupVersionKind,
+	rules []torv1alpha2.ServiceRule,
+) *torv1alpha2.OnionService {
+	onion := &torv1alpha2.OnionService{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      owner.GetName(),
+			Namespace: owner.GetNamespace(),
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(owner, ownerKind),
+			},
+		},
+		Spec: torv1alpha2.OnionServiceSpec{
+			Rules:   rules,
+			Version: 3,
+		},
+	}
+
+	// Both stay in the same shard
+	if shard, ok := owner.GetLabels()[shardLabel]; ok {
+		onion.Labels = map[string]string{shardLabel: shard}
+	}
+
+	if secret := owner.GetAnnotations()[privateKeySecretAnnotation]; secret != "" {
+		onion.Spec.PrivateKeySecret.Name = secret
+	}
+
+	return onion
+}
+
+// onionRouteHosts returns the Host headers the generated routes match. Tor
+// clients send the onion address, so clearnet hosts match any host instead.
+func onionRouteHosts(hosts []string) []string {
+	routeHosts := []string{}
+	seen := map[string]bool{}
+
+	for _, host := range hosts {
+		if !strings.HasSuffix(strings.ToLower(host), ".onion") {
+			host = ""
+		}
+
+		if !seen[host] {
+			seen[host] = true
+
+			routeHosts = append(routeHosts, host)
+		}
+	}
+
+	return routeHosts
+}
+
+// routeConflicts describes the routes of rule shadowed by an earlier route
+// matching the same requests with another backend. Clearnet hosts all match
+// the onion address, so their routes may conflict once generated.
+func routeConflicts(rule *torv1alpha2.ServiceRule) []string {
+	conflicts := []string{}
+
+	for i := range rule.HTTP {
+		route := &rule.HTTP[i]
+
+		for j := 0; j < i; j++ {
+			first := &rule.HTTP[j]
+
+			if first.Host != route.Host || first.Path != route.Path || first.PathType != route.PathType ||
+				reflect.DeepEqual(first.Backend, route.Backend) {
+				continue
+			}
+
+			host := route.Host
+			if host == "" {
+				host = "any host"
+			}
+
+			conflicts = append(conflicts, fmt.Sprintf("port %d: the %s path %s of %s routes to %s, not to %s",
+				rule.Port.Number, route.PathType, route.Path, host, backendName(&first.Backend), backendName(&route.Backend)))
+
+			break
+		}
+	}
+
+	return conflicts
+}
+
+// backendName returns the <name>:<port> of a backend Service.
+func backendName(backend *torv1alpha2.OnionServiceBackend) string {
+	if backend.Service == nil {
+		return "an unsupported backend"
+	}
+
+	name := backend.Service.Name
+	if backend.Namespace != "" {
+		name = backend.Namespace + "/" + name
+	}
+
+	if backend.Service.Port.Name != "" {
+		return name + ":" + backend.Service.Port.Name
+	}
+
+	return fmt.Sprintf("%s:%d", name, backend.Service.Port.Number)
+}
+
+// reconcileGeneratedOnionService creates or updates the OnionService
+// generated for owner, or deletes it when desired is nil because owner isn't
+// served anymore. It returns the current OnionService, nil when there is
+// none.
+func reconcileGeneratedOnionService(
+	ctx context.Context,
+	c client.Client,
+	owner client.Object,
+	desired *torv1alpha2.OnionService,
+) (*torv1alpha2.OnionService, error) {
+	logger := k8slog.FromContext(ctx)
+
+	var onion torv1alpha2.OnionService
+
+	err := c.Get(ctx, client.ObjectKeyFromObject(owner), &onion)
+	if apierrors.IsNotFound(err) {
+		if desired == nil {
+			return nil, nil
+		}
+
+		err := c.Create(ctx, desired)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create OnionService %s", desired.Name)
+		}
+
+		return desired, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "failed to get OnionService %s", owner.GetName())
+	}
+
+	if !metav1.IsControlledBy(&onion, owner) {
+		if desired != nil {
+			recordNotControlled("OnionService", &onion, owner)
+			logger.Info("OnionService already exists and is not controlled by",
+				"OnionService", onion.Name,
+				"controller", owner.GetName())
+		}
+
+		return nil, nil
+	}
+
+	if desired == nil {
+		err = c.Delete(ctx, &onion)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to delete OnionService %s", onion.Name)
+		}
+
+		return nil, nil
+	}
+
+	// Only the generated fields are kept in sync, the others may be edited
+	if !reflect.DeepEqual(onion.Spec.Rules, desired.Spec.Rules) ||
+		onion.Spec.PrivateKeySecret != desired.Spec.PrivateKeySecret ||
+		onion.Labels[shardLabel] != desired.Labels[shardLabel] {
+		onion.Spec.Rules = desired.Spec.Rules
+		onion.Spec.PrivateKeySecret = desired.Spec.PrivateKeySecret
+
+		if shard, ok := desired.Labels[shardLabel]; ok {
+			if onion.Labels == nil {
+				onion.Labels = map[string]string{}
+			}
+
+			onion.Labels[shardLabel] = shard
+		} else {
+			delete(onion.Labels, shardLabel)
+		}
+
+		err = c.Update(ctx, &onion)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to update OnionService %s", onion.Name)
+		}
+	}
+
+	return &onion, nil
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tor
+
+import (
+	"reflect"
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+
+	torv1alpha2 "github.com/bugfest/tor-controller/apis/tor/v1alpha2"
+)
+
+func TestOnionRouteHosts(t *testing.T) {
+	tests := []struct {
+		name  string
+		hosts []string
+		want  []string
+	}{
+		{"none", []string{}, []string{}},
+		{"clearnet hosts", []string{"example.com", "www.example.com"}, []string{""}},
+		{"onion hosts", []string{"abc.onion", "*.abc.onion"}, []string{"abc.onion", "*.abc.onion"}},
+		{"onion host, other case", []string{"ABC.ONION"}, []string{"ABC.ONION"}},
+		{"mixed", []string{"example.com", "abc.onion", ""}, []string{"", "abc.onion"}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if got := onionRouteHosts(tt.hosts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("onionRouteHosts(%v) = %v, want %v", tt.hosts, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouteConflicts(t *testing.T) {
+	backend := func(name string) torv1alpha2.OnionServiceBackend {
+		return torv1alpha2.OnionServiceBackend{
+			IngressBackend: networkingv1.IngressBackend{
+				Service: &networkingv1.IngressServiceBackend{
+					Name: name,
+					Port: networkingv1.ServiceBackendPort{Number: 80},
+				},
+			},
+		}
+	}
+
+	route := func(host, path, service string) torv1alpha2.OnionServiceHTTPRoute {
+		return torv1alpha2.OnionServiceHTTPRoute{
+			Host:     host,
+			Path:     path,
+			PathType: networkingv1.PathTypePrefix,
+			Backend:  backend(service),
+		}
+	}
+
+	tests := []struct {
+		name   string
+		routes []torv1alpha2.OnionServiceHTTPRoute
+		want   []string
+	}{
+		{
+			name:   "distinct paths",
+			routes: []torv1alpha2.OnionServiceHTTPRoute{route("", "/", "a"), route("", "/api", "b")},
+			want:   []string{},
+		},
+		{
+			name:   "same backend",
+			routes: []torv1alpha2.OnionServiceHTTPRoute{route("", "/", "a"), route("", "/", "a")},
+			want:   []string{},
+		},
+		{
+			name:   "distinct onion hosts",
+			routes: []torv1alpha2.OnionServiceHTTPRoute{route("a.onion", "/", "a"), route("b.onion", "/", "b")},
+			want:   []string{},
+		},
+		{
+			name: "collapsed clearnet hosts",
+			routes: []torv1alpha2.OnionServiceHTTPRoute{
+				route("", "/", "a"), route("", "/", "b"), route("", "/", "c"),
+			},
+			want: []string{
+				"port 80: the Prefix path / of any host routes to a:80, not to b:80",
+				"port 80: the Prefix path / of any host routes to a:80, not to c:80",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			rule := &torv1alpha2.ServiceRule{
+				Port: networkingv1.ServiceBackendPort{Number: 80},
+				HTTP: tt.routes,
+			}
+
+			if got := routeConflicts(rule); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("routeConflicts() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tor
+
+import (
+	"context"
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	configv2 "github.com/bugfest/tor-controller/apis/config/v2"
+	torv1alpha2 "github.com/bugfest/tor-controller/apis/tor/v1alpha2"
+	"github.com/cockroachdb/errors"
+)
+
+// IngressControllerName is the controller of the IngressClasses exposed
+// through onion services.
+const IngressControllerName = "tor.k8s.torproject.org/ingress-controller"
+
+//nolint:gomnd // HTTP
+const ingressPort = 80
+
+// IngressReconciler exposes the Ingresses of the tor IngressClasses through
+// generated OnionServices.
+type IngressReconciler struct {
+	client.Client
+	Scheme        *runtime.Scheme
+	Recorder      record.EventRecorder
+	ProjectConfig configv2.ProjectConfig
+}
+
+//+kubebuilder:rbac:groups="networking.k8s.io",resources=ingresses,verbs=get;list;watch
+//+kubebuilder:rbac:groups="networking.k8s.io",resources=ingresses/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups="networking.k8s.io",resources=ingressclasses,verbs=get
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;update;patch
+
+// Reconcile keeps the OnionService of an Ingress in sync with its rules, and
+// publishes the onion address in the Ingress status.
+func (r *IngressReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var ingress networkingv1.Ingress
+
+	err := r.Get(ctx, req.NamespacedName, &ingress)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(client.IgnoreNotFound(err), "unable to fetch Ingress")
+	}
+
+	served, err := r.servedIngress(ctx, &ingress)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	var desired *torv1alpha2.OnionService
+	if served {
+		desired = ingressOnionService(&ingress)
+
+		// Only the first of the conflicting rules is served
+		for i := range desired.Spec.Rules {
+			for _, conflict := range routeConflicts(&desired.Spec.Rules[i]) {
+				r.Recorder.Event(&ingress, corev1.EventTypeWarning, "RouteConflict", conflict)
+			}
+		}
+	}
+
+	onion, err := reconcileGeneratedOnionService(ctx, r.Client, &ingress, desired)
+	if err != nil || onion == nil {
+		return ctrl.Result{}, err
+	}
+
+	var loadBalancer []corev1.LoadBalancerIngress
+	if onion.Status.Hostname != "" {
+		loadBalancer = append(loadBalancer, corev1.LoadBalancerIngress{Hostname: onion.Status.Hostname})
+	}
+
+	if reflect.DeepEqual(ingress.Status.LoadBalancer.Ingress, loadBalancer) {
+		return ctrl.Result{}, nil
+	}
+
+	ingress.Status.LoadBalancer.Ingress = loadBalancer
+
+	err = r.Status().Update(ctx, &ingress)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "unable to update Ingress status")
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// servedIngress tells whether the IngressClass of ingress is a tor one.
+func (r *IngressReconciler) servedIngress(ctx context.Context, ingress *networkingv1.Ingress) (bool, error) {
+	if ingress.Spec.IngressClassName == nil {
+		return false, nil
+	}
+
+	var class networkingv1.IngressClass
+
+	err := r.Get(ctx, types.NamespacedName{Name: *ingress.Spec.IngressClassName}, &class)
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, errors.Wrapf(err, "failed to get IngressClass %s", *ingress.Spec.IngressClassName)
+	}
+
+	return class.Spec.Controller == IngressControllerName, nil
+}
+
+// ingressOnionService routes the HTTP requests to the onion address like the
+// Ingress rules do. TLS settings are ignored, onion services are already end
+// to end encrypted.
+func ingressOnionService(ingress *networkingv1.Ingress) *torv1alpha2.OnionService {
+	routes := []torv1alpha2.OnionServiceHTTPRoute{}
+
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+
+		host := onionRouteHosts([]string{rule.Host})[0]
+
+		for _, path := range rule.HTTP.Paths {
+			route := torv1alpha2.OnionServiceHTTPRoute{
+				Host:     host,
+				Path:     path.Path,
+				PathType: networkingv1.PathTypePrefix,
+				Backend:  torv1alpha2.OnionServiceBackend{IngressBackend: path.Backend},
+			}
+
+			// ImplementationSpecific paths are prefixes
+			if path.PathType != nil && *path.PathType == networkingv1.PathTypeExact {
+				route.PathType = networkingv1.PathTypeExact
+			}
+
+			if route.Path == "" {
+				route.Path = "/"
+			}
+
+			routes = append(routes, route)
+		}
+	}
+
+	// Gets the requests no rule matches, ties go to the first route
+	if ingress.Spec.DefaultBackend != nil {
+		routes = append(routes, torv1alpha2.OnionServiceHTTPRoute{
+			Path:     "/",
+			PathType: networkingv1.PathTypePrefix,
+			Backend:  torv1alpha2.OnionServiceBackend{IngressBackend: *ingress.Spec.DefaultBackend},
+		})
+	}
+
+	rules := []torv1alpha2.ServiceRule{}
+
+	if len(routes) > 0 {
+		rules = append(rules, torv1alpha2.ServiceRule{
+			Port: networkingv1.ServiceBackendPort{Number: ingressPort},
+			HTTP: routes,
+		})
+	}
+
+	return generatedOnionService(ingress, networkingv1.SchemeGroupVersion.WithKind("Ingress"), rules)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *IngressReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// Status updates are skipped, the annotations and labels are rendered
+	pred := predicate.Or(
+		predicate.GenerationChangedPredicate{},
+		predicate.AnnotationChangedPredicate{},
+		predicate.LabelChangedPredicate{},
+	)
+
+	// OnionServices changes carry the onion address
+	err := ctrl.NewControllerManagedBy(mgr).
+		For(&networkingv1.Ingress{}, builder.WithPredicates(pred)).
+		Owns(&torv1alpha2.OnionService{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.ProjectConfig.MaxConcurrentReconciles.Ingress}).
+		Complete(withSharding(mgr, r.ProjectConfig.Sharding, &networkingv1.Ingress{}, r))
+	if err != nil {
+		return errors.Wrap(err, "unable to create Ingress controller")
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tor
+
+import (
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// A typical Ingress written for a clearnet host must serve the onion address.
+func TestIngressOnionServiceHosts(t *testing.T) {
+	prefix := networkingv1.PathTypePrefix
+	backend := networkingv1.IngressBackend{
+		Service: &networkingv1.IngressServiceBackend{
+			Name: "web",
+			Port: networkingv1.ServiceBackendPort{Number: 80},
+		},
+	}
+
+	tests := []struct {
+		name string
+		host string
+		want string
+	}{
+		{"clearnet host", "example.com", ""},
+		{"clearnet wildcard", "*.example.com", ""},
+		{"no host", "", ""},
+		{"onion host", "abc.onion", "abc.onion"},
+		{"onion wildcard", "*.abc.onion", "*.abc.onion"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ingress := &networkingv1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+				Spec: networkingv1.IngressSpec{
+					Rules: []networkingv1.IngressRule{
+						{
+							Host: tt.host,
+							IngressRuleValue: networkingv1.IngressRuleValue{
+								HTTP: &networkingv1.HTTPIngressRuleValue{
+									Paths: []networkingv1.HTTPIngressPath{
+										{Path: "/", PathType: &prefix, Backend: backend},
+									},
+								},
+							},
+						},
+					},
+				},
+			}
+
+			rules := ingressOnionService(ingress).Spec.Rules
+			if len(rules) != 1 || len(rules[0].HTTP) != 1 {
+				t.Fatalf("ingressOnionService() rules = %+v, want a single route", rules)
+			}
+
+			if got := rules[0].HTTP[0].Host; got != tt.want {
+				t.Errorf("ingressOnionService() host = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,277 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	k8slog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	configv2 "github.com/bugfest/tor-controller/apis/config/v2"
+	torv1alpha2 "github.com/bugfest/tor-controller/apis/tor/v1alpha2"
+)
+
+const (
+	metricsNamespace = "tor_controller"
+
+	// Onion service states reported by tor_controller_onion_services
+	onionServiceStatePending       = "pending"
+	onionServiceStateReady         = "ready"
+	onionServiceStateConfigInvalid = "config_invalid"
+
+	metricsListTimeout = 10 * time.Second
+)
+
+var (
+	keysGenerated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "keys_generated_total",
+		Help:      "Number of onion service keys generated, by kind of owner.",
+	}, []string{"kind"})
+
+	hostnamePublishDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "hostname_publish_duration_seconds",
+		Help:      "Time from OnionService creation to its hostname being published in the status.",
+		//nolint:gomnd // 5s to ~40m
+		Buckets: prometheus.ExponentialBuckets(5, 2, 10),
+	})
+
+	notControlledResourcesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "not_controlled_resources"),
+		"Number of child resources that exist and are not controlled by their owner, by kind.",
+		[]string{"kind"}, nil,
+	)
+
+	onionServicesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "onion_services"),
+		"Number of OnionServices, by state.",
+		[]string{"state"}, nil,
+	)
+
+	onionBalancedServiceBackendsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "onionbalancedservice", "backends"),
+		"Backends of an OnionBalancedService, desired or ready (with a published hostname).",
+		[]string{"namespace", "name", "type"}, nil,
+	)
+)
+
+// RegisterMetrics adds the operator metrics to the controller-runtime
+// registry, served with the manager metrics. States are read from reader,
+// usually the manager cache, and only report the resources of this shard.
+func RegisterMetrics(reader client.Reader, sharding *configv2.ShardingConfig) {
+	metrics.Registry.MustRegister(
+		keysGenerated,
+		hostnamePublishDuration,
+		&stateCollector{reader: reader, sharding: sharding},
+	)
+}
+
+// notControlledResource is a child resource found by a reconciler to be
+// owned by someone else.
+type notControlledResource struct {
+	kind   string
+	object client.Object
+	owner  client.Object
+}
+
+// notControlledResources holds the conflicts found by the reconcilers. The
+// collector drops them once the child or the owner is gone, or the child is
+// adopted.
+var notControlledResources = struct {
+	sync.Mutex
+	resources map[string]notControlledResource
+}{resources: map[string]notControlledResource{}}
+
+func recordNotControlled(kind string, object, owner client.Object) {
+	objectCopy, ok := object.DeepCopyObject().(client.Object)
+	if !ok {
+		return
+	}
+
+	ownerCopy, ok := owner.DeepCopyObject().(client.Object)
+	if !ok {
+		return
+	}
+
+	notControlledResources.Lock()
+	defer notControlledResources.Unlock()
+
+	key := fmt.Sprintf("%s/%s/%s", kind, object.GetNamespace(), object.GetName())
+	notControlledResources.resources[key] = notControlledResource{
+		kind:   kind,
+		object: objectCopy,
+		owner:  ownerCopy,
+	}
+}
+
+// stateCollector reports the state of the onion services on every scrape.
+type stateCollector struct {
+	reader   client.Reader
+	sharding *configv2.ShardingConfig
+}
+
+func (c *stateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- onionServicesDesc
+	ch <- onionBalancedServiceBackendsDesc
+	ch <- notControlledResourcesDesc
+}
+
+func (c *stateCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), metricsListTimeout)
+	defer cancel()
+
+	logger := k8slog.FromContext(ctx)
+
+	c.collectNotControlled(ctx, ch)
+
+	var onionServices torv1alpha2.OnionServiceList
+
+	err := c.reader.List(ctx, &onionServices)
+	if err != nil {
+		logger.Error(err, "unable to list OnionServices for metrics")
+	} else {
+		states := map[string]int{
+			onionServiceStatePending:       0,
+			onionServiceStateReady:         0,
+			onionServiceStateConfigInvalid: 0,
+		}
+
+		for i := range onionServices.Items {
+			if !ownsShard(c.sharding, &onionServices.Items[i]) {
+				continue
+			}
+
+			states[onionServiceState(&onionServices.Items[i])]++
+		}
+
+		for state, count := range states {
+			ch <- prometheus.MustNewConstMetric(onionServicesDesc, prometheus.GaugeValue, float64(count), state)
+		}
+	}
+
+	var onionBalancedServices torv1alpha2.OnionBalancedServiceList
+
+	err = c.reader.List(ctx, &onionBalancedServices)
+	if err != nil {
+		logger.Error(err, "unable to list OnionBalancedServices for metrics")
+
+		return
+	}
+
+	for i := range onionBalancedServices.Items {
+		obs := &onionBalancedServices.Items[i]
+		if !ownsShard(c.sharding, obs) {
+			continue
+		}
+
+		ready := 0
+
+		for _, backend := range obs.Status.Backends {
+			if backend.Hostname != "" {
+				ready++
+			}
+		}
+
+		ch <- prometheus.MustNewConstMetric(onionBalancedServiceBackendsDesc, prometheus.GaugeValue,
+			float64(obs.Spec.GetBackends()), obs.Namespace, obs.Name, "desired")
+		ch <- prometheus.MustNewConstMetric(onionBalancedServiceBackendsDesc, prometheus.GaugeValue,
+			float64(ready), obs.Namespace, obs.Name, "ready")
+	}
+}
+
+func (c *stateCollector) collectNotControlled(ctx context.Context, ch chan<- prometheus.Metric) {
+	notControlledResources.Lock()
+	defer notControlledResources.Unlock()
+
+	counts := map[string]int{}
+
+	for key, resource := range notControlledResources.resources {
+		if !c.stillNotControlled(ctx, resource) {
+			delete(notControlledResources.resources, key)
+
+			continue
+		}
+
+		counts[resource.kind]++
+	}
+
+	for kind, count := range counts {
+		ch <- prometheus.MustNewConstMetric(notControlledResourcesDesc, prometheus.GaugeValue, float64(count), kind)
+	}
+}
+
+// stillNotControlled reports whether both the child resource and its owner
+// still exist, and the child is not controlled by the owner. Read errors
+// keep the conflict.
+func (c *stateCollector) stillNotControlled(ctx context.Context, resource notControlledResource) bool {
+	for _, obj := range []client.Object{resource.owner, resource.object} {
+		err := c.reader.Get(ctx, client.ObjectKeyFromObject(obj), obj)
+		if apierrors.IsNotFound(err) {
+			return false
+		}
+	}
+
+	return !metav1.IsControlledBy(resource.object, resource.owner)
+}
+
+func onionServiceState(onion *torv1alpha2.OnionService) string {
+	if meta.IsStatusConditionFalse(onion.Status.Conditions, torv1alpha2.OnionServiceConfigValid) {
+		return onionServiceStateConfigInvalid
+	}
+
+	if onion.Status.Hostname == "" {
+		return onionServiceStatePending
+	}
+
+	return onionServiceStateReady
+}
+
+// hostnamePublishedObserver measures how long the OnionServices of this shard
+// take to get a hostname. It doesn't filter any event.
+func hostnamePublishedObserver(sharding *configv2.ShardingConfig) predicate.Funcs {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldOnion, ok := e.ObjectOld.(*torv1alpha2.OnionService)
+			if !ok {
+				return true
+			}
+
+			newOnion, ok := e.ObjectNew.(*torv1alpha2.OnionService)
+			if !ok {
+				return true
+			}
+
+			if oldOnion.Status.Hostname == "" && newOnion.Status.Hostname != "" && ownsShard(sharding, newOnion) {
+				hostnamePublishDuration.Observe(time.Since(newOnion.CreationTimestamp.Time).Seconds())
+			}
+
+			return true
+		},
+	}
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tor
+
+import (
+	"context"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	torv1alpha2 "github.com/bugfest/tor-controller/apis/tor/v1alpha2"
+)
+
+const metricsPath = "/metrics"
+
+// monitorLabels adds the user labels to the selector labels. The selector
+// labels win, they are used to find the metrics Service.
+func monitorLabels(selector map[string]string, spec *torv1alpha2.MonitoringSpec) map[string]string {
+	labels := map[string]string{}
+
+	for k, v := range spec.Labels {
+		labels[k] = v
+	}
+
+	for k, v := range selector {
+		labels[k] = v
+	}
+
+	return labels
+}
+
+func relabelConfigs(configs []monitoringv1.RelabelConfig) []*monitoringv1.RelabelConfig {
+	if len(configs) == 0 {
+		return nil
+	}
+
+	relabelings := make([]*monitoringv1.RelabelConfig, 0, len(configs))
+
+	for i := range configs {
+		relabelings = append(relabelings, configs[i].DeepCopy())
+	}
+
+	return relabelings
+}
+
+// serviceMonitorEndpoints scrapes the given ports of the metrics Service.
+func serviceMonitorEndpoints(spec *torv1alpha2.MonitoringSpec, ports ...string) []monitoringv1.Endpoint {
+	endpoints := []monitoringv1.Endpoint{}
+
+	for _, port := range ports {
+		endpoints = append(endpoints, monitoringv1.Endpoint{
+			Port:                 port,
+			Path:                 metricsPath,
+			Interval:             spec.Interval,
+			ScrapeTimeout:        spec.ScrapeTimeout,
+			RelabelConfigs:       relabelConfigs(spec.Relabelings),
+			MetricRelabelConfigs: relabelConfigs(spec.MetricRelabelings),
+		})
+	}
+
+	return endpoints
+}
+
+// podMetricsEndpoints scrapes the given ports of the pods.
+func podMetricsEndpoints(spec *torv1alpha2.MonitoringSpec, ports ...string) []monitoringv1.PodMetricsEndpoint {
+	endpoints := []monitoringv1.PodMetricsEndpoint{}
+
+	for _, port := range ports {
+		endpoints = append(endpoints, monitoringv1.PodMetricsEndpoint{
+			Port:                 port,
+			Path:                 metricsPath,
+			Interval:             spec.Interval,
+			ScrapeTimeout:        spec.ScrapeTimeout,
+			RelabelConfigs:       relabelConfigs(spec.Relabelings),
+			MetricRelabelConfigs: relabelConfigs(spec.MetricRelabelings),
+		})
+	}
+
+	return endpoints
+}
+
+func podMonitoringInstalled(ctx context.Context, reader client.Reader) bool {
+	var monitoring apiextensionsv1.CustomResourceDefinition
+	err := reader.Get(ctx, types.NamespacedName{Name: "podmonitors.monitoring.coreos.com"}, &monitoring)
+
+	return !apierrors.IsNotFound(err)
+}
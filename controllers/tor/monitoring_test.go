@@ -0,0 +1,61 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tor
+
+import (
+	"encoding/json"
+	"testing"
+
+	torv1alpha2 "github.com/bugfest/tor-controller/apis/tor/v1alpha2"
+)
+
+func TestMonitoring(t *testing.T) {
+	tests := []struct {
+		name           string
+		spec           string
+		serviceMonitor bool
+		podMonitor     bool
+	}{
+		{"disabled", `{}`, false, false},
+		{"boolean field", `{"serviceMonitor": true}`, true, false},
+		{"monitoring", `{"monitoring": {"enable": true}}`, true, false},
+		{"pod monitor", `{"monitoring": {"enable": true, "kind": "PodMonitor"}}`, false, true},
+		{"boolean field with pod monitor settings", `{"serviceMonitor": true, "monitoring": {"kind": "PodMonitor"}}`, false, true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var onion torv1alpha2.OnionService
+
+			err := json.Unmarshal([]byte(tt.spec), &onion.Spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			monitoring := onion.Monitoring()
+
+			if got := monitoring.ServiceMonitorEnabled(); got != tt.serviceMonitor {
+				t.Errorf("ServiceMonitorEnabled() = %v, want %v", got, tt.serviceMonitor)
+			}
+
+			if got := monitoring.PodMonitorEnabled(); got != tt.podMonitor {
+				t.Errorf("PodMonitorEnabled() = %v, want %v", got, tt.podMonitor)
+			}
+		})
+	}
+}
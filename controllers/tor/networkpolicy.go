@@ -0,0 +1,190 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tor
+
+import (
+	"context"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	configv2 "github.com/bugfest/tor-controller/apis/config/v2"
+	torv1alpha2 "github.com/bugfest/tor-controller/apis/tor/v1alpha2"
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	dnsPort = 53
+
+	// namespaceNameLabel is set by Kubernetes on every namespace.
+	namespaceNameLabel = "kubernetes.io/metadata.name"
+
+	// apiServerService is the Service of the API server, in the default
+	// namespace.
+	apiServerService = "kubernetes"
+)
+
+var (
+	// Private and link-local ranges are not part of the internet
+	privateIPv4Blocks = []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "169.254.0.0/16", "100.64.0.0/10"}
+	privateIPv6Blocks = []string{"fc00::/7", "fe80::/10"}
+
+	// Ports of the Kubernetes API server, used by the tor agents along with
+	// apiServerCIDRs
+	apiServerPorts = []int{443, 6443}
+)
+
+func networkPolicyPort(protocol corev1.Protocol, port intstr.IntOrString) networkingv1.NetworkPolicyPort {
+	return networkingv1.NetworkPolicyPort{
+		Protocol: &protocol,
+		Port:     &port,
+	}
+}
+
+// internetEgress allows DNS and connections to public addresses, needed by
+// tor to join the network.
+func internetEgress() []networkingv1.NetworkPolicyEgressRule {
+	return []networkingv1.NetworkPolicyEgressRule{
+		{
+			Ports: []networkingv1.NetworkPolicyPort{
+				networkPolicyPort(corev1.ProtocolUDP, intstr.FromInt(dnsPort)),
+				networkPolicyPort(corev1.ProtocolTCP, intstr.FromInt(dnsPort)),
+			},
+		},
+		{
+			To: []networkingv1.NetworkPolicyPeer{
+				{IPBlock: &networkingv1.IPBlock{CIDR: "0.0.0.0/0", Except: privateIPv4Blocks}},
+				{IPBlock: &networkingv1.IPBlock{CIDR: "::/0", Except: privateIPv6Blocks}},
+			},
+		},
+	}
+}
+
+// apiServerEgress allows the tor agents to watch their resource. Only the
+// API server is allowed: the CIDRs of the project config, or else the
+// endpoints of the kubernetes Service, as policies apply after the Service
+// address is translated.
+func apiServerEgress(
+	ctx context.Context,
+	reader client.Reader,
+	projectConfig *configv2.ProjectConfig,
+) (networkingv1.NetworkPolicyEgressRule, error) {
+	rule := networkingv1.NetworkPolicyEgressRule{}
+
+	if len(projectConfig.APIServerCIDRs) > 0 {
+		for _, cidr := range projectConfig.APIServerCIDRs {
+			rule.To = append(rule.To, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}})
+		}
+
+		for _, port := range apiServerPorts {
+			rule.Ports = append(rule.Ports, networkPolicyPort(corev1.ProtocolTCP, intstr.FromInt(port)))
+		}
+
+		return rule, nil
+	}
+
+	var endpoints corev1.Endpoints
+
+	err := reader.Get(ctx, types.NamespacedName{Name: apiServerService, Namespace: metav1.NamespaceDefault}, &endpoints)
+	if err != nil {
+		return rule, errors.Wrap(err, "unable to get the API server endpoints, set apiServerCIDRs in the project config")
+	}
+
+	for _, subset := range endpoints.Subsets {
+		for _, address := range subset.Addresses {
+			rule.To = append(rule.To, networkingv1.NetworkPolicyPeer{
+				IPBlock: &networkingv1.IPBlock{CIDR: hostCIDR(address.IP)},
+			})
+		}
+
+		for _, port := range subset.Ports {
+			rule.Ports = append(rule.Ports, networkPolicyPort(port.Protocol, intstr.FromInt(int(port.Port))))
+		}
+	}
+
+	if len(rule.To) == 0 {
+		return rule, errors.New("the API server endpoints have no address, set apiServerCIDRs in the project config")
+	}
+
+	return rule, nil
+}
+
+// hostCIDR returns the CIDR matching the single address ip.
+func hostCIDR(ip string) string {
+	if strings.Contains(ip, ":") {
+		return ip + "/128"
+	}
+
+	return ip + "/32"
+}
+
+// metricsIngress allows the selected namespaces to scrape the metrics ports.
+func metricsIngress(spec *torv1alpha2.NetworkPolicySpec, ports ...int) []networkingv1.NetworkPolicyIngressRule {
+	if spec.MetricsNamespaceSelector == nil {
+		return nil
+	}
+
+	rule := networkingv1.NetworkPolicyIngressRule{
+		From: []networkingv1.NetworkPolicyPeer{
+			{NamespaceSelector: spec.MetricsNamespaceSelector.DeepCopy()},
+		},
+	}
+
+	for _, port := range ports {
+		rule.Ports = append(rule.Ports, networkPolicyPort(corev1.ProtocolTCP, intstr.FromInt(port)))
+	}
+
+	return []networkingv1.NetworkPolicyIngressRule{rule}
+}
+
+// torNetworkPolicy builds the policy of the pods matching podLabels, adding
+// the user rules to the generated ones.
+func torNetworkPolicy(
+	meta metav1.ObjectMeta,
+	podLabels map[string]string,
+	spec *torv1alpha2.NetworkPolicySpec,
+	ingress []networkingv1.NetworkPolicyIngressRule,
+	egress []networkingv1.NetworkPolicyEgressRule,
+) *networkingv1.NetworkPolicy {
+	for i := range spec.ExtraIngress {
+		ingress = append(ingress, *spec.ExtraIngress[i].DeepCopy())
+	}
+
+	for i := range spec.ExtraEgress {
+		egress = append(egress, *spec.ExtraEgress[i].DeepCopy())
+	}
+
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: meta,
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: podLabels,
+			},
+			Ingress: ingress,
+			Egress:  egress,
+			PolicyTypes: []networkingv1.PolicyType{
+				networkingv1.PolicyTypeIngress,
+				networkingv1.PolicyTypeEgress,
+			},
+		},
+	}
+}
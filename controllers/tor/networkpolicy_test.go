@@ -0,0 +1,111 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tor
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	configv2 "github.com/bugfest/tor-controller/apis/config/v2"
+)
+
+func TestAPIServerEgress(t *testing.T) {
+	apiServerEndpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: "kubernetes", Namespace: "default"},
+		Subsets: []corev1.EndpointSubset{
+			{
+				Addresses: []corev1.EndpointAddress{{IP: "172.18.0.2"}, {IP: "fd00::2"}},
+				Ports:     []corev1.EndpointPort{{Name: "https", Port: 6443, Protocol: corev1.ProtocolTCP}},
+			},
+		},
+	}
+
+	tcp := func(port int) networkingv1.NetworkPolicyPort {
+		return networkPolicyPort(corev1.ProtocolTCP, intstr.FromInt(port))
+	}
+
+	peer := func(cidr string) networkingv1.NetworkPolicyPeer {
+		return networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}}
+	}
+
+	tests := []struct {
+		name    string
+		objects []client.Object
+		cidrs   []string
+		want    networkingv1.NetworkPolicyEgressRule
+		wantErr bool
+	}{
+		{
+			name:    "kubernetes endpoints",
+			objects: []client.Object{apiServerEndpoints},
+			want: networkingv1.NetworkPolicyEgressRule{
+				To:    []networkingv1.NetworkPolicyPeer{peer("172.18.0.2/32"), peer("fd00::2/128")},
+				Ports: []networkingv1.NetworkPolicyPort{tcp(6443)},
+			},
+		},
+		{
+			name:    "configured CIDRs",
+			objects: []client.Object{apiServerEndpoints},
+			cidrs:   []string{"10.0.0.0/24"},
+			want: networkingv1.NetworkPolicyEgressRule{
+				To:    []networkingv1.NetworkPolicyPeer{peer("10.0.0.0/24")},
+				Ports: []networkingv1.NetworkPolicyPort{tcp(443), tcp(6443)},
+			},
+		},
+		{
+			name:    "endpoints not readable",
+			wantErr: true,
+		},
+		{
+			name: "endpoints without address",
+			objects: []client.Object{&corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{Name: "kubernetes", Namespace: "default"},
+			}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			reader := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(tt.objects...).Build()
+			projectConfig := &configv2.ProjectConfig{APIServerCIDRs: tt.cidrs}
+
+			got, err := apiServerEgress(context.Background(), reader, projectConfig)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("apiServerEgress() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("apiServerEgress() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
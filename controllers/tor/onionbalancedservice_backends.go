@@ -99,6 +99,9 @@ func onionBalancedServiceBackend(onion *torv1alpha2.OnionBalancedService, _ *con
 	onionServiceSpec.Version = onion.Spec.Version
 	onionServiceSpec.MasterOnionAddress = onion.Status.Hostname
 
+	// The backends would publish their own addresses to the same targets
+	onionServiceSpec.Publish = torv1alpha2.PublishSpec{}
+
 	return &torv1alpha2.OnionService{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      onion.OnionServiceBackendName(idx),
@@ -41,6 +41,7 @@ const (
 	MetricsPortPolicy = "accept 0.0.0.0/0"
 
 	configFormat = `# Config automatically generated
+DataDirectory /var/lib/tor/data
 SocksPort {{ .SocksPort }}
 ControlPort {{ .ControlPort }}
 MetricsPort {{ .MetricsPort }}
@@ -88,6 +89,7 @@ func (r *OnionBalancedServiceReconciler) reconcileConfigMap(
 	}
 
 	if !metav1.IsControlledBy(&configmap.ObjectMeta, onionBalancedService) {
+		recordNotControlled("ConfigMap", &configmap, onionBalancedService)
 		logger.Info("configmap already exists and is not controlled by onionbalancedservice",
 			"configmap", configmap.Name,
 			"onionbalancedservice", onionBalancedService.Name,
@@ -26,6 +26,7 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	k8slog "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
@@ -58,6 +59,8 @@ type OnionBalancedServiceReconciler struct {
 //+kubebuilder:rbac:groups="apps",resources=deployments,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups="",resources=events,verbs=create;update;patch
 //+kubebuilder:rbac:groups="monitoring.coreos.com",resources=servicemonitors,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="monitoring.coreos.com",resources=podmonitors,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="networking.k8s.io",resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups="apiextensions.k8s.io",resources=customresourcedefinitions,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
@@ -140,6 +143,16 @@ func (r *OnionBalancedServiceReconciler) Reconcile(ctx context.Context, req ctrl
 		return ctrl.Result{}, err
 	}
 
+	err = r.reconcilePodMonitor(ctx, &OnionBalancedService)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	err = r.reconcileNetworkPolicy(ctx, &OnionBalancedService)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
 	// Finally, we update the status block of the OnionBalancedService resource to reflect the
 	// current state of the world
 	OnionBalancedServiceCopy := OnionBalancedService.DeepCopy()
@@ -206,10 +219,13 @@ func (r *OnionBalancedServiceReconciler) Reconcile(ctx context.Context, req ctrl
 func (r *OnionBalancedServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	pred := predicate.GenerationChangedPredicate{}
 
-	err := ctrl.NewControllerManagedBy(mgr).
+	bldr := ctrl.NewControllerManagedBy(mgr).
 		For(&torv1alpha2.OnionBalancedService{}).
 		WithEventFilter(pred).
-		Complete(r)
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.ProjectConfig.MaxConcurrentReconciles.OnionBalancedService})
+
+	err := watchPolicies(mgr, bldr, &torv1alpha2.OnionBalancedServiceList{}).
+		Complete(withSharding(mgr, r.ProjectConfig.Sharding, &torv1alpha2.OnionBalancedService{}, r))
 	if err != nil {
 		return errors.Wrap(err, "unable to create OnionBalancedService controller")
 	}
@@ -41,8 +41,7 @@ const (
 	torConfigMountDir  = "/run/tor"
 	privateKeyMounPath = torConfigMountDir + "key"
 
-	torFile   = "/run/tor/torfile"
-	torBinary = "/usr/local/bin/tor"
+	torFile = "/run/tor/torfile"
 )
 
 func (r *OnionBalancedServiceReconciler) reconcileDeployment(ctx context.Context, onionBalancedService *torv1alpha2.OnionBalancedService) error {
@@ -60,12 +59,19 @@ func (r *OnionBalancedServiceReconciler) reconcileDeployment(ctx context.Context
 		return nil
 	}
 
-	var deployment appsv1.Deployment
-	err := r.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: namespace}, &deployment)
+	projectConfig, err := projectConfigFor(ctx, r, &r.ProjectConfig, namespace)
+	if err != nil {
+		return err
+	}
 
 	// If the deployment doesn't exist, we'll create it
-	projectConfig := r.ProjectConfig
-	newDeployment := onionbalanceDeployment(onionBalancedService, &projectConfig)
+	newDeployment, err := onionbalanceDeployment(onionBalancedService, projectConfig)
+	if err != nil {
+		return errors.Wrapf(err, "failed to build Deployment %s", deploymentName)
+	}
+
+	var deployment appsv1.Deployment
+	err = r.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: namespace}, &deployment)
 
 	// log.Infof(" %#v", *newDeployment))
 
@@ -86,6 +92,7 @@ func (r *OnionBalancedServiceReconciler) reconcileDeployment(ctx context.Context
 	// If the Deployment is not controlled by this Foo resource, we should log
 	// a warning to the event recorder and ret
 	if !metav1.IsControlledBy(&deployment.ObjectMeta, onionBalancedService) {
+		recordNotControlled("Deployment", &deployment, onionBalancedService)
 		logger.Info("deployment already exists and not controlled by - skipping update",
 			"deployment", deployment.Name,
 			"controller", onionBalancedService.Name)
@@ -104,7 +111,7 @@ func (r *OnionBalancedServiceReconciler) reconcileDeployment(ctx context.Context
 	return nil
 }
 
-func onionbalanceDeployment(onion *torv1alpha2.OnionBalancedService, projectConfig *configv2.ProjectConfig) *appsv1.Deployment {
+func onionbalanceDeployment(onion *torv1alpha2.OnionBalancedService, projectConfig *configv2.ProjectConfig) (*appsv1.Deployment, error) {
 	volumes := []corev1.Volume{
 		{
 			Name: onionBalanceConfigVolume,
@@ -152,6 +159,13 @@ func onionbalanceDeployment(onion *torv1alpha2.OnionBalancedService, projectConf
 				},
 			},
 		},
+		emptyDirVolume(torDataVolume),
+		emptyDirVolume(tmpVolume),
+	}
+
+	tmpVolumeMount := corev1.VolumeMount{
+		Name:      tmpVolume,
+		MountPath: "/tmp",
 	}
 
 	onionBalanceVolumeMounts := []corev1.VolumeMount{
@@ -163,6 +177,7 @@ func onionbalanceDeployment(onion *torv1alpha2.OnionBalancedService, projectConf
 			Name:      onionBalanceSecretVolume,
 			MountPath: onionBalanceSecretMountPath,
 		},
+		tmpVolumeMount,
 	}
 
 	torVolumeMounts := []corev1.VolumeMount{
@@ -174,46 +189,45 @@ func onionbalanceDeployment(onion *torv1alpha2.OnionBalancedService, projectConf
 			Name:      privateKeyVolume,
 			MountPath: privateKeyMounPath,
 		},
+		{
+			Name:      torDataVolume,
+			MountPath: "/var/lib/tor",
+		},
+		tmpVolumeMount,
 	}
 
-	// Fetch Pod Template
-	podTemplate := onion.PodTemplate()
-
-	// Add Labels to template
-	if podTemplate.ObjectMeta.Labels == nil {
-		// Set deployment labels
-		podTemplate.ObjectMeta.Labels = onion.DeploymentLabels()
-	} else {
-		// Add tor labels to existing template labels
-		for k, v := range onion.DeploymentLabels() {
-			// TODO: should we throw an error if a label was already set?
-			podTemplate.ObjectMeta.Labels[k] = v
-		}
-	}
-
-	// Set Onion balancer daemon service pod properties
-	podTemplate.Spec.ServiceAccountName = onion.ServiceAccountName()
-	podTemplate.Spec.Volumes = append(podTemplate.Spec.Volumes, volumes...)
-	podTemplate.Spec.Containers = append(podTemplate.Spec.Containers,
-		corev1.Container{
+	containers := []corev1.Container{
+		{
 			Name:  "onionbalance",
-			Image: projectConfig.TorOnionbalanceManager.Image,
+			Image: projectConfig.TorOnionbalanceManager.ImageRef(),
 			Args: []string{
 				"-name", onion.Name,
 				"-namespace", onion.Namespace,
 			},
-			ImagePullPolicy: "Always",
+			ImagePullPolicy: projectConfig.TorOnionbalanceManager.PullPolicy(),
+			SecurityContext: componentSecurityContext(&projectConfig.TorOnionbalanceManager.ComponentDefaults),
 			VolumeMounts:    onionBalanceVolumeMounts,
-			Resources:       onion.BalancerResources(),
+			Ports: []corev1.ContainerPort{
+				{
+					Name:          "agent-metrics",
+					Protocol:      "TCP",
+					ContainerPort: agentMetricsPort,
+				},
+			},
+			Resources: containerResources(onion.BalancerResources(), &projectConfig.TorOnionbalanceManager.ComponentDefaults),
 		},
-		corev1.Container{
-			Name:    "tor",
-			Image:   projectConfig.TorDaemonManager.Image, // TODO: use a dedicated Tor image
-			Command: []string{torBinary},
+		{
+			Name:  "tor",
+			Image: projectConfig.TorDaemonManager.ImageRef(), // TODO: use a dedicated Tor image
+			// The tor agent runs tor with the static torfile and serves
+			// its health checks
 			Args: []string{
+				"standalone",
 				"-f", torFile,
+				"-control", controlAddress,
 			},
-			ImagePullPolicy: "Always",
+			ImagePullPolicy: projectConfig.TorDaemonManager.PullPolicy(),
+			SecurityContext: componentSecurityContext(&projectConfig.TorDaemonManager.ComponentDefaults),
 			VolumeMounts:    torVolumeMounts,
 			Ports: []corev1.ContainerPort{
 				{
@@ -221,11 +235,52 @@ func onionbalanceDeployment(onion *torv1alpha2.OnionBalancedService, projectConf
 					Protocol:      "TCP",
 					ContainerPort: metricsPort,
 				},
+				{
+					Name:          "health",
+					Protocol:      "TCP",
+					ContainerPort: healthPort,
+				},
 			},
-			Resources: onion.TorResources(),
+			LivenessProbe:  torLivenessProbe(),
+			ReadinessProbe: torReadinessProbe(),
+			Resources:      containerResources(onion.TorResources(), &projectConfig.TorDaemonManager.ComponentDefaults),
+		},
+	}
+
+	generated := corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: onion.DeploymentLabels(),
 		},
+		Spec: corev1.PodSpec{
+			Volumes:    volumes,
+			Containers: containers,
+		},
+	}
+
+	applyComponentDefaults(&generated.Spec,
+		&projectConfig.TorOnionbalanceManager.ComponentDefaults,
+		&projectConfig.TorDaemonManager.ComponentDefaults,
 	)
 
+	// Merge the user's Pod Template, which can tweak the generated containers
+	userTemplate := onion.PodTemplate()
+
+	podTemplate, err := mergePodTemplate(&generated, &userTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	// The deployment labels back the selector, so they win over the
+	// template ones
+	for k, v := range onion.DeploymentLabels() {
+		podTemplate.ObjectMeta.Labels[k] = v
+	}
+
+	// Set Onion balancer daemon service pod properties
+	podTemplate.Spec.ServiceAccountName = onion.ServiceAccountName()
+	// The onionbalance agent watches the backends, so it needs the token
+	applyPodSecurityDefaults(&podTemplate.Spec, true)
+
 	return &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      onion.DeploymentName(),
@@ -244,5 +299,5 @@ func onionbalanceDeployment(onion *torv1alpha2.OnionBalancedService, projectConf
 			},
 			Template: podTemplate,
 		},
-	}
+	}, nil
 }
@@ -0,0 +1,124 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tor
+
+import (
+	"context"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	k8slog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/cockroachdb/errors"
+
+	torv1alpha2 "github.com/bugfest/tor-controller/apis/tor/v1alpha2"
+)
+
+func (r *OnionBalancedServiceReconciler) reconcileNetworkPolicy(ctx context.Context, onionBalancedService *torv1alpha2.OnionBalancedService) error {
+	logger := k8slog.FromContext(ctx)
+
+	policyName := onionBalancedService.NetworkPolicyName()
+	namespace := onionBalancedService.Namespace
+
+	var policy networkingv1.NetworkPolicy
+	err := r.Get(ctx, types.NamespacedName{Name: policyName, Namespace: namespace}, &policy)
+
+	if apierrors.IsNotFound(err) {
+		if !onionBalancedService.Spec.NetworkPolicy.Enable {
+			// NetworkPolicy is not requested, skipping
+			return nil
+		}
+	} else if err != nil {
+		return errors.Wrapf(err, "failed to get NetworkPolicy %s", policyName)
+	}
+
+	if err == nil && !metav1.IsControlledBy(&policy.ObjectMeta, onionBalancedService) {
+		recordNotControlled("NetworkPolicy", &policy, onionBalancedService)
+		logger.Info("NetworkPolicy already exists and is not controlled by",
+			"NetworkPolicy", policy.Name,
+			"controller", onionBalancedService.Name)
+
+		return nil
+	}
+
+	if !onionBalancedService.Spec.NetworkPolicy.Enable {
+		// NetworkPolicy is not requested but exists, deleting
+		err = r.Delete(ctx, &policy)
+		if err != nil {
+			return errors.Wrapf(err, "failed to delete NetworkPolicy %s", policyName)
+		}
+
+		return nil
+	}
+
+	apiServer, egressErr := apiServerEgress(ctx, r.Client, &r.ProjectConfig)
+	if egressErr != nil {
+		return egressErr
+	}
+
+	newPolicy := obsTorNetworkPolicy(onionBalancedService, apiServer)
+	if apierrors.IsNotFound(err) {
+		err := r.Create(ctx, newPolicy)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create NetworkPolicy %s", policyName)
+		}
+
+		return nil
+	}
+
+	// If the policy specs don't match, update
+	if !networkPolicyEqual(&policy, newPolicy) {
+		err := r.Update(ctx, newPolicy)
+		if err != nil {
+			return errors.Wrapf(err, "failed to update NetworkPolicy %s", policyName)
+		}
+	}
+
+	return nil
+}
+
+// obsTorNetworkPolicy lets the balancer pods reach the tor network and the
+// API server (the onionbalance agent), and be scraped. Backend descriptors
+// are fetched through tor.
+func obsTorNetworkPolicy(
+	onion *torv1alpha2.OnionBalancedService,
+	apiServer networkingv1.NetworkPolicyEgressRule,
+) *networkingv1.NetworkPolicy {
+	egress := internetEgress()
+	egress = append(egress, apiServer)
+
+	return torNetworkPolicy(
+		metav1.ObjectMeta{
+			Name:      onion.NetworkPolicyName(),
+			Namespace: onion.Namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(onion, schema.GroupVersionKind{
+					Group:   torv1alpha2.GroupVersion.Group,
+					Version: torv1alpha2.GroupVersion.Version,
+					Kind:    "OnionBalancedService",
+				}),
+			},
+		},
+		onion.DeploymentLabels(),
+		&onion.Spec.NetworkPolicy,
+		metricsIngress(&onion.Spec.NetworkPolicy, metricsPort, agentMetricsPort),
+		egress,
+	)
+}
@@ -62,6 +62,7 @@ func (r *OnionBalancedServiceReconciler) reconcileRole(ctx context.Context, onio
 	}
 
 	if !metav1.IsControlledBy(&role.ObjectMeta, onionBalancedService) {
+		recordNotControlled("Role", &role, onionBalancedService)
 		logger.Info("role already exists and is not controlled by",
 			"role", role.Name,
 			"controller", onionBalancedService.Name)
@@ -63,6 +63,7 @@ func (r *OnionBalancedServiceReconciler) reconcileRolebinding(ctx context.Contex
 	}
 
 	if !metav1.IsControlledBy(&roleBinding.ObjectMeta, onionBalancedService) {
+		recordNotControlled("RoleBinding", &roleBinding, onionBalancedService)
 		logger.Info("RoleBinding already exists and is not controlled by",
 			"RoleBinding", roleBinding.Name,
 			"controller", onionBalancedService.Name)
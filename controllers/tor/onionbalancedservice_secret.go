@@ -56,6 +56,8 @@ func (r *OnionBalancedServiceReconciler) reconcileSecret(ctx context.Context, on
 			return errors.Wrap(err, "failed to create secret")
 		}
 
+		keysGenerated.WithLabelValues("OnionBalancedService").Inc()
+
 		secret = *newSecret
 	} else if err != nil {
 		return errors.Wrap(err, "failed to get secret")
@@ -70,6 +72,7 @@ func (r *OnionBalancedServiceReconciler) reconcileSecret(ctx context.Context, on
 		// msg := fmt.Sprintf(MessageResourceExists, service.Name)
 		// bc.recorder.Event(OnionBalancedService, corev1.EventTypeWarning, ErrResourceExists, msg)
 		// return errors.New(msg)
+		recordNotControlled("Secret", &secret, onionBalancedService)
 		logger.Info("secret already exists and is not controlled by",
 			"secret", secret.Name,
 			"controller", onionBalancedService.Name)
@@ -66,6 +66,7 @@ func (r *OnionBalancedServiceReconciler) reconcileService(
 	}
 
 	if !metav1.IsControlledBy(&service.ObjectMeta, onionBalancedService) {
+		recordNotControlled("Service", &service, onionBalancedService)
 		logger.Info("Service already exists and is not controlled by",
 			"service", service.Name,
 			"controller", onionBalancedService.Name)
@@ -34,6 +34,9 @@ import (
 
 const (
 	metricsPort = 9035
+
+	// agentMetricsPort exposes the metrics of the management process
+	agentMetricsPort = 9036
 )
 
 func (r *OnionBalancedServiceReconciler) reconcileMetricsService(ctx context.Context, onionBalancedService *torv1alpha2.OnionBalancedService) error {
@@ -67,6 +70,7 @@ func (r *OnionBalancedServiceReconciler) reconcileMetricsService(ctx context.Con
 	}
 
 	if !metav1.IsControlledBy(&service.ObjectMeta, onionBalancedService) {
+		recordNotControlled("Service", &service, onionBalancedService)
 		logger.Info("service already exists and is not controlled by",
 			"service", service.Name,
 			"controller", onionBalancedService.Name)
@@ -101,11 +105,18 @@ func obsTorMetricsService(onion *torv1alpha2.OnionBalancedService) *corev1.Servi
 		},
 		Spec: corev1.ServiceSpec{
 			Selector: onion.ServiceSelector(),
-			Ports: []corev1.ServicePort{{
-				Name:       "metrics",
-				TargetPort: intstr.FromInt(metricsPort),
-				Port:       metricsPort,
-			}},
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "metrics",
+					TargetPort: intstr.FromInt(metricsPort),
+					Port:       metricsPort,
+				},
+				{
+					Name:       "agent-metrics",
+					TargetPort: intstr.FromInt(agentMetricsPort),
+					Port:       agentMetricsPort,
+				},
+			},
 		},
 	}
 }
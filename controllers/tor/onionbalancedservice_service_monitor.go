@@ -62,7 +62,7 @@ func (r *OnionBalancedServiceReconciler) reconcileServiceMonitor(
 	newService := obsTorServiceMonitor(onionBalancedService)
 
 	if apierrors.IsNotFound(err) {
-		if !onionBalancedService.Spec.ServiceMonitor {
+		if !onionBalancedService.Monitoring().ServiceMonitorEnabled() {
 			// ServiceMonitor is not requested, skipping
 			return nil
 		}
@@ -78,6 +78,7 @@ func (r *OnionBalancedServiceReconciler) reconcileServiceMonitor(
 	}
 
 	if !metav1.IsControlledBy(&service.ObjectMeta, onionBalancedService) {
+		recordNotControlled("ServiceMonitor", &service, onionBalancedService)
 		logger.Info("ServiceMonitor already exists and is not controlled by",
 			"ServiceMonitor", service.Name,
 			"controller", onionBalancedService.Name)
@@ -85,7 +86,7 @@ func (r *OnionBalancedServiceReconciler) reconcileServiceMonitor(
 		return nil
 	}
 
-	if !onionBalancedService.Spec.ServiceMonitor {
+	if !onionBalancedService.Monitoring().ServiceMonitorEnabled() {
 		// ServiceMonitor is not requested but exists, deleting
 		err = r.Delete(ctx, &service)
 		if err != nil {
@@ -113,7 +114,7 @@ func obsTorServiceMonitor(onion *torv1alpha2.OnionBalancedService) *monitoringv1
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      onion.ServiceMetricsName(),
 			Namespace: onion.Namespace,
-			Labels:    onion.ServiceMetricsSelector(),
+			Labels:    monitorLabels(onion.ServiceMetricsSelector(), onion.Monitoring()),
 			OwnerReferences: []metav1.OwnerReference{
 				*metav1.NewControllerRef(onion, schema.GroupVersionKind{
 					Group:   torv1alpha2.GroupVersion.Group,
@@ -126,12 +127,7 @@ func obsTorServiceMonitor(onion *torv1alpha2.OnionBalancedService) *monitoringv1
 			Selector: metav1.LabelSelector{
 				MatchLabels: onion.ServiceMetricsSelector(),
 			},
-			Endpoints: []monitoringv1.Endpoint{
-				{
-					Port: "metrics",
-					Path: "/metrics",
-				},
-			},
+			Endpoints: serviceMonitorEndpoints(onion.Monitoring(), "metrics", "agent-metrics"),
 			NamespaceSelector: monitoringv1.NamespaceSelector{
 				MatchNames: []string{
 					onion.Namespace,
@@ -62,6 +62,7 @@ func (r *OnionBalancedServiceReconciler) reconcileServiceAccount(ctx context.Con
 	}
 
 	if !metav1.IsControlledBy(&serviceAccount.ObjectMeta, onionBalancedService) {
+		recordNotControlled("ServiceAccount", &serviceAccount, onionBalancedService)
 		logger.Info("ServiceAccount already exists and is not controlled by",
 			"ServiceAccount", serviceAccount.Name,
 			"controller", onionBalancedService.Name)
@@ -0,0 +1,128 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tor
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	k8slog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	configv2 "github.com/bugfest/tor-controller/apis/config/v2"
+	torv1alpha2 "github.com/bugfest/tor-controller/apis/tor/v1alpha2"
+	"github.com/cockroachdb/errors"
+)
+
+// OnionEndpointReconciler reconciles a OnionEndpoint object.
+type OnionEndpointReconciler struct {
+	client.Client
+	Scheme        *runtime.Scheme
+	ProjectConfig configv2.ProjectConfig
+}
+
+//+kubebuilder:rbac:groups=tor.k8s.torproject.org,resources=onionendpoints,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=tor.k8s.torproject.org,resources=onionendpoints/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=tor.k8s.torproject.org,resources=onionendpoints/finalizers,verbs=update
+//+kubebuilder:rbac:groups=tor.k8s.torproject.org,resources=tors,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;patch;delete
+
+// Reconcile makes a remote onion service reachable inside the cluster: it
+// manages a Tor client running a TCP forwarder to the onion address, and a
+// ClusterIP service in front of it named after the OnionEndpoint.
+func (r *OnionEndpointReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := k8slog.FromContext(ctx)
+
+	var onionEndpoint torv1alpha2.OnionEndpoint
+
+	err := r.Get(ctx, req.NamespacedName, &onionEndpoint)
+	if err != nil {
+		// The OnionEndpoint resource may no longer exist, in which case we stop
+		// processing.
+		logger.Error(err, "unable to fetch OnionEndpoint")
+
+		// we'll ignore not-found errors, since they can't be fixed by an immediate
+		// requeue (we'll need to wait for a new notification), and we can get them
+		// on deleted requests.
+		return ctrl.Result{}, errors.Wrap(client.IgnoreNotFound(err), "unable to fetch OnionEndpoint")
+	}
+
+	namespace := onionEndpoint.Namespace
+
+	err = r.reconcileTor(ctx, &onionEndpoint)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	err = r.reconcileService(ctx, &onionEndpoint)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Finally, we update the status block of the OnionEndpoint resource to reflect the
+	// current state of the world
+	onionEndpointCopy := onionEndpoint.DeepCopy()
+	serviceName := onionEndpoint.ServiceName()
+
+	var service corev1.Service
+	err = r.Get(ctx, types.NamespacedName{Name: serviceName, Namespace: namespace}, &service)
+
+	switch {
+	case apierrors.IsNotFound(err):
+		onionEndpointCopy.Status.ClusterIP = defaultClusterIP
+	case err != nil:
+		return ctrl.Result{}, errors.Wrap(err, "unable to get service")
+	default:
+		onionEndpointCopy.Status.ClusterIP = service.Spec.ClusterIP
+	}
+
+	onionEndpointCopy.Status.ServiceName = serviceName
+
+	if err := r.Status().Update(ctx, onionEndpointCopy); err != nil {
+		logger.Error(err, "unable to update OnionEndpoint status")
+
+		return ctrl.Result{}, errors.Wrap(err, "unable to update OnionEndpoint status")
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *OnionEndpointReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	pred := predicate.GenerationChangedPredicate{}
+
+	bldr := ctrl.NewControllerManagedBy(mgr).
+		For(&torv1alpha2.OnionEndpoint{}, builder.WithPredicates(pred)).
+		Owns(&torv1alpha2.Tor{}).
+		Owns(&corev1.Service{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.ProjectConfig.MaxConcurrentReconciles.OnionEndpoint})
+
+	err := watchPolicies(mgr, bldr, &torv1alpha2.OnionEndpointList{}).
+		Complete(withSharding(mgr, r.ProjectConfig.Sharding, &torv1alpha2.OnionEndpoint{}, r))
+	if err != nil {
+		return errors.Wrap(err, "unable to create OnionEndpoint controller")
+	}
+
+	return nil
+}
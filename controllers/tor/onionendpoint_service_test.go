@@ -0,0 +1,50 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tor
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	torv1alpha2 "github.com/bugfest/tor-controller/apis/tor/v1alpha2"
+)
+
+func TestOnionEndpointServicePortNames(t *testing.T) {
+	onionEndpoint := &torv1alpha2.OnionEndpoint{
+		ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "default"},
+		Spec: torv1alpha2.OnionEndpointSpec{
+			Ports: []torv1alpha2.OnionEndpointPort{
+				{Port: 80},
+				{Name: "https", Port: 443},
+			},
+		},
+	}
+
+	service := onionEndpointService(onionEndpoint)
+
+	want := []string{"port-80", "https"}
+	if len(service.Spec.Ports) != len(want) {
+		t.Fatalf("got %d ports, want %d", len(service.Spec.Ports), len(want))
+	}
+
+	for i, port := range service.Spec.Ports {
+		if port.Name != want[i] {
+			t.Errorf("port %d name = %q, want %q", port.Port, port.Name, want[i])
+		}
+	}
+}
@@ -0,0 +1,247 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tor
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	k8slog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	configv2 "github.com/bugfest/tor-controller/apis/config/v2"
+	torv1alpha2 "github.com/bugfest/tor-controller/apis/tor/v1alpha2"
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// The forwarder runs as non-root, so it listens on unprivileged ports
+	// and the service maps them back to the requested ones
+	onionEndpointForwarderBasePort = 20000
+	onionEndpointSocksPort         = 9050
+
+	onionEndpointAuthKeyVolume = "client-auth-key"
+	onionEndpointAuthKeyDir    = "/run/tor/client-auth-key"
+)
+
+func (r *OnionEndpointReconciler) reconcileTor(ctx context.Context, onionEndpoint *torv1alpha2.OnionEndpoint) error {
+	logger := k8slog.FromContext(ctx)
+
+	torName := onionEndpoint.TorName()
+	namespace := onionEndpoint.Namespace
+
+	projectConfig, err := projectConfigFor(ctx, r, &r.ProjectConfig, namespace)
+	if err != nil {
+		return err
+	}
+
+	newTor, err := onionEndpointTor(onionEndpoint, projectConfig)
+	if err != nil {
+		return errors.Wrapf(err, "failed to build Tor %s/%s", namespace, torName)
+	}
+
+	var tor torv1alpha2.Tor
+	err = r.Get(ctx, types.NamespacedName{Name: torName, Namespace: namespace}, &tor)
+
+	if apierrors.IsNotFound(err) {
+		err := r.Create(ctx, newTor)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create Tor %s/%s", namespace, torName)
+		}
+
+		tor = *newTor
+	} else if err != nil {
+		return errors.Wrapf(err, "failed to get Tor %s/%s", namespace, torName)
+	}
+
+	if !metav1.IsControlledBy(&tor.ObjectMeta, onionEndpoint) {
+		recordNotControlled("Tor", &tor, onionEndpoint)
+		logger.Info("Tor already exists and is not controlled by",
+			"tor", tor.Name,
+			"controller", onionEndpoint.Name)
+
+		return nil
+	}
+
+	// If the fields we manage don't match, update. The rest of the spec is
+	// defaulted by the API server and left as is
+	if !onionEndpointTorSpecEqual(&tor.Spec, &newTor.Spec) {
+		tor.Spec.Replicas = newTor.Spec.Replicas
+		tor.Spec.Template = newTor.Spec.Template
+		tor.Spec.Client.Socks = newTor.Spec.Client.Socks
+		tor.Spec.Config = newTor.Spec.Config
+
+		err := r.Update(ctx, &tor)
+		if err != nil {
+			return errors.Wrapf(err, "failed to update Tor %s/%s", namespace, torName)
+		}
+	}
+
+	return nil
+}
+
+// onionEndpointTor returns a Tor client with socks enabled on localhost only
+// and a forwarder sidecar relaying every port to the remote onion service.
+func onionEndpointTor(onionEndpoint *torv1alpha2.OnionEndpoint, projectConfig *configv2.ProjectConfig) (*torv1alpha2.Tor, error) {
+	template := *onionEndpoint.Spec.Template.DeepCopy()
+	generated := corev1.PodTemplateSpec{}
+
+	forwarderArgs := []string{"forward", "-socks", fmt.Sprintf("127.0.0.1:%d", onionEndpointSocksPort)}
+	forwarderPorts := []corev1.ContainerPort{}
+
+	for i, port := range onionEndpoint.Spec.Ports {
+		listenPort := onionEndpointForwarderPort(i)
+
+		forwarderArgs = append(forwarderArgs, "-map",
+			fmt.Sprintf("%d=%s:%d", listenPort, onionEndpoint.Spec.Address, port.GetTargetPort()))
+		forwarderPorts = append(forwarderPorts, corev1.ContainerPort{
+			Name:          fmt.Sprintf("forward-%d", i),
+			Protocol:      "TCP",
+			ContainerPort: listenPort,
+		})
+	}
+
+	generated.Spec.Containers = append(generated.Spec.Containers, corev1.Container{
+		Name:            "forwarder",
+		Image:           projectConfig.TorDaemonManager.ImageRef(),
+		Args:            forwarderArgs,
+		ImagePullPolicy: projectConfig.TorDaemonManager.PullPolicy(),
+		SecurityContext: componentSecurityContext(&projectConfig.TorDaemonManager.ComponentDefaults),
+		Resources:       *projectConfig.TorDaemonManager.Resources.DeepCopy(),
+		Ports:           forwarderPorts,
+	})
+
+	config := ""
+
+	if onionEndpoint.Spec.ClientAuthKeySecret.Name != "" {
+		// tor reads client authorization keys from a private directory with
+		// one <name>.auth_private file per onion service. The init container
+		// writes it in tor's data volume from the secret
+		authFile := fmt.Sprintf("%s/%s.auth_private", torv1alpha2.OnionEndpointAuthDir, onionEndpoint.Name)
+		authLine := fmt.Sprintf("%s:descriptor:x25519:", strings.TrimSuffix(onionEndpoint.Spec.Address, ".onion"))
+
+		key := onionEndpoint.Spec.ClientAuthKeySecret.Key
+		if key == "" {
+			key = "privateKey"
+		}
+
+		generated.Spec.Volumes = append(generated.Spec.Volumes, corev1.Volume{
+			Name: onionEndpointAuthKeyVolume,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: onionEndpoint.Spec.ClientAuthKeySecret.Name,
+					Items: []corev1.KeyToPath{{
+						Key:  key,
+						Path: "key",
+					}},
+				},
+			},
+		})
+
+		generated.Spec.InitContainers = append(generated.Spec.InitContainers, corev1.Container{
+			Name:  "client-auth",
+			Image: projectConfig.TorDaemon.ImageRef(),
+			Command: []string{"/bin/sh", "-c", strings.Join([]string{
+				fmt.Sprintf("mkdir -p %s", torv1alpha2.OnionEndpointAuthDir),
+				fmt.Sprintf("echo \"%s$(cat %s/key)\" > %s", authLine, onionEndpointAuthKeyDir, authFile),
+				fmt.Sprintf("chmod 700 %s", torv1alpha2.OnionEndpointAuthDir),
+				fmt.Sprintf("chmod 600 %s", authFile),
+			}, " && ")},
+			ImagePullPolicy: projectConfig.TorDaemon.PullPolicy(),
+			SecurityContext: componentSecurityContext(&projectConfig.TorDaemon.ComponentDefaults),
+			VolumeMounts: []corev1.VolumeMount{
+				{
+					Name:      onionEndpointAuthKeyVolume,
+					MountPath: onionEndpointAuthKeyDir,
+					ReadOnly:  true,
+				},
+				{
+					// tor's data volume, created by the Tor controller
+					Name:      torDataVolume,
+					MountPath: "/var/lib/tor",
+				},
+			},
+		})
+
+		config = "ClientOnionAuthDir " + torv1alpha2.OnionEndpointAuthDir
+	}
+
+	applyComponentDefaults(&generated.Spec, &projectConfig.TorDaemonManager.ComponentDefaults)
+
+	// Merge the user's Pod Template, which can tweak the generated containers
+	podTemplate, err := mergePodTemplate(&generated, &corev1.PodTemplateSpec{
+		ObjectMeta: template.ObjectMeta,
+		Spec:       template.Spec,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	template.ObjectMeta = podTemplate.ObjectMeta
+	template.Spec = podTemplate.Spec
+
+	tor := &torv1alpha2.Tor{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      onionEndpoint.TorName(),
+			Namespace: onionEndpoint.Namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(onionEndpoint, schema.GroupVersionKind{
+					Group:   torv1alpha2.GroupVersion.Group,
+					Version: torv1alpha2.GroupVersion.Version,
+					Kind:    "OnionEndpoint",
+				}),
+			},
+		},
+		Spec: torv1alpha2.TorSpec{
+			Replicas: 1,
+			Template: template,
+			Client: torv1alpha2.TorClientSpec{
+				Socks: torv1alpha2.TorGenericPortWithFlagSpec{
+					TorGenericPortSpec: torv1alpha2.TorGenericPortSpec{
+						Enable:  true,
+						Port:    onionEndpointSocksPort,
+						Address: []string{"127.0.0.1"},
+					},
+					Policy: []string{"accept 127.0.0.1"},
+				},
+			},
+			Config: config,
+		},
+	}
+
+	return tor, nil
+}
+
+// onionEndpointTorSpecEqual compares the Tor spec fields set by
+// onionEndpointTor.
+func onionEndpointTorSpecEqual(spec1, spec2 *torv1alpha2.TorSpec) bool {
+	return spec1.Replicas == spec2.Replicas &&
+		equality.Semantic.DeepEqual(spec1.Template, spec2.Template) &&
+		reflect.DeepEqual(spec1.Client.Socks, spec2.Client.Socks) &&
+		spec1.Config == spec2.Config
+}
+
+func onionEndpointForwarderPort(index int) int32 {
+	return int32(onionEndpointForwarderBasePort + index)
+}
@@ -0,0 +1,513 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tor
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	k8slog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	configv2 "github.com/bugfest/tor-controller/apis/config/v2"
+	torv1alpha2 "github.com/bugfest/tor-controller/apis/tor/v1alpha2"
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// onionLocationAnnotation names the OnionService, in the namespace of
+	// the Ingress, advertised in the Onion-Location header.
+	onionLocationAnnotation = "tor.k8s.torproject.org/onion-location"
+
+	// onionLocationProviderAnnotation overrides the ingress controller
+	// detected from the IngressClass: nginx or traefik.
+	onionLocationProviderAnnotation = "tor.k8s.torproject.org/onion-location-provider"
+
+	nginxSnippetAnnotation      = "nginx.ingress.kubernetes.io/configuration-snippet"
+	traefikMiddlewareAnnotation = "traefik.ingress.kubernetes.io/router.middlewares"
+
+	nginxSnippetBegin = "# BEGIN tor-controller onion-location"
+	nginxSnippetEnd   = "# END tor-controller onion-location"
+)
+
+// onionLocationProvider is an ingress controller the header can be set for.
+type onionLocationProvider string
+
+const (
+	onionLocationNginx   onionLocationProvider = "nginx"
+	onionLocationTraefik onionLocationProvider = "traefik"
+)
+
+// onionLocationProviders maps the controllers of the IngressClasses.
+var onionLocationProviders = map[string]onionLocationProvider{
+	"k8s.io/ingress-nginx":          onionLocationNginx,
+	"traefik.io/ingress-controller": onionLocationTraefik,
+}
+
+// traefikMiddlewareGVKs are the Middleware kinds of Traefik, the current
+// group first. Traefik before v2.10 only serves the legacy one.
+var traefikMiddlewareGVKs = []schema.GroupVersionKind{
+	{Group: "traefik.io", Version: "v1alpha1", Kind: "Middleware"},
+	{Group: "traefik.containo.us", Version: "v1alpha1", Kind: "Middleware"},
+}
+
+var errUnknownProvider = errors.New("unknown Onion-Location provider")
+
+// OnionLocationReconciler advertises the onion address of an OnionService in
+// the Onion-Location header of the responses of a clearnet Ingress.
+type OnionLocationReconciler struct {
+	client.Client
+	Scheme        *runtime.Scheme
+	Recorder      record.EventRecorder
+	ProjectConfig configv2.ProjectConfig
+}
+
+//+kubebuilder:rbac:groups="networking.k8s.io",resources=ingresses,verbs=get;list;watch;update
+//+kubebuilder:rbac:groups="traefik.io",resources=middlewares,verbs=get;create;update;delete
+//+kubebuilder:rbac:groups="traefik.containo.us",resources=middlewares,verbs=get;create;update;delete
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;update;patch
+
+// Reconcile sets the Onion-Location header of an annotated Ingress once the
+// onion address is known, and removes it when the annotation is.
+func (r *OnionLocationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := k8slog.FromContext(ctx)
+
+	var ingress networkingv1.Ingress
+
+	err := r.Get(ctx, req.NamespacedName, &ingress)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(client.IgnoreNotFound(err), "unable to fetch Ingress")
+	}
+
+	location, err := r.onionLocation(ctx, &ingress)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// The header is removed until the provider is fixed
+	provider, err := r.onionLocationProvider(ctx, &ingress)
+	if errors.Is(err, errUnknownProvider) {
+		r.Recorder.Event(&ingress, corev1.EventTypeWarning, "UnknownProvider", err.Error())
+	} else if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if location != "" && provider == "" && err == nil {
+		r.Recorder.Eventf(&ingress, corev1.EventTypeWarning, "UnknownProvider",
+			"the ingress controller isn't recognized, set the %s annotation to nginx or traefik",
+			onionLocationProviderAnnotation)
+	}
+
+	nginxLocation, traefikLocation := "", ""
+
+	switch provider {
+	case onionLocationNginx:
+		nginxLocation = location
+	case onionLocationTraefik:
+		traefikLocation = location
+	}
+
+	annotations := map[string]string{}
+	for k, v := range ingress.Annotations {
+		annotations[k] = v
+	}
+
+	// Ingresses without annotations are left as is
+	if len(ingress.Annotations) == 0 && location == "" {
+		return ctrl.Result{}, nil
+	}
+
+	setNginxOnionLocation(annotations, nginxLocation)
+
+	err = r.reconcileTraefikOnionLocation(ctx, &ingress, annotations, traefikLocation)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if reflect.DeepEqual(ingress.Annotations, annotations) {
+		return ctrl.Result{}, nil
+	}
+
+	ingress.Annotations = annotations
+
+	err = r.Update(ctx, &ingress)
+	if apierrors.IsBadRequest(err) || apierrors.IsForbidden(err) || apierrors.IsInvalid(err) {
+		// Admission webhooks deny the annotations, like ingress-nginx 1.9
+		// does for snippets by default: retrying won't help until the Ingress
+		// or the webhook settings change
+		logger.Info("Ingress annotations rejected", "error", err.Error())
+		r.Recorder.Eventf(&ingress, corev1.EventTypeWarning, "OnionLocationRejected",
+			"the Onion-Location annotations were rejected: %v", err)
+
+		return ctrl.Result{}, nil
+	} else if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "unable to update Ingress annotations")
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// onionLocation returns the URL advertised for ingress, empty when it isn't
+// annotated or the onion address isn't known yet.
+func (r *OnionLocationReconciler) onionLocation(ctx context.Context, ingress *networkingv1.Ingress) (string, error) {
+	name := ingress.Annotations[onionLocationAnnotation]
+	if name == "" {
+		return "", nil
+	}
+
+	var onion torv1alpha2.OnionService
+
+	err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: ingress.Namespace}, &onion)
+	if apierrors.IsNotFound(err) {
+		return "", nil
+	} else if err != nil {
+		return "", errors.Wrapf(err, "failed to get OnionService %s", name)
+	}
+
+	if onion.Status.Hostname == "" {
+		return "", nil
+	}
+
+	return "http://" + onion.Status.Hostname, nil
+}
+
+// onionLocationProvider returns the ingress controller serving ingress, from
+// the provider annotation or its IngressClass. Empty when the IngressClass
+// isn't recognized, and an errUnknownProvider for other annotation values.
+func (r *OnionLocationReconciler) onionLocationProvider(
+	ctx context.Context,
+	ingress *networkingv1.Ingress,
+) (onionLocationProvider, error) {
+	if value, ok := ingress.Annotations[onionLocationProviderAnnotation]; ok {
+		return parseOnionLocationProvider(value)
+	}
+
+	if ingress.Spec.IngressClassName == nil {
+		return "", nil
+	}
+
+	var class networkingv1.IngressClass
+
+	err := r.Get(ctx, types.NamespacedName{Name: *ingress.Spec.IngressClassName}, &class)
+	if apierrors.IsNotFound(err) {
+		return "", nil
+	} else if err != nil {
+		return "", errors.Wrapf(err, "failed to get IngressClass %s", *ingress.Spec.IngressClassName)
+	}
+
+	return onionLocationProviders[class.Spec.Controller], nil
+}
+
+// parseOnionLocationProvider validates the provider annotation.
+func parseOnionLocationProvider(value string) (onionLocationProvider, error) {
+	switch provider := onionLocationProvider(value); provider {
+	case onionLocationNginx, onionLocationTraefik:
+		return provider, nil
+	default:
+		return "", errors.Wrapf(errUnknownProvider, "%s must be nginx or traefik, not %q",
+			onionLocationProviderAnnotation, value)
+	}
+}
+
+// setNginxOnionLocation keeps the header in a delimited block of the
+// configuration snippet, the rest of the snippet is left as is.
+func setNginxOnionLocation(annotations map[string]string, location string) {
+	lines := []string{}
+	inBlock, found := false, false
+
+	for _, line := range strings.Split(annotations[nginxSnippetAnnotation], "\n") {
+		switch {
+		case line == nginxSnippetBegin:
+			inBlock, found = true, true
+		case line == nginxSnippetEnd:
+			inBlock = false
+		case !inBlock && line != "":
+			lines = append(lines, line)
+		}
+	}
+
+	// The snippets of the users are left untouched
+	if !found && location == "" {
+		return
+	}
+
+	if location != "" {
+		lines = append(lines,
+			nginxSnippetBegin,
+			fmt.Sprintf(`more_set_headers "Onion-Location: %s$request_uri";`, location),
+			nginxSnippetEnd,
+		)
+	}
+
+	if len(lines) == 0 {
+		delete(annotations, nginxSnippetAnnotation)
+
+		return
+	}
+
+	annotations[nginxSnippetAnnotation] = strings.Join(lines, "\n") + "\n"
+}
+
+// reconcileTraefikOnionLocation keeps a headers Middleware in the router
+// middlewares of the Ingress. The Middleware is deleted with the reference.
+func (r *OnionLocationReconciler) reconcileTraefikOnionLocation(
+	ctx context.Context,
+	ingress *networkingv1.Ingress,
+	annotations map[string]string,
+	location string,
+) error {
+	name := ingress.Name + "-onion-location"
+	reference := fmt.Sprintf("%s-%s@kubernetescrd", ingress.Namespace, name)
+
+	middlewares := []string{}
+	referenced := false
+
+	for _, middleware := range strings.Split(annotations[traefikMiddlewareAnnotation], ",") {
+		middleware = strings.TrimSpace(middleware)
+
+		switch {
+		case middleware == reference:
+			referenced = true
+		case middleware != "":
+			middlewares = append(middlewares, middleware)
+		}
+	}
+
+	if !referenced && location == "" {
+		return nil
+	}
+
+	kinds, err := r.traefikMiddlewareKinds()
+	if err != nil {
+		return err
+	}
+
+	if location != "" {
+		// A missing Middleware would fail the router
+		installed, err := r.reconcileTraefikMiddleware(ctx, ingress, kinds, name, location)
+		if err != nil {
+			return err
+		}
+
+		if installed {
+			middlewares = append(middlewares, reference)
+		}
+	} else {
+		err := r.deleteTraefikMiddleware(ctx, ingress, kinds, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(middlewares) == 0 {
+		delete(annotations, traefikMiddlewareAnnotation)
+	} else {
+		annotations[traefikMiddlewareAnnotation] = strings.Join(middlewares, ",")
+	}
+
+	return nil
+}
+
+// traefikMiddlewareKinds returns the Middleware kinds served by the cluster,
+// in the order of traefikMiddlewareGVKs.
+func (r *OnionLocationReconciler) traefikMiddlewareKinds() ([]schema.GroupVersionKind, error) {
+	kinds := []schema.GroupVersionKind{}
+
+	for _, gvk := range traefikMiddlewareGVKs {
+		_, err := r.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+		if meta.IsNoMatchError(err) {
+			continue
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "failed to map %s", gvk)
+		}
+
+		kinds = append(kinds, gvk)
+	}
+
+	return kinds, nil
+}
+
+// reconcileTraefikMiddleware keeps the Middleware in the first of kinds, and
+// deletes it from the others.
+func (r *OnionLocationReconciler) reconcileTraefikMiddleware(
+	ctx context.Context,
+	ingress *networkingv1.Ingress,
+	kinds []schema.GroupVersionKind,
+	name, location string,
+) (bool, error) {
+	logger := k8slog.FromContext(ctx)
+
+	if len(kinds) == 0 {
+		logger.Info("Traefik Middleware CRD not found, the Onion-Location header is not set")
+
+		return false, nil
+	}
+
+	// Left from before an upgrade of Traefik, it would conflict
+	err := r.deleteTraefikMiddleware(ctx, ingress, kinds[1:], name)
+	if err != nil {
+		return false, err
+	}
+
+	// Traefik can't append the request URI, the root of the onion is sent
+	newMiddleware := &unstructured.Unstructured{}
+	newMiddleware.SetGroupVersionKind(kinds[0])
+	newMiddleware.SetName(name)
+	newMiddleware.SetNamespace(ingress.Namespace)
+	newMiddleware.Object["spec"] = map[string]interface{}{
+		"headers": map[string]interface{}{
+			"customResponseHeaders": map[string]interface{}{
+				"Onion-Location": location,
+			},
+		},
+	}
+
+	err = controllerutil.SetControllerReference(ingress, newMiddleware, r.Scheme)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to set controller reference")
+	}
+
+	middleware := &unstructured.Unstructured{}
+	middleware.SetGroupVersionKind(kinds[0])
+
+	err = r.Get(ctx, types.NamespacedName{Name: name, Namespace: ingress.Namespace}, middleware)
+	if apierrors.IsNotFound(err) {
+		err = r.Create(ctx, newMiddleware)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to create Middleware %s", name)
+		}
+
+		return true, nil
+	} else if err != nil {
+		return false, errors.Wrapf(err, "failed to get Middleware %s", name)
+	}
+
+	if !metav1.IsControlledBy(middleware, ingress) {
+		logger.Info(fmt.Sprintf("Middleware %s already exists and is not controlled by Ingress %s", name, ingress.Name))
+		recordNotControlled("Middleware", middleware, ingress)
+
+		return false, nil
+	}
+
+	if reflect.DeepEqual(middleware.Object["spec"], newMiddleware.Object["spec"]) {
+		return true, nil
+	}
+
+	middleware.Object["spec"] = newMiddleware.Object["spec"]
+
+	err = r.Update(ctx, middleware)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to update Middleware %s", name)
+	}
+
+	return true, nil
+}
+
+func (r *OnionLocationReconciler) deleteTraefikMiddleware(
+	ctx context.Context,
+	ingress *networkingv1.Ingress,
+	kinds []schema.GroupVersionKind,
+	name string,
+) error {
+	for _, gvk := range kinds {
+		middleware := &unstructured.Unstructured{}
+		middleware.SetGroupVersionKind(gvk)
+
+		err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: ingress.Namespace}, middleware)
+		if apierrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return errors.Wrapf(err, "failed to get Middleware %s", name)
+		}
+
+		if !metav1.IsControlledBy(middleware, ingress) {
+			continue
+		}
+
+		err = r.Delete(ctx, middleware)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete Middleware %s", name)
+		}
+	}
+
+	return nil
+}
+
+// findIngressesForOnionService returns the Ingresses advertising object.
+func (r *OnionLocationReconciler) findIngressesForOnionService(object client.Object) []reconcile.Request {
+	var ingressList networkingv1.IngressList
+
+	err := r.List(context.Background(), &ingressList, client.InNamespace(object.GetNamespace()))
+	if err != nil {
+		k8slog.Log.Error(err, "unable to list Ingresses")
+
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+
+	for i := range ingressList.Items {
+		ingress := &ingressList.Items[i]
+
+		if ingress.Annotations[onionLocationAnnotation] == object.GetName() {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: client.ObjectKeyFromObject(ingress),
+			})
+		}
+	}
+
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *OnionLocationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// OnionService status updates carry the onion address
+	err := ctrl.NewControllerManagedBy(mgr).
+		Named("onionlocation").
+		For(&networkingv1.Ingress{}, builder.WithPredicates(predicate.AnnotationChangedPredicate{})).
+		Watches(
+			&source.Kind{Type: &torv1alpha2.OnionService{}},
+			handler.EnqueueRequestsFromMapFunc(r.findIngressesForOnionService),
+		).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.ProjectConfig.MaxConcurrentReconciles.OnionLocation}).
+		Complete(withSharding(mgr, r.ProjectConfig.Sharding, &networkingv1.Ingress{}, r))
+	if err != nil {
+		return errors.Wrap(err, "unable to create OnionLocation controller")
+	}
+
+	return nil
+}
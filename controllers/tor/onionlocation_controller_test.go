@@ -0,0 +1,184 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tor
+
+import (
+	"context"
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/cockroachdb/errors"
+)
+
+func TestParseOnionLocationProvider(t *testing.T) {
+	tests := []struct {
+		value string
+		want  onionLocationProvider
+		err   error
+	}{
+		{"nginx", onionLocationNginx, nil},
+		{"traefik", onionLocationTraefik, nil},
+		{"", "", errUnknownProvider},
+		{"haproxy", "", errUnknownProvider},
+		{"Nginx", "", errUnknownProvider},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseOnionLocationProvider(tt.value)
+			if got != tt.want || !errors.Is(err, tt.err) {
+				t.Errorf("parseOnionLocationProvider(%q) = %q, %v, want %q, %v", tt.value, got, err, tt.want, tt.err)
+			}
+		})
+	}
+}
+
+// traefikRESTMapper maps the Middleware kinds of groups.
+func traefikRESTMapper(groups ...string) meta.RESTMapper {
+	mapper := meta.NewDefaultRESTMapper(nil)
+
+	for _, gvk := range traefikMiddlewareGVKs {
+		for _, group := range groups {
+			if gvk.Group == group {
+				mapper.Add(gvk, meta.RESTScopeNamespace)
+			}
+		}
+	}
+
+	return mapper
+}
+
+func testMiddleware(gvk schema.GroupVersionKind, ingress *networkingv1.Ingress) *unstructured.Unstructured {
+	middleware := &unstructured.Unstructured{}
+	middleware.SetGroupVersionKind(gvk)
+	middleware.SetName(ingress.Name + "-onion-location")
+	middleware.SetNamespace(ingress.Namespace)
+	middleware.SetOwnerReferences([]metav1.OwnerReference{
+		*metav1.NewControllerRef(ingress, networkingv1.SchemeGroupVersion.WithKind("Ingress")),
+	})
+
+	return middleware
+}
+
+func TestReconcileTraefikMiddleware(t *testing.T) {
+	current, legacy := traefikMiddlewareGVKs[0], traefikMiddlewareGVKs[1]
+
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", UID: "1234"},
+	}
+
+	tests := []struct {
+		name     string
+		groups   []string
+		existing []schema.GroupVersionKind
+		want     schema.GroupVersionKind
+		removed  []schema.GroupVersionKind
+	}{
+		{
+			name:   "current group",
+			groups: []string{current.Group, legacy.Group},
+			want:   current,
+		},
+		{
+			name:   "legacy group",
+			groups: []string{legacy.Group},
+			want:   legacy,
+		},
+		{
+			name:     "moved to the current group",
+			groups:   []string{current.Group, legacy.Group},
+			existing: []schema.GroupVersionKind{legacy},
+			want:     current,
+			removed:  []schema.GroupVersionKind{legacy},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			builder := fake.NewClientBuilder().
+				WithScheme(clientgoscheme.Scheme).
+				WithRESTMapper(traefikRESTMapper(tt.groups...))
+
+			for _, gvk := range tt.existing {
+				builder = builder.WithObjects(testMiddleware(gvk, ingress))
+			}
+
+			r := &OnionLocationReconciler{Client: builder.Build(), Scheme: clientgoscheme.Scheme}
+			ctx := context.Background()
+
+			kinds, err := r.traefikMiddlewareKinds()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			installed, err := r.reconcileTraefikMiddleware(ctx, ingress, kinds, "web-onion-location", "http://abc.onion")
+			if err != nil || !installed {
+				t.Fatalf("reconcileTraefikMiddleware() = %v, %v, want true", installed, err)
+			}
+
+			key := types.NamespacedName{Name: "web-onion-location", Namespace: "default"}
+
+			middleware := &unstructured.Unstructured{}
+			middleware.SetGroupVersionKind(tt.want)
+
+			err = r.Get(ctx, key, middleware)
+			if err != nil {
+				t.Errorf("Middleware %s not created: %v", tt.want, err)
+			}
+
+			for _, gvk := range tt.removed {
+				middleware := &unstructured.Unstructured{}
+				middleware.SetGroupVersionKind(gvk)
+
+				err = r.Get(ctx, key, middleware)
+				if !apierrors.IsNotFound(err) {
+					t.Errorf("Middleware %s not deleted: %v", gvk, err)
+				}
+			}
+		})
+	}
+}
+
+func TestReconcileTraefikMiddlewareWithoutCRD(t *testing.T) {
+	r := &OnionLocationReconciler{
+		Client: fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithRESTMapper(traefikRESTMapper()).Build(),
+		Scheme: clientgoscheme.Scheme,
+	}
+
+	kinds, err := r.traefikMiddlewareKinds()
+	if err != nil || len(kinds) != 0 {
+		t.Fatalf("traefikMiddlewareKinds() = %v, %v, want none", kinds, err)
+	}
+
+	ingress := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+
+	installed, err := r.reconcileTraefikMiddleware(context.Background(), ingress, kinds, "web-onion-location", "http://abc.onion")
+	if err != nil || installed {
+		t.Errorf("reconcileTraefikMiddleware() = %v, %v, want false", installed, err)
+	}
+}
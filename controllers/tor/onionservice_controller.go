@@ -20,12 +20,18 @@ import (
 	"context"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	k8slog "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	corev1 "k8s.io/api/core/v1"
 
@@ -52,6 +58,8 @@ type OnionServiceReconciler struct {
 //+kubebuilder:rbac:groups="apps",resources=deployments,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups="",resources=events,verbs=create;update;patch
 //+kubebuilder:rbac:groups="monitoring.coreos.com",resources=servicemonitors,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="monitoring.coreos.com",resources=podmonitors,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="networking.k8s.io",resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups="apiextensions.k8s.io",resources=customresourcedefinitions,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
@@ -80,31 +88,20 @@ func (r *OnionServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		return ctrl.Result{}, errors.Wrap(client.IgnoreNotFound(err), "unable to fetch OnionService")
 	}
 
-	namespace := onionService.Namespace
-
-	for _, rule := range onionService.Spec.Rules {
-		serviceName := rule.Backend.Service.Name
-
-		var service corev1.Service
-
-		if err := r.Get(ctx, types.NamespacedName{Name: serviceName, Namespace: namespace}, &service); err != nil {
-			logger.Error(err, "service not found")
+	if !onionService.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, r.finalizePublish(ctx, &onionService)
+	}
 
-			return ctrl.Result{}, errors.Wrap(err, "service not found")
-		}
+	namespace := onionService.Namespace
+	targets := make([]torv1alpha2.OnionServiceTarget, 0, len(onionService.Spec.Rules))
 
-		ruleBackendService := corev1.ServicePort{
-			Name:     rule.Backend.Service.Port.Name,
-			Port:     rule.Backend.Service.Port.Number,
-			Protocol: "TCP",
+	for i := range onionService.Spec.Rules {
+		target, err := r.resolveTarget(ctx, &onionService, &onionService.Spec.Rules[i])
+		if err != nil {
+			return r.backendNotResolved(ctx, &onionService, err)
 		}
 
-		if !portExists(service.Spec.Ports, &ruleBackendService) {
-			logger.Error(err, "Port not found in target service rule",
-				"ruleBackendService", ruleBackendService)
-
-			return ctrl.Result{}, errors.Wrapf(err, "port in service rule %#v not found in target service", ruleBackendService)
-		}
+		targets = append(targets, target)
 	}
 
 	err = r.reconcileSecretAuthorizedClients(ctx, &onionService)
@@ -152,6 +149,16 @@ func (r *OnionServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		return ctrl.Result{}, err
 	}
 
+	err = r.reconcilePodMonitor(ctx, &onionService)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	err = r.reconcileNetworkPolicy(ctx, &onionService)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
 	// Finally, we update the status block of the OnionService resource to reflect the
 	// current state of the world
 	onionServiceCopy := onionService.DeepCopy()
@@ -176,6 +183,14 @@ func (r *OnionServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request
 	}
 
 	onionServiceCopy.Status.TargetClusterIP = clusterIP
+	onionServiceCopy.Status.Targets = targets
+	setVanguardsCondition(onionServiceCopy)
+	setResolvedRefsCondition(onionServiceCopy, metav1.ConditionTrue, "ResolvedRefs", "backend Services are resolved")
+
+	err = r.reconcilePublish(ctx, onionServiceCopy)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
 
 	if err := r.Status().Update(ctx, onionServiceCopy); err != nil {
 		logger.Error(err, "unable to update OnionService status")
@@ -183,17 +198,68 @@ func (r *OnionServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		return ctrl.Result{}, errors.Wrap(err, "unable to update OnionService status")
 	}
 
+	// Dropped once the keys of the removed publish targets are
+	err = r.updatePublishFinalizer(ctx, onionServiceCopy)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
 	return ctrl.Result{}, nil
 }
 
+// updateResolvedRefs records in the status why the backends can't be used.
+func (r *OnionServiceReconciler) updateResolvedRefs(
+	ctx context.Context,
+	onion *torv1alpha2.OnionService,
+	status metav1.ConditionStatus,
+	reason, message string,
+) error {
+	onionCopy := onion.DeepCopy()
+	setResolvedRefsCondition(onionCopy, status, reason, message)
+
+	err := r.Status().Update(ctx, onionCopy)
+	if err != nil {
+		return errors.Wrap(err, "unable to update OnionService status")
+	}
+
+	return nil
+}
+
+func setResolvedRefsCondition(
+	onion *torv1alpha2.OnionService,
+	status metav1.ConditionStatus,
+	reason, message string,
+) {
+	meta.SetStatusCondition(&onion.Status.Conditions, metav1.Condition{
+		Type:               torv1alpha2.OnionServiceResolvedRefs,
+		Status:             status,
+		ObservedGeneration: onion.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *OnionServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	pred := predicate.GenerationChangedPredicate{}
-
-	err := ctrl.NewControllerManagedBy(mgr).
-		For(&torv1alpha2.OnionService{}).
-		WithEventFilter(pred).
-		Complete(r)
+	// The onion address is published once the tor agent reports it
+	pred := predicate.Or(predicate.GenerationChangedPredicate{}, hostnameChanged())
+
+	// Backend Services changes must be rendered again: the generation filter
+	// only applies to OnionServices
+	bldr := ctrl.NewControllerManagedBy(mgr).
+		For(&torv1alpha2.OnionService{}, builder.WithPredicates(pred)).
+		Watches(
+			&source.Kind{Type: &corev1.Service{}},
+			handler.EnqueueRequestsFromMapFunc(r.findOnionServicesForService),
+		).
+		// Observes status updates, so it goes before the generation filter
+		WithEventFilter(hostnamePublishedObserver(r.ProjectConfig.Sharding)).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.ProjectConfig.MaxConcurrentReconciles.OnionService})
+
+	bldr = watchReferenceGrants(mgr, bldr, onionServiceGroupKind, &torv1alpha2.OnionServiceList{})
+
+	err := watchPolicies(mgr, bldr, &torv1alpha2.OnionServiceList{}).
+		Complete(withSharding(mgr, r.ProjectConfig.Sharding, &torv1alpha2.OnionService{}, r))
 	if err != nil {
 		return errors.Wrap(err, "unable to create OnionService controller")
 	}
@@ -25,6 +25,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/runtime"
 	k8slog "sigs.k8s.io/controller-runtime/pkg/log"
 
@@ -33,6 +34,9 @@ import (
 	"github.com/cockroachdb/errors"
 )
 
+// healthPort is where the tor agent serves /healthz and /readyz.
+const healthPort = 8081
+
 func (r *OnionServiceReconciler) reconcileDeployment(ctx context.Context, onionService *torv1alpha2.OnionService) error {
 	logger := k8slog.FromContext(ctx)
 
@@ -48,12 +52,19 @@ func (r *OnionServiceReconciler) reconcileDeployment(ctx context.Context, onionS
 		return nil
 	}
 
-	var deployment appsv1.Deployment
-	err := r.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: namespace}, &deployment)
+	projectConfig, err := projectConfigFor(ctx, r, &r.ProjectConfig, namespace)
+	if err != nil {
+		return err
+	}
 
 	// If the deployment doesn't exist, we'll create it
-	projectConfig := r.ProjectConfig
-	newDeployment := torOnionServiceDeployment(onionService, &projectConfig)
+	newDeployment, err := torOnionServiceDeployment(onionService, projectConfig)
+	if err != nil {
+		return errors.Wrapf(err, "failed to build Deployment %s/%s", namespace, deploymentName)
+	}
+
+	var deployment appsv1.Deployment
+	err = r.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: namespace}, &deployment)
 
 	if apierrors.IsNotFound(err) {
 		err := r.Create(ctx, newDeployment)
@@ -72,6 +83,7 @@ func (r *OnionServiceReconciler) reconcileDeployment(ctx context.Context, onionS
 	// If the Deployment is not controlled by this Foo resource, we should log
 	// a warning to the event recorder and ret
 	if !metav1.IsControlledBy(&deployment.ObjectMeta, onionService) {
+		recordNotControlled("Deployment", &deployment, onionService)
 		logger.Info("Deployment already exists and not controlled by - skipping update",
 			"deployment", deployment.Name,
 			"controller", onionService.Name)
@@ -90,9 +102,12 @@ func (r *OnionServiceReconciler) reconcileDeployment(ctx context.Context, onionS
 	return nil
 }
 
-func torOnionServiceDeployment(onion *torv1alpha2.OnionService, projectConfig *configv2.ProjectConfig) *appsv1.Deployment {
-	privateKeyMountPath := "/run/tor/service/key"
-	authorizedClientsMountPath := "/run/tor/service/.authorized_clients"
+func torOnionServiceDeployment(onion *torv1alpha2.OnionService, projectConfig *configv2.ProjectConfig) (*appsv1.Deployment, error) {
+	// The secrets are mounted outside tor's HiddenServiceDir, which the agent
+	// creates in the /run/tor volume and fills from them. Mounting them inside
+	// would make the runtime create the directory owned by root.
+	privateKeyMountPath := "/run/tor-secrets/key"
+	authorizedClientsMountPath := "/run/tor-secrets/authorized_clients"
 
 	publicKeyFileName := "hs_ed25519_public_key"
 	privateKeyFileName := "hs_ed25519_secret_key"
@@ -167,49 +182,135 @@ func torOnionServiceDeployment(onion *torv1alpha2.OnionService, projectConfig *c
 		},
 	}
 
-	// Fetch Pod Template
-	podTemplate := onion.PodTemplate()
+	// tor exposes a control socket in this volume for the health checks and
+	// the vanguards add-on
+	volumes = append(volumes, emptyDirVolume(torControlVolume))
 
-	// Add Labels to template
-	if podTemplate.ObjectMeta.Labels == nil {
-		// Set deployment labels
-		podTemplate.ObjectMeta.Labels = onion.DeploymentLabels()
-	} else {
-		// Add tor labels to existing template labels
-		for k, v := range onion.DeploymentLabels() {
-			// TODO: should we throw an error if a label was already set?
-			podTemplate.ObjectMeta.Labels[k] = v
+	volumeMounts = append(volumeMounts, corev1.VolumeMount{
+		Name:      torControlVolume,
+		MountPath: torv1alpha2.TorControlDir,
+	})
+
+	// The agent writes the torrc and the service files, and tor its state;
+	// everything else in the container is read-only
+	volumes = append(volumes,
+		emptyDirVolume(torRunVolume),
+		emptyDirVolume(torDataVolume),
+		emptyDirVolume(tmpVolume),
+	)
+
+	volumeMounts = append(volumeMounts,
+		corev1.VolumeMount{
+			Name:      torRunVolume,
+			MountPath: "/run/tor",
+		},
+		corev1.VolumeMount{
+			Name:      torDataVolume,
+			MountPath: "/var/lib/tor",
+		},
+		corev1.VolumeMount{
+			Name:      tmpVolume,
+			MountPath: "/tmp",
+		},
+	)
+
+	// Unix socket backends live in a volume shared with the containers
+	// serving them
+	unixSocketsMount := corev1.VolumeMount{
+		Name:      unixSocketsVolume,
+		MountPath: torv1alpha2.UnixSocketsDir,
+	}
+
+	socketContainers := unixSocketContainers(onion)
+	if len(socketContainers) > 0 {
+		volumes = append(volumes, emptyDirVolume(unixSocketsVolume))
+		volumeMounts = append(volumeMounts, unixSocketsMount)
+	}
+
+	containers := []corev1.Container{
+		{
+			Name:  "tor",
+			Image: projectConfig.TorDaemonManager.ImageRef(),
+			Args: []string{
+				"-name",
+				onion.Name,
+				"-namespace",
+				onion.Namespace,
+			},
+			ImagePullPolicy: projectConfig.TorDaemonManager.PullPolicy(),
+			SecurityContext: componentSecurityContext(&projectConfig.TorDaemonManager.ComponentDefaults),
+			VolumeMounts:    volumeMounts,
+			Ports: []corev1.ContainerPort{
+				// {
+				// 	Name: "control",
+				// 	Protocol: "TCP",
+				// 	ContainerPort: 9051,
+				// },
+				{
+					Name:          "metrics",
+					Protocol:      "TCP",
+					ContainerPort: metricsPort,
+				},
+				{
+					Name:          "agent-metrics",
+					Protocol:      "TCP",
+					ContainerPort: agentMetricsPort,
+				},
+				{
+					Name:          "health",
+					Protocol:      "TCP",
+					ContainerPort: healthPort,
+				},
+			},
+			LivenessProbe:  torLivenessProbe(),
+			ReadinessProbe: torReadinessProbe(),
+			Resources:      containerResources(onion.Resources(), &projectConfig.TorDaemonManager.ComponentDefaults),
+		},
+	}
+
+	generated := corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: onion.DeploymentLabels(),
+		},
+		Spec: corev1.PodSpec{
+			Volumes: volumes,
+		},
+	}
+
+	applyComponentDefaults(&generated.Spec, &projectConfig.TorDaemonManager.ComponentDefaults)
+
+	if onion.Spec.Vanguards.FullEnabled() {
+		containers = append(containers, vanguardsContainer(onion, projectConfig))
+		applyComponentDefaults(&generated.Spec, &projectConfig.TorOnionbalanceManager.ComponentDefaults)
+	}
+
+	generated.Spec.Containers = containers
+
+	// Merge the user's Pod Template, which can tweak the generated containers
+	userTemplate := onion.PodTemplate()
+
+	podTemplate, err := mergePodTemplate(&generated, &userTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range podTemplate.Spec.Containers {
+		container := &podTemplate.Spec.Containers[i]
+		if socketContainers[container.Name] && !hasVolumeMount(container, unixSocketsVolume) {
+			container.VolumeMounts = append(container.VolumeMounts, unixSocketsMount)
 		}
 	}
 
+	// The deployment labels back the selector, so they win over the
+	// template ones
+	for k, v := range onion.DeploymentLabels() {
+		podTemplate.ObjectMeta.Labels[k] = v
+	}
+
 	// Set Onion Service pod properties
 	podTemplate.Spec.ServiceAccountName = onion.ServiceAccountName()
-	podTemplate.Spec.Volumes = append(podTemplate.Spec.Volumes, volumes...)
-	podTemplate.Spec.Containers = append(podTemplate.Spec.Containers, corev1.Container{
-		Name:  "tor",
-		Image: projectConfig.TorDaemonManager.Image,
-		Args: []string{
-			"-name",
-			onion.Name,
-			"-namespace",
-			onion.Namespace,
-		},
-		ImagePullPolicy: corev1.PullAlways,
-		VolumeMounts:    volumeMounts,
-		Ports: []corev1.ContainerPort{
-			// {
-			// 	Name: "control",
-			// 	Protocol: "TCP",
-			// 	ContainerPort: 9051,
-			// },
-			{
-				Name:          "metrics",
-				Protocol:      "TCP",
-				ContainerPort: metricsPort,
-			},
-		},
-		Resources: onion.Resources(),
-	})
+	// The agent watches the OnionService, so it needs the token
+	applyPodSecurityDefaults(&podTemplate.Spec, true)
 
 	return &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
@@ -229,5 +330,50 @@ func torOnionServiceDeployment(onion *torv1alpha2.OnionService, projectConfig *c
 			},
 			Template: podTemplate,
 		},
+	}, nil
+}
+
+// unixSocketContainers returns the containers serving unix socket backends.
+func unixSocketContainers(onion *torv1alpha2.OnionService) map[string]bool {
+	containers := map[string]bool{}
+
+	for _, rule := range onion.Spec.Rules {
+		if rule.Backend.Unix != nil {
+			containers[rule.Backend.Unix.Container] = true
+		}
+	}
+
+	return containers
+}
+
+// torLivenessProbe restarts the pod when the tor agent can't keep tor
+// running.
+func torLivenessProbe() *corev1.Probe {
+	return &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			HTTPGet: &corev1.HTTPGetAction{
+				Path: "/healthz",
+				Port: intstr.FromInt(healthPort),
+			},
+		},
+		InitialDelaySeconds: 10,
+		PeriodSeconds:       10,
+		// tor restarts with a backoff of up to a minute
+		FailureThreshold: 9,
+	}
+}
+
+// torReadinessProbe marks the pod ready once tor bootstrapped and, for onion
+// services, published its descriptor.
+func torReadinessProbe() *corev1.Probe {
+	return &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			HTTPGet: &corev1.HTTPGetAction{
+				Path: "/readyz",
+				Port: intstr.FromInt(healthPort),
+			},
+		},
+		PeriodSeconds:  10,
+		TimeoutSeconds: 6,
 	}
 }
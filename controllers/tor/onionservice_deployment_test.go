@@ -0,0 +1,123 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tor
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	configv2 "github.com/bugfest/tor-controller/apis/config/v2"
+	torv1alpha2 "github.com/bugfest/tor-controller/apis/tor/v1alpha2"
+)
+
+// hiddenServiceDir is the HiddenServiceDir the tor agent creates.
+const hiddenServiceDir = "/run/tor/service"
+
+func TestOnionServiceDeploymentLayout(t *testing.T) {
+	onion := &torv1alpha2.OnionService{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "example",
+			Namespace: "default",
+		},
+		Spec: torv1alpha2.OnionServiceSpec{
+			Template: torv1alpha2.ServicePodTemplate{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{Name: "sidecar", Image: "sidecar:latest"},
+					},
+				},
+			},
+		},
+	}
+
+	deployment, err := torOnionServiceDeployment(onion, &configv2.ProjectConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spec := deployment.Spec.Template.Spec
+
+	volumes := map[string]corev1.Volume{}
+	for _, volume := range spec.Volumes {
+		volumes[volume.Name] = volume
+	}
+
+	containers := map[string]corev1.Container{}
+	for _, container := range spec.Containers {
+		containers[container.Name] = container
+	}
+
+	tor, ok := containers["tor"]
+	if !ok {
+		t.Fatal("no tor container")
+	}
+
+	var runMounted bool
+
+	for _, mount := range tor.VolumeMounts {
+		volume := volumes[mount.Name]
+
+		// The runtime would create the HiddenServiceDir owned by root to
+		// mount anything in it
+		if mount.MountPath == hiddenServiceDir || strings.HasPrefix(mount.MountPath, hiddenServiceDir+"/") {
+			t.Errorf("volume %s is mounted at %s, in the HiddenServiceDir", mount.Name, mount.MountPath)
+		}
+
+		if mount.MountPath == "/run/tor" {
+			runMounted = true
+
+			if volume.EmptyDir == nil {
+				t.Errorf("/run/tor is mounted from %s, want an emptyDir", mount.Name)
+			}
+		}
+
+		if volume.Secret != nil && !mount.ReadOnly {
+			t.Errorf("secret volume %s is mounted read-write", mount.Name)
+		}
+	}
+
+	if !runMounted {
+		t.Error("/run/tor isn't mounted, the agent can't create the HiddenServiceDir")
+	}
+
+	securityContext := tor.SecurityContext
+	if securityContext == nil || securityContext.RunAsUser == nil || *securityContext.RunAsUser != restrictedUserID {
+		t.Errorf("tor container security context = %+v, want runAsUser %d", securityContext, restrictedUserID)
+	}
+
+	if securityContext == nil || securityContext.RunAsNonRoot == nil || !*securityContext.RunAsNonRoot {
+		t.Errorf("tor container security context = %+v, want runAsNonRoot", securityContext)
+	}
+
+	// The secrets and emptyDirs are shared through the fsGroup, the user
+	// is left to the containers
+	podSecurityContext := spec.SecurityContext
+	if podSecurityContext == nil || podSecurityContext.FSGroup == nil || *podSecurityContext.FSGroup != restrictedUserID {
+		t.Errorf("pod security context = %+v, want fsGroup %d", podSecurityContext, restrictedUserID)
+	}
+
+	if podSecurityContext != nil && (podSecurityContext.RunAsUser != nil || podSecurityContext.RunAsGroup != nil) {
+		t.Errorf("pod security context = %+v, want no user or group", podSecurityContext)
+	}
+
+	if sidecar := containers["sidecar"]; sidecar.SecurityContext != nil {
+		t.Errorf("sidecar security context = %+v, want the one of the template", sidecar.SecurityContext)
+	}
+}
@@ -0,0 +1,230 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tor
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	k8slog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/cockroachdb/errors"
+
+	torv1alpha2 "github.com/bugfest/tor-controller/apis/tor/v1alpha2"
+)
+
+func (r *OnionServiceReconciler) reconcileNetworkPolicy(ctx context.Context, onionService *torv1alpha2.OnionService) error {
+	logger := k8slog.FromContext(ctx)
+
+	policyName := onionService.NetworkPolicyName()
+	namespace := onionService.Namespace
+
+	var policy networkingv1.NetworkPolicy
+	err := r.Get(ctx, types.NamespacedName{Name: policyName, Namespace: namespace}, &policy)
+
+	if apierrors.IsNotFound(err) {
+		if !onionService.Spec.NetworkPolicy.Enable {
+			// NetworkPolicy is not requested, skipping
+			return nil
+		}
+	} else if err != nil {
+		return errors.Wrapf(err, "failed to get NetworkPolicy %s", policyName)
+	}
+
+	if err == nil && !metav1.IsControlledBy(&policy.ObjectMeta, onionService) {
+		recordNotControlled("NetworkPolicy", &policy, onionService)
+		logger.Info("NetworkPolicy already exists and is not controlled by",
+			"NetworkPolicy", policy.Name,
+			"controller", onionService.Name)
+
+		return nil
+	}
+
+	if !onionService.Spec.NetworkPolicy.Enable {
+		// NetworkPolicy is not requested but exists, deleting
+		err = r.Delete(ctx, &policy)
+		if err != nil {
+			return errors.Wrapf(err, "failed to delete NetworkPolicy %s", policyName)
+		}
+
+		return nil
+	}
+
+	apiServer, egressErr := apiServerEgress(ctx, r.Client, &r.ProjectConfig)
+	if egressErr != nil {
+		return egressErr
+	}
+
+	newPolicy := osTorNetworkPolicy(onionService, apiServer, r.backendEgress(ctx, onionService))
+	if apierrors.IsNotFound(err) {
+		err := r.Create(ctx, newPolicy)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create NetworkPolicy %s", policyName)
+		}
+
+		return nil
+	}
+
+	// If the policy specs don't match, update
+	if !networkPolicyEqual(&policy, newPolicy) {
+		err := r.Update(ctx, newPolicy)
+		if err != nil {
+			return errors.Wrapf(err, "failed to update NetworkPolicy %s", policyName)
+		}
+	}
+
+	return nil
+}
+
+// backendEgress allows the tor pods to reach the pods behind the backend
+// Services of the rules and their HTTP routes. Services without a selector
+// are skipped, their endpoints can't be selected.
+func (r *OnionServiceReconciler) backendEgress(
+	ctx context.Context,
+	onionService *torv1alpha2.OnionService,
+) []networkingv1.NetworkPolicyEgressRule {
+	logger := k8slog.FromContext(ctx)
+
+	rules := []networkingv1.NetworkPolicyEgressRule{}
+
+	for i := range onionService.Spec.Rules {
+		for _, backend := range onionService.Spec.Rules[i].Backends() {
+			if backend.Service == nil {
+				continue
+			}
+
+			egress, err := r.serviceEgress(ctx, onionService.Namespace, backend)
+			if err != nil {
+				logger.Info("skipping NetworkPolicy egress to backend",
+					"service", backend.Service.Name,
+					"error", err.Error())
+
+				continue
+			}
+
+			if egress != nil {
+				rules = append(rules, *egress)
+			}
+		}
+	}
+
+	return rules
+}
+
+// serviceEgress allows the traffic to the target port of the pods of a
+// backend Service, namespace being the one of the OnionService. It is nil for
+// Services without selector, and an error for Services that can't be
+// referenced or don't expose the backend port.
+func (r *OnionServiceReconciler) serviceEgress(
+	ctx context.Context,
+	onionNamespace string,
+	backend *torv1alpha2.OnionServiceBackend,
+) (*networkingv1.NetworkPolicyEgressRule, error) {
+	logger := k8slog.FromContext(ctx)
+
+	name, namespace := backend.Service.Name, backend.ServiceNamespace(onionNamespace)
+
+	granted, err := referenceGranted(ctx, r, onionServiceGroupKind, onionNamespace,
+		serviceGroupKind, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !granted {
+		return nil, errors.Wrapf(errRefNotPermitted,
+			"no TorReferenceGrant in %s allows the backend Service %s", namespace, name)
+	}
+
+	var service corev1.Service
+
+	err = r.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, &service)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get Service %s/%s", namespace, name)
+	}
+
+	// An egress rule without ports would allow them all
+	port, err := servicePort(&service, backend.Service.Port)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(service.Spec.Selector) == 0 {
+		logger.Info("skipping NetworkPolicy egress to backend without selector",
+			"service", backend.Service.Name)
+
+		return nil, nil
+	}
+
+	peer := networkingv1.NetworkPolicyPeer{
+		PodSelector: &metav1.LabelSelector{
+			MatchLabels: service.Spec.Selector,
+		},
+	}
+
+	if namespace != onionNamespace {
+		peer.NamespaceSelector = &metav1.LabelSelector{
+			MatchLabels: map[string]string{namespaceNameLabel: namespace},
+		}
+	}
+
+	// Traffic reaches the pods on the target port
+	targetPort := port.TargetPort
+	if targetPort.IntValue() == 0 && targetPort.Type == intstr.Int {
+		targetPort = intstr.FromInt(int(port.Port))
+	}
+
+	return &networkingv1.NetworkPolicyEgressRule{
+		To:    []networkingv1.NetworkPolicyPeer{peer},
+		Ports: []networkingv1.NetworkPolicyPort{networkPolicyPort(port.Protocol, targetPort)},
+	}, nil
+}
+
+// osTorNetworkPolicy lets the tor pods reach the tor network, the backends
+// and the API server (the tor agent), and be scraped.
+func osTorNetworkPolicy(
+	onion *torv1alpha2.OnionService,
+	apiServer networkingv1.NetworkPolicyEgressRule,
+	backends []networkingv1.NetworkPolicyEgressRule,
+) *networkingv1.NetworkPolicy {
+	egress := internetEgress()
+	egress = append(egress, apiServer)
+	egress = append(egress, backends...)
+
+	return torNetworkPolicy(
+		metav1.ObjectMeta{
+			Name:      onion.NetworkPolicyName(),
+			Namespace: onion.Namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(onion, schema.GroupVersionKind{
+					Group:   torv1alpha2.GroupVersion.Group,
+					Version: torv1alpha2.GroupVersion.Version,
+					Kind:    "OnionService",
+				}),
+			},
+		},
+		onion.DeploymentLabels(),
+		&onion.Spec.NetworkPolicy,
+		metricsIngress(&onion.Spec.NetworkPolicy, metricsPort, agentMetricsPort),
+		egress,
+	)
+}
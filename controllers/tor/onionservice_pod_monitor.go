@@ -0,0 +1,124 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tor
+
+import (
+	"context"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	k8slog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/cockroachdb/errors"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+
+	torv1alpha2 "github.com/bugfest/tor-controller/apis/tor/v1alpha2"
+)
+
+func (r *OnionServiceReconciler) reconcilePodMonitor(ctx context.Context, onionService *torv1alpha2.OnionService) error {
+	logger := k8slog.FromContext(ctx)
+
+	if !podMonitoringInstalled(ctx, r) {
+		// PodMonitor cannot be created; monitoring CRDs are not installed
+		return nil
+	}
+
+	monitorName := onionService.ServiceMetricsName()
+	namespace := onionService.Namespace
+
+	var monitor monitoringv1.PodMonitor
+	err := r.Get(ctx, types.NamespacedName{Name: monitorName, Namespace: namespace}, &monitor)
+
+	newMonitor := osTorPodMonitor(onionService)
+
+	if apierrors.IsNotFound(err) {
+		if !onionService.Monitoring().PodMonitorEnabled() {
+			// PodMonitor is not requested, skipping
+			return nil
+		}
+
+		err := r.Create(ctx, newMonitor)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create PodMonitor %s", monitorName)
+		}
+
+		monitor = *newMonitor
+	} else if err != nil {
+		return errors.Wrapf(err, "failed to get PodMonitor %s", monitorName)
+	}
+
+	if !metav1.IsControlledBy(&monitor.ObjectMeta, onionService) {
+		recordNotControlled("PodMonitor", &monitor, onionService)
+		logger.Info("PodMonitor already exists and is not controlled by",
+			"PodMonitor", monitor.Name,
+			"controller", onionService.Name)
+
+		return nil
+	}
+
+	if !onionService.Monitoring().PodMonitorEnabled() {
+		// PodMonitor is not requested but exists, deleting
+		err = r.Delete(ctx, &monitor)
+		if err != nil {
+			return errors.Wrapf(err, "failed to delete PodMonitor %s", monitorName)
+		}
+
+		return nil
+	}
+
+	// If the monitor specs don't match, update
+	if !podMonitorEqual(&monitor, newMonitor) {
+		err := r.Update(ctx, newMonitor)
+		if err != nil {
+			return errors.Wrapf(err, "failed to update PodMonitor %s", monitorName)
+		}
+	}
+
+	return nil
+}
+
+// osTorPodMonitor scrapes every tor pod directly, instead of going through the
+// metrics Service.
+func osTorPodMonitor(onion *torv1alpha2.OnionService) *monitoringv1.PodMonitor {
+	return &monitoringv1.PodMonitor{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      onion.ServiceMetricsName(),
+			Namespace: onion.Namespace,
+			Labels:    monitorLabels(onion.ServiceMetricsSelector(), onion.Monitoring()),
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(onion, schema.GroupVersionKind{
+					Group:   torv1alpha2.GroupVersion.Group,
+					Version: torv1alpha2.GroupVersion.Version,
+					Kind:    "OnionService",
+				}),
+			},
+		},
+		Spec: monitoringv1.PodMonitorSpec{
+			Selector: metav1.LabelSelector{
+				MatchLabels: onion.DeploymentLabels(),
+			},
+			PodMetricsEndpoints: podMetricsEndpoints(onion.Monitoring(), "metrics", "agent-metrics"),
+			NamespaceSelector: monitoringv1.NamespaceSelector{
+				MatchNames: []string{
+					onion.Namespace,
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,305 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tor
+
+import (
+	"context"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	k8slog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+
+	torv1alpha2 "github.com/bugfest/tor-controller/apis/tor/v1alpha2"
+	"github.com/cockroachdb/errors"
+)
+
+//+kubebuilder:rbac:groups="",resources=endpoints,verbs=get
+
+// endpointsRequeueDelay is the time to wait for the endpoints of a headless
+// Service, they aren't watched.
+const endpointsRequeueDelay = 10 * time.Second
+
+var (
+	errPortNotFound       = errors.New("port not found in the backend Service")
+	errEndpointsNotReady  = errors.New("target port not found in the backend endpoints")
+	errBackendNotFound    = errors.New("backend Service not found")
+	errRefNotPermitted    = errors.New("backend reference not permitted")
+	errUnsupportedBackend = errors.New("unsupported backend")
+	errContainerNotFound  = errors.New("container not found in the pod template")
+)
+
+// resolvedRefsReasons are the ResolvedRefs condition reasons of the errors
+// the user has to fix.
+var resolvedRefsReasons = []struct {
+	err    error
+	reason string
+}{
+	{errPortNotFound, "PortNotFound"},
+	{errEndpointsNotReady, "EndpointsNotReady"},
+	{errBackendNotFound, "BackendNotFound"},
+	{errRefNotPermitted, "RefNotPermitted"},
+	{errUnsupportedBackend, "UnsupportedBackend"},
+	{errContainerNotFound, "ContainerNotFound"},
+}
+
+// servicePort returns the TCP port of service matching the backend port, by
+// name or number.
+func servicePort(service *corev1.Service, backendPort networkingv1.ServiceBackendPort) (*corev1.ServicePort, error) {
+	for i := range service.Spec.Ports {
+		port := &service.Spec.Ports[i]
+
+		if port.Protocol != corev1.ProtocolTCP {
+			continue
+		}
+
+		if backendPort.Name != "" && port.Name == backendPort.Name {
+			return port, nil
+		}
+
+		if backendPort.Name == "" && port.Port == backendPort.Number {
+			return port, nil
+		}
+	}
+
+	if backendPort.Name != "" {
+		return nil, errors.Wrapf(errPortNotFound, "Service %s/%s has no TCP port named %q",
+			service.Namespace, service.Name, backendPort.Name)
+	}
+
+	return nil, errors.Wrapf(errPortNotFound, "Service %s/%s has no TCP port %d",
+		service.Namespace, service.Name, backendPort.Number)
+}
+
+// resolveTargetPort returns the port tor must connect to: the Service port,
+// or the target port of the endpoints for headless Services, as their name
+// resolves to the pod addresses.
+func (r *OnionServiceReconciler) resolveTargetPort(
+	ctx context.Context,
+	service *corev1.Service,
+	port *corev1.ServicePort,
+) (int32, error) {
+	if service.Spec.ClusterIP != corev1.ClusterIPNone {
+		return port.Port, nil
+	}
+
+	switch {
+	case port.TargetPort.Type == intstr.Int && port.TargetPort.IntVal == 0:
+		return port.Port, nil
+	case port.TargetPort.Type == intstr.Int:
+		return port.TargetPort.IntVal, nil
+	}
+
+	// Named target ports are only known by the endpoints, which keep the name
+	// of the Service port
+	var endpoints corev1.Endpoints
+
+	err := r.Get(ctx, types.NamespacedName{Name: service.Name, Namespace: service.Namespace}, &endpoints)
+	if client.IgnoreNotFound(err) != nil {
+		return 0, errors.Wrap(err, "unable to get endpoints")
+	}
+
+	for _, subset := range endpoints.Subsets {
+		for _, endpointPort := range subset.Ports {
+			if endpointPort.Name == port.Name {
+				return endpointPort.Port, nil
+			}
+		}
+	}
+
+	return 0, errors.Wrapf(errEndpointsNotReady, "no endpoints of Service %s/%s expose the target port %q",
+		service.Namespace, service.Name, port.TargetPort.StrVal)
+}
+
+// resolveTarget returns the HiddenServicePort target of a rule.
+func (r *OnionServiceReconciler) resolveTarget(
+	ctx context.Context,
+	onion *torv1alpha2.OnionService,
+	rule *torv1alpha2.ServiceRule,
+) (torv1alpha2.OnionServiceTarget, error) {
+	target := torv1alpha2.OnionServiceTarget{
+		PublicPort: rule.Port.Number,
+	}
+
+	if len(rule.HTTP) > 0 {
+		for i := range rule.HTTP {
+			backend := &rule.HTTP[i].Backend
+			if backend.Service == nil {
+				return target, errors.Wrapf(errUnsupportedBackend,
+					"the HTTP routes of port %d only support Service backends", rule.Port.Number)
+			}
+
+			port, err := r.resolveBackendPort(ctx, onion.Namespace, backend)
+			if err != nil {
+				return target, err
+			}
+
+			target.Routes = append(target.Routes, torv1alpha2.OnionServiceRouteTarget{
+				Host: backend.ServiceHost(onion.Namespace),
+				Port: port,
+			})
+		}
+
+		return target, nil
+	}
+
+	if unix := rule.Backend.Unix; unix != nil {
+		if !hasContainer(onion.Spec.Template.Spec.Containers, unix.Container) {
+			return target, errors.Wrapf(errContainerNotFound,
+				"spec.template has no container %s serving the unix socket %s", unix.Container, unix.Path)
+		}
+
+		target.Unix = rule.Backend.UnixSocketPath()
+
+		return target, nil
+	}
+
+	if rule.Backend.Service == nil {
+		return target, errors.Wrapf(errUnsupportedBackend,
+			"the backend of port %d is neither a Service nor a unix socket", rule.Port.Number)
+	}
+
+	port, err := r.resolveBackendPort(ctx, onion.Namespace, &rule.Backend)
+	if err != nil {
+		return target, err
+	}
+
+	// Only resolved backends are rendered by the tor agent
+	target.Host = rule.Backend.ServiceHost(onion.Namespace)
+	target.Port = port
+
+	return target, nil
+}
+
+// resolveBackendPort returns the port tor must connect to for a Service
+// backend, namespace being the one of the OnionService.
+func (r *OnionServiceReconciler) resolveBackendPort(
+	ctx context.Context,
+	namespace string,
+	backend *torv1alpha2.OnionServiceBackend,
+) (int32, error) {
+	serviceName := backend.Service.Name
+	serviceNamespace := backend.ServiceNamespace(namespace)
+
+	granted, err := referenceGranted(ctx, r, onionServiceGroupKind, namespace,
+		serviceGroupKind, serviceNamespace, serviceName)
+	if err != nil {
+		return 0, err
+	}
+
+	if !granted {
+		// A TorReferenceGrant change triggers a new reconcile
+		return 0, errors.Wrapf(errRefNotPermitted,
+			"no TorReferenceGrant in %s allows the backend Service %s", serviceNamespace, serviceName)
+	}
+
+	var service corev1.Service
+
+	err = r.Get(ctx, types.NamespacedName{Name: serviceName, Namespace: serviceNamespace}, &service)
+	if apierrors.IsNotFound(err) {
+		// Creating the Service triggers a new reconcile
+		return 0, errors.Wrapf(errBackendNotFound, "backend Service %s/%s not found", serviceNamespace, serviceName)
+	} else if err != nil {
+		return 0, errors.Wrap(err, "unable to get backend service")
+	}
+
+	port, err := servicePort(&service, backend.Service.Port)
+	if err != nil {
+		return 0, err
+	}
+
+	return r.resolveTargetPort(ctx, &service, port)
+}
+
+// backendNotResolved records in the status why the backends can't be used,
+// or returns the error to retry.
+func (r *OnionServiceReconciler) backendNotResolved(
+	ctx context.Context,
+	onion *torv1alpha2.OnionService,
+	err error,
+) (ctrl.Result, error) {
+	logger := k8slog.FromContext(ctx)
+
+	for _, known := range resolvedRefsReasons {
+		if !errors.Is(err, known.err) {
+			continue
+		}
+
+		logger.Info("backend not resolved", "reason", known.reason, "error", err.Error())
+
+		result := ctrl.Result{}
+
+		// The endpoints aren't watched
+		if known.reason == "EndpointsNotReady" {
+			result.RequeueAfter = endpointsRequeueDelay
+		}
+
+		return result, r.updateResolvedRefs(ctx, onion, metav1.ConditionFalse, known.reason, err.Error())
+	}
+
+	return ctrl.Result{}, err
+}
+
+// findOnionServicesForService maps a Service to the OnionServices using it
+// as backend, so port changes are rendered again.
+func (r *OnionServiceReconciler) findOnionServicesForService(object client.Object) []reconcile.Request {
+	var onionServiceList torv1alpha2.OnionServiceList
+
+	err := r.List(context.Background(), &onionServiceList)
+	if err != nil {
+		k8slog.Log.Error(err, "unable to list OnionServices")
+
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+
+	for i := range onionServiceList.Items {
+		onion := &onionServiceList.Items[i]
+
+		if usesService(onion, object) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: client.ObjectKeyFromObject(onion),
+			})
+		}
+	}
+
+	return requests
+}
+
+// usesService tells whether a rule of onion has service as backend.
+func usesService(onion *torv1alpha2.OnionService, service client.Object) bool {
+	for i := range onion.Spec.Rules {
+		for _, backend := range onion.Spec.Rules[i].Backends() {
+			if backend.Service != nil &&
+				backend.Service.Name == service.GetName() &&
+				backend.ServiceNamespace(onion.Namespace) == service.GetNamespace() {
+				return true
+			}
+		}
+	}
+
+	return false
+}
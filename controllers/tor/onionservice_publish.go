@@ -0,0 +1,734 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	k8slog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	torv1alpha2 "github.com/bugfest/tor-controller/apis/tor/v1alpha2"
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// publishFinalizer removes the published keys of deleted OnionServices,
+	// owner references can't reach the targets of other namespaces.
+	publishFinalizer = "tor.k8s.torproject.org/publish"
+
+	// publishedLabel marks the targets the controller may write to. The ones
+	// it created, labelled publishedCreated, are deleted once they hold no
+	// key.
+	publishedLabel   = "tor.k8s.torproject.org/published"
+	publishedCreated = "true"
+	publishedClaimed = "claimed"
+
+	publishedHostnameKey  = "hostname"
+	publishedPublicKeyKey = "hs_ed25519_public_key"
+
+	dnsEndpointKind = "DNSEndpoint"
+)
+
+// errTargetNotOwned is returned for the publish targets already holding
+// keys of someone else.
+var errTargetNotOwned = errors.New("publish target not owned")
+
+var dnsEndpointGVK = schema.GroupVersionKind{
+	Group:   "externaldns.k8s.io",
+	Version: "v1alpha1",
+	Kind:    dnsEndpointKind,
+}
+
+// publishedRef identifies a resource the onion address is written to.
+type publishedRef struct {
+	kind, namespace, name string
+}
+
+//+kubebuilder:rbac:groups="externaldns.k8s.io",resources=dnsendpoints,verbs=get;create;update;delete
+
+// reconcilePublish writes the onion address into the publish targets, and
+// removes it from the ones no longer listed. What was written is recorded in
+// the status of onion.
+func (r *OnionServiceReconciler) reconcilePublish(ctx context.Context, onion *torv1alpha2.OnionService) error {
+	logger := k8slog.FromContext(ctx)
+	spec := &onion.Spec.Publish
+
+	if !publishConfigured(onion) && len(onion.Status.Published) == 0 {
+		meta.RemoveStatusCondition(&onion.Status.Conditions, torv1alpha2.OnionServicePublished)
+
+		return nil
+	}
+
+	// The tor agent writes the onion address once tor generated it
+	if onion.Status.Hostname == "" {
+		setPublishedCondition(onion, metav1.ConditionFalse, "Pending", "the onion address is not known yet")
+
+		return nil
+	}
+
+	// Owner references can't reach the targets of other namespaces, the
+	// finalizer has to be in place before anything is written
+	err := r.addPublishFinalizer(ctx, onion)
+	if err != nil {
+		return err
+	}
+
+	previous := map[publishedRef][]string{}
+
+	for _, target := range onion.Status.Published {
+		previous[publishedRef{target.Kind, target.Namespace, target.Name}] = target.Keys
+	}
+
+	published := []torv1alpha2.PublishedTarget{}
+	denied := []string{}
+	notOwned := []string{}
+
+	for i := range spec.Targets {
+		target := &spec.Targets[i]
+		ref := publishTargetRef(onion, target)
+
+		granted, err := referenceGranted(ctx, r, onionServiceGroupKind, onion.Namespace,
+			schema.GroupKind{Group: corev1.GroupName, Kind: ref.kind}, ref.namespace, ref.name)
+		if err != nil {
+			return err
+		}
+
+		if !granted {
+			denied = append(denied, fmt.Sprintf("%s %s/%s", ref.kind, ref.namespace, ref.name))
+
+			continue
+		}
+
+		data, err := r.publishedData(ctx, onion, target, ref.kind)
+		if err != nil {
+			return err
+		}
+
+		err = r.publishTarget(ctx, ref, data, previous[ref])
+		if errors.Is(err, errTargetNotOwned) {
+			// Left as is, the keys there aren't ours to remove
+			delete(previous, ref)
+
+			notOwned = append(notOwned, fmt.Sprintf("%s %s/%s", ref.kind, ref.namespace, ref.name))
+
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		delete(previous, ref)
+
+		published = append(published, torv1alpha2.PublishedTarget{
+			Kind:      ref.kind,
+			Name:      ref.name,
+			Namespace: ref.namespace,
+			Keys:      sortedKeys(data),
+		})
+	}
+
+	if spec.DNSEndpoint != nil {
+		installed, err := r.reconcileDNSEndpoint(ctx, onion)
+		if err != nil {
+			return err
+		}
+
+		if installed {
+			ref := publishedRef{dnsEndpointKind, onion.Namespace, onion.Name}
+			delete(previous, ref)
+
+			published = append(published, torv1alpha2.PublishedTarget{
+				Kind:      ref.kind,
+				Name:      ref.name,
+				Namespace: ref.namespace,
+			})
+		} else {
+			logger.Info("ExternalDNS DNSEndpoint CRD not found, the TXT record is not published")
+		}
+	}
+
+	for ref, keys := range previous {
+		err := r.unpublish(ctx, onion, ref, keys)
+		if err != nil {
+			return err
+		}
+	}
+
+	onion.Status.Published = published
+
+	switch {
+	case !publishConfigured(onion):
+		meta.RemoveStatusCondition(&onion.Status.Conditions, torv1alpha2.OnionServicePublished)
+	case len(denied) > 0:
+		setPublishedCondition(onion, metav1.ConditionFalse, "RefNotPermitted",
+			"no TorReferenceGrant allows writing to "+strings.Join(denied, ", "))
+	case len(notOwned) > 0:
+		setPublishedCondition(onion, metav1.ConditionFalse, "TargetNotOwned",
+			strings.Join(notOwned, ", ")+" already hold keys and have no "+publishedLabel+" label")
+	default:
+		setPublishedCondition(onion, metav1.ConditionTrue, "Published", "the onion address is published")
+	}
+
+	return nil
+}
+
+func publishConfigured(onion *torv1alpha2.OnionService) bool {
+	return len(onion.Spec.Publish.Targets) > 0 || onion.Spec.Publish.DNSEndpoint != nil
+}
+
+func publishTargetRef(onion *torv1alpha2.OnionService, target *torv1alpha2.PublishTarget) publishedRef {
+	ref := publishedRef{target.Kind, target.Namespace, target.Name}
+
+	if ref.kind == "" {
+		ref.kind = "ConfigMap"
+	}
+
+	if ref.namespace == "" {
+		ref.namespace = onion.Namespace
+	}
+
+	return ref
+}
+
+func setPublishedCondition(
+	onion *torv1alpha2.OnionService,
+	status metav1.ConditionStatus,
+	reason, message string,
+) {
+	meta.SetStatusCondition(&onion.Status.Conditions, metav1.Condition{
+		Type:               torv1alpha2.OnionServicePublished,
+		Status:             status,
+		ObservedGeneration: onion.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+// publishedData returns the keys written to target.
+func (r *OnionServiceReconciler) publishedData(
+	ctx context.Context,
+	onion *torv1alpha2.OnionService,
+	target *torv1alpha2.PublishTarget,
+	kind string,
+) (map[string][]byte, error) {
+	data := map[string][]byte{
+		target.KeyPrefix + publishedHostnameKey: []byte(onion.Status.Hostname),
+	}
+
+	if target.PublicKey {
+		var secret corev1.Secret
+
+		err := r.Get(ctx, types.NamespacedName{Name: onion.SecretName(), Namespace: onion.Namespace}, &secret)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return nil, errors.Wrap(err, "failed to get secret")
+		}
+
+		if publicKeyFile, ok := secret.Data["publicKeyFile"]; ok {
+			data[target.KeyPrefix+publishedPublicKeyKey] = publicKeyFile
+		}
+	}
+
+	// Private keys don't go into ConfigMaps
+	if !target.AuthorizedClients || kind != "Secret" {
+		return data, nil
+	}
+
+	serviceID := strings.TrimSuffix(onion.Status.Hostname, ".onion")
+
+	for idx, authorizedClientSecretRef := range onion.Spec.AuthorizedClients {
+		var secret corev1.Secret
+
+		err := r.Get(ctx, types.NamespacedName{Name: authorizedClientSecretRef.Name, Namespace: onion.Namespace}, &secret)
+		if apierrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "failed to get secret %s", authorizedClientSecretRef.Name)
+		}
+
+		privateKey, ok := secret.Data[privateKeyLabel]
+		if !ok {
+			continue
+		}
+
+		data[target.KeyPrefix+fmt.Sprintf("client-%d.auth_private", idx)] = []byte(fmt.Sprintf("%s:%s:%s:%s",
+			serviceID, authTypeDefault, keyTypeDefault, strings.TrimSpace(string(privateKey))))
+	}
+
+	return data, nil
+}
+
+// publishTarget writes data into the ConfigMap or Secret of ref, creating it
+// when missing, and removes the previous keys data no longer holds.
+func (r *OnionServiceReconciler) publishTarget(
+	ctx context.Context,
+	ref publishedRef,
+	data map[string][]byte,
+	previous []string,
+) error {
+	key := types.NamespacedName{Name: ref.name, Namespace: ref.namespace}
+	labels := map[string]string{publishedLabel: publishedCreated}
+
+	if ref.kind == "Secret" {
+		var secret corev1.Secret
+
+		err := r.Get(ctx, key, &secret)
+		if apierrors.IsNotFound(err) {
+			secret = corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: ref.name, Namespace: ref.namespace, Labels: labels}}
+			setSecretKeys(&secret, data, nil)
+
+			err = r.Create(ctx, &secret)
+			if err != nil {
+				return errors.Wrapf(err, "failed to create Secret %s/%s", ref.namespace, ref.name)
+			}
+
+			return nil
+		} else if err != nil {
+			return errors.Wrapf(err, "failed to get Secret %s/%s", ref.namespace, ref.name)
+		}
+
+		err = claimTarget(&secret.ObjectMeta, len(secret.Data) == 0)
+		if err != nil {
+			return errors.Wrapf(err, "Secret %s/%s", ref.namespace, ref.name)
+		}
+
+		if !setSecretKeys(&secret, data, previous) {
+			return nil
+		}
+
+		err = r.Update(ctx, &secret)
+		if err != nil {
+			return errors.Wrapf(err, "failed to update Secret %s/%s", ref.namespace, ref.name)
+		}
+
+		return nil
+	}
+
+	var configMap corev1.ConfigMap
+
+	err := r.Get(ctx, key, &configMap)
+	if apierrors.IsNotFound(err) {
+		configMap = corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: ref.name, Namespace: ref.namespace, Labels: labels}}
+		setConfigMapKeys(&configMap, data, nil)
+
+		err = r.Create(ctx, &configMap)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create ConfigMap %s/%s", ref.namespace, ref.name)
+		}
+
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "failed to get ConfigMap %s/%s", ref.namespace, ref.name)
+	}
+
+	err = claimTarget(&configMap.ObjectMeta, len(configMap.Data)+len(configMap.BinaryData) == 0)
+	if err != nil {
+		return errors.Wrapf(err, "ConfigMap %s/%s", ref.namespace, ref.name)
+	}
+
+	if !setConfigMapKeys(&configMap, data, previous) {
+		return nil
+	}
+
+	err = r.Update(ctx, &configMap)
+	if err != nil {
+		return errors.Wrapf(err, "failed to update ConfigMap %s/%s", ref.namespace, ref.name)
+	}
+
+	return nil
+}
+
+// claimTarget labels an existing target as published, which is only allowed
+// while it is empty. The keys of the other targets may belong to someone else.
+// Claimed targets aren't deleted once empty, they were there before.
+func claimTarget(object *metav1.ObjectMeta, empty bool) error {
+	if _, ok := object.Labels[publishedLabel]; ok {
+		return nil
+	}
+
+	if !empty {
+		return errTargetNotOwned
+	}
+
+	metav1.SetMetaDataLabel(object, publishedLabel, publishedClaimed)
+
+	return nil
+}
+
+// unpublish removes the keys written to ref. The targets created by the
+// controller are deleted once empty.
+func (r *OnionServiceReconciler) unpublish(
+	ctx context.Context,
+	onion *torv1alpha2.OnionService,
+	ref publishedRef,
+	keys []string,
+) error {
+	key := types.NamespacedName{Name: ref.name, Namespace: ref.namespace}
+
+	var object client.Object
+
+	switch ref.kind {
+	case dnsEndpointKind:
+		return r.deleteDNSEndpoint(ctx, onion, key)
+	case "Secret":
+		var secret corev1.Secret
+
+		err := r.Get(ctx, key, &secret)
+		if err != nil {
+			return errors.Wrapf(client.IgnoreNotFound(err), "failed to get Secret %s/%s", ref.namespace, ref.name)
+		}
+
+		if !setSecretKeys(&secret, nil, keys) {
+			return nil
+		}
+
+		if len(secret.Data) == 0 && secret.Labels[publishedLabel] == publishedCreated {
+			return errors.Wrapf(client.IgnoreNotFound(r.Delete(ctx, &secret)),
+				"failed to delete Secret %s/%s", ref.namespace, ref.name)
+		}
+
+		object = &secret
+	default:
+		var configMap corev1.ConfigMap
+
+		err := r.Get(ctx, key, &configMap)
+		if err != nil {
+			return errors.Wrapf(client.IgnoreNotFound(err), "failed to get ConfigMap %s/%s", ref.namespace, ref.name)
+		}
+
+		if !setConfigMapKeys(&configMap, nil, keys) {
+			return nil
+		}
+
+		if len(configMap.Data)+len(configMap.BinaryData) == 0 && configMap.Labels[publishedLabel] == publishedCreated {
+			return errors.Wrapf(client.IgnoreNotFound(r.Delete(ctx, &configMap)),
+				"failed to delete ConfigMap %s/%s", ref.namespace, ref.name)
+		}
+
+		object = &configMap
+	}
+
+	err := r.Update(ctx, object)
+	if err != nil {
+		return errors.Wrapf(err, "failed to update %s %s/%s", ref.kind, ref.namespace, ref.name)
+	}
+
+	return nil
+}
+
+// setConfigMapKeys writes data into configMap, the values that aren't UTF-8
+// into BinaryData, and removes the previous keys data no longer holds. It
+// tells whether configMap changed.
+func setConfigMapKeys(configMap *corev1.ConfigMap, data map[string][]byte, previous []string) bool {
+	changed := false
+
+	for _, key := range previous {
+		if _, ok := data[key]; ok {
+			continue
+		}
+
+		if _, ok := configMap.Data[key]; ok {
+			delete(configMap.Data, key)
+
+			changed = true
+		}
+
+		if _, ok := configMap.BinaryData[key]; ok {
+			delete(configMap.BinaryData, key)
+
+			changed = true
+		}
+	}
+
+	for key, value := range data {
+		if utf8.Valid(value) {
+			if current, ok := configMap.Data[key]; !ok || current != string(value) {
+				if configMap.Data == nil {
+					configMap.Data = map[string]string{}
+				}
+
+				configMap.Data[key] = string(value)
+				changed = true
+			}
+
+			if _, ok := configMap.BinaryData[key]; ok {
+				delete(configMap.BinaryData, key)
+
+				changed = true
+			}
+
+			continue
+		}
+
+		if current, ok := configMap.BinaryData[key]; !ok || !bytes.Equal(current, value) {
+			if configMap.BinaryData == nil {
+				configMap.BinaryData = map[string][]byte{}
+			}
+
+			configMap.BinaryData[key] = value
+			changed = true
+		}
+
+		if _, ok := configMap.Data[key]; ok {
+			delete(configMap.Data, key)
+
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// setSecretKeys writes data into secret, and removes the previous keys data
+// no longer holds. It tells whether secret changed.
+func setSecretKeys(secret *corev1.Secret, data map[string][]byte, previous []string) bool {
+	changed := false
+
+	for _, key := range previous {
+		if _, ok := data[key]; ok {
+			continue
+		}
+
+		if _, ok := secret.Data[key]; ok {
+			delete(secret.Data, key)
+
+			changed = true
+		}
+	}
+
+	for key, value := range data {
+		if current, ok := secret.Data[key]; ok && bytes.Equal(current, value) {
+			continue
+		}
+
+		if secret.Data == nil {
+			secret.Data = map[string][]byte{}
+		}
+
+		secret.Data[key] = value
+		changed = true
+	}
+
+	return changed
+}
+
+func sortedKeys(data map[string][]byte) []string {
+	keys := make([]string, 0, len(data))
+
+	for key := range data {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// reconcileDNSEndpoint keeps the TXT record of onion. It tells whether the
+// ExternalDNS CRD is installed.
+func (r *OnionServiceReconciler) reconcileDNSEndpoint(ctx context.Context, onion *torv1alpha2.OnionService) (bool, error) {
+	logger := k8slog.FromContext(ctx)
+	dns := onion.Spec.Publish.DNSEndpoint
+
+	endpoint := map[string]interface{}{
+		"dnsName":    dns.DNSName,
+		"recordType": "TXT",
+		"targets":    []interface{}{"onion-location=http://" + onion.Status.Hostname},
+	}
+
+	if dns.RecordTTL > 0 {
+		endpoint["recordTTL"] = dns.RecordTTL
+	}
+
+	newEndpoint := &unstructured.Unstructured{}
+	newEndpoint.SetGroupVersionKind(dnsEndpointGVK)
+	newEndpoint.SetName(onion.Name)
+	newEndpoint.SetNamespace(onion.Namespace)
+	newEndpoint.Object["spec"] = map[string]interface{}{
+		"endpoints": []interface{}{endpoint},
+	}
+
+	err := controllerutil.SetControllerReference(onion, newEndpoint, r.Scheme)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to set controller reference")
+	}
+
+	dnsEndpoint := &unstructured.Unstructured{}
+	dnsEndpoint.SetGroupVersionKind(dnsEndpointGVK)
+
+	err = r.Get(ctx, types.NamespacedName{Name: onion.Name, Namespace: onion.Namespace}, dnsEndpoint)
+	if apierrors.IsNotFound(err) {
+		err = r.Create(ctx, newEndpoint)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to create DNSEndpoint %s", onion.Name)
+		}
+
+		return true, nil
+	} else if meta.IsNoMatchError(err) {
+		return false, nil
+	} else if err != nil {
+		return false, errors.Wrapf(err, "failed to get DNSEndpoint %s", onion.Name)
+	}
+
+	if !metav1.IsControlledBy(dnsEndpoint, onion) {
+		logger.Info(fmt.Sprintf("DNSEndpoint %s already exists and is not controlled by %s", onion.Name, onion.Name))
+		recordNotControlled(dnsEndpointKind, dnsEndpoint, onion)
+
+		return true, nil
+	}
+
+	if reflect.DeepEqual(dnsEndpoint.Object["spec"], newEndpoint.Object["spec"]) {
+		return true, nil
+	}
+
+	dnsEndpoint.Object["spec"] = newEndpoint.Object["spec"]
+
+	err = r.Update(ctx, dnsEndpoint)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to update DNSEndpoint %s", onion.Name)
+	}
+
+	return true, nil
+}
+
+func (r *OnionServiceReconciler) deleteDNSEndpoint(
+	ctx context.Context,
+	onion *torv1alpha2.OnionService,
+	key types.NamespacedName,
+) error {
+	dnsEndpoint := &unstructured.Unstructured{}
+	dnsEndpoint.SetGroupVersionKind(dnsEndpointGVK)
+
+	err := r.Get(ctx, key, dnsEndpoint)
+	if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "failed to get DNSEndpoint %s", key.Name)
+	}
+
+	if !metav1.IsControlledBy(dnsEndpoint, onion) {
+		return nil
+	}
+
+	err = r.Delete(ctx, dnsEndpoint)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete DNSEndpoint %s", key.Name)
+	}
+
+	return nil
+}
+
+// updatePublishFinalizer keeps the finalizer while the onion address is, or
+// is to be, published.
+func (r *OnionServiceReconciler) updatePublishFinalizer(ctx context.Context, onion *torv1alpha2.OnionService) error {
+	wanted := publishConfigured(onion) || len(onion.Status.Published) > 0
+	if wanted == controllerutil.ContainsFinalizer(onion, publishFinalizer) {
+		return nil
+	}
+
+	if wanted {
+		controllerutil.AddFinalizer(onion, publishFinalizer)
+	} else {
+		controllerutil.RemoveFinalizer(onion, publishFinalizer)
+	}
+
+	err := r.Update(ctx, onion)
+	if err != nil {
+		return errors.Wrap(err, "unable to update OnionService finalizers")
+	}
+
+	return nil
+}
+
+// addPublishFinalizer adds the finalizer before the first publish target is
+// written. The status of onion is kept, Update doesn't write it.
+func (r *OnionServiceReconciler) addPublishFinalizer(ctx context.Context, onion *torv1alpha2.OnionService) error {
+	if controllerutil.ContainsFinalizer(onion, publishFinalizer) {
+		return nil
+	}
+
+	status := onion.Status.DeepCopy()
+
+	controllerutil.AddFinalizer(onion, publishFinalizer)
+
+	err := r.Update(ctx, onion)
+	if err != nil {
+		return errors.Wrap(err, "unable to update OnionService finalizers")
+	}
+
+	onion.Status = *status
+
+	return nil
+}
+
+// finalizePublish removes the published keys of a deleted OnionService.
+func (r *OnionServiceReconciler) finalizePublish(ctx context.Context, onion *torv1alpha2.OnionService) error {
+	if !controllerutil.ContainsFinalizer(onion, publishFinalizer) {
+		return nil
+	}
+
+	for _, target := range onion.Status.Published {
+		err := r.unpublish(ctx, onion, publishedRef{target.Kind, target.Namespace, target.Name}, target.Keys)
+		if err != nil {
+			return err
+		}
+	}
+
+	controllerutil.RemoveFinalizer(onion, publishFinalizer)
+
+	err := r.Update(ctx, onion)
+	if err != nil {
+		return errors.Wrap(err, "unable to update OnionService finalizers")
+	}
+
+	return nil
+}
+
+// hostnameChanged lets through the onion address updates of the tor agent,
+// which don't change the generation.
+func hostnameChanged() predicate.Funcs {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldOnion, ok := e.ObjectOld.(*torv1alpha2.OnionService)
+			if !ok {
+				return false
+			}
+
+			newOnion, ok := e.ObjectNew.(*torv1alpha2.OnionService)
+			if !ok {
+				return false
+			}
+
+			return oldOnion.Status.Hostname != newOnion.Status.Hostname
+		},
+	}
+}
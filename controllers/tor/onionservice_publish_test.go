@@ -0,0 +1,189 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tor
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	torv1alpha2 "github.com/bugfest/tor-controller/apis/tor/v1alpha2"
+)
+
+const testHostname = "abcdef.onion"
+
+func publishTestScheme(t *testing.T) *runtime.Scheme {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+
+	err := clientgoscheme.AddToScheme(scheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = torv1alpha2.AddToScheme(scheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return scheme
+}
+
+func TestReconcilePublish(t *testing.T) {
+	tests := []struct {
+		name      string
+		existing  *corev1.ConfigMap
+		written   bool
+		label     string
+		condition string
+	}{
+		{
+			name:      "missing target",
+			written:   true,
+			label:     publishedCreated,
+			condition: "Published",
+		},
+		{
+			name: "empty target",
+			existing: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "addresses", Namespace: "default"},
+			},
+			written:   true,
+			label:     publishedClaimed,
+			condition: "Published",
+		},
+		{
+			name: "labelled target",
+			existing: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "addresses",
+					Namespace: "default",
+					Labels:    map[string]string{publishedLabel: "shared"},
+				},
+				Data: map[string]string{"other": "value"},
+			},
+			written:   true,
+			label:     "shared",
+			condition: "Published",
+		},
+		{
+			name: "target of someone else",
+			existing: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "addresses", Namespace: "default"},
+				Data:       map[string]string{"hostname": "mine"},
+			},
+			condition: "TargetNotOwned",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := publishTestScheme(t)
+
+			onion := &torv1alpha2.OnionService{
+				ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "default"},
+				Spec: torv1alpha2.OnionServiceSpec{
+					Publish: torv1alpha2.PublishSpec{
+						Targets: []torv1alpha2.PublishTarget{{Name: "addresses"}},
+					},
+				},
+			}
+
+			objects := []client.Object{onion.DeepCopy()}
+			if tt.existing != nil {
+				objects = append(objects, tt.existing)
+			}
+
+			r := &OnionServiceReconciler{
+				Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build(),
+				Scheme: scheme,
+			}
+			ctx := context.Background()
+
+			err := r.Get(ctx, client.ObjectKeyFromObject(onion), onion)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			onion.Status.Hostname = testHostname
+
+			err = r.reconcilePublish(ctx, onion)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The finalizer is stored, and the status being built is kept
+			var stored torv1alpha2.OnionService
+
+			err = r.Get(ctx, client.ObjectKeyFromObject(onion), &stored)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !controllerutil.ContainsFinalizer(&stored, publishFinalizer) {
+				t.Errorf("finalizers = %v, want %s", stored.Finalizers, publishFinalizer)
+			}
+
+			if onion.Status.Hostname != testHostname {
+				t.Errorf("status hostname = %q after adding the finalizer, want %q", onion.Status.Hostname, testHostname)
+			}
+
+			condition := meta.FindStatusCondition(onion.Status.Conditions, torv1alpha2.OnionServicePublished)
+			if condition == nil || condition.Reason != tt.condition {
+				t.Errorf("Published condition = %+v, want reason %s", condition, tt.condition)
+			}
+
+			var configMap corev1.ConfigMap
+
+			err = r.Get(ctx, types.NamespacedName{Name: "addresses", Namespace: "default"}, &configMap)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if written := configMap.Data["hostname"] == testHostname; written != tt.written {
+				t.Errorf("hostname key = %q, written %v, want %v", configMap.Data["hostname"], written, tt.written)
+			}
+
+			if got := configMap.Labels[publishedLabel]; got != tt.label {
+				t.Errorf("%s label = %q, want %q", publishedLabel, got, tt.label)
+			}
+
+			if tt.existing != nil {
+				for key, value := range tt.existing.Data {
+					if configMap.Data[key] != value && key != "hostname" {
+						t.Errorf("key %s = %q, want it kept as %q", key, configMap.Data[key], value)
+					}
+				}
+			}
+
+			if !tt.written && len(onion.Status.Published) != 0 {
+				t.Errorf("status published = %+v, want none", onion.Status.Published)
+			}
+		})
+	}
+}
@@ -62,6 +62,7 @@ func (r *OnionServiceReconciler) reconcileRole(ctx context.Context, onionService
 	}
 
 	if !metav1.IsControlledBy(&role.ObjectMeta, onionService) {
+		recordNotControlled("Role", &role, onionService)
 		logger.Info("Role already exists and is not controlled by",
 			"role", role.Name,
 			"controller", onionService.Name)
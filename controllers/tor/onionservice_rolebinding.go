@@ -62,6 +62,7 @@ func (r *OnionServiceReconciler) reconcileRolebinding(ctx context.Context, onion
 	}
 
 	if !metav1.IsControlledBy(&roleBinding.ObjectMeta, onionService) {
+		recordNotControlled("RoleBinding", &roleBinding, onionService)
 		logger.Info("RoleBinding already exists and is not controlled by",
 			"roleBinding", roleBinding.Name,
 			"controller", onionService.Name)
@@ -56,6 +56,8 @@ func (r *OnionServiceReconciler) reconcileSecret(ctx context.Context, onionServi
 			return errors.Wrap(err, "failed to create secret")
 		}
 
+		keysGenerated.WithLabelValues("OnionService").Inc()
+
 		secret = *newSecret
 	} else if err != nil {
 		return errors.Wrap(err, "failed to get secret")
@@ -67,6 +69,7 @@ func (r *OnionServiceReconciler) reconcileSecret(ctx context.Context, onionServi
 		// msg := fmt.Sprintf(MessageResourceExists, service.Name)
 		// bc.recorder.Event(onionService, corev1.EventTypeWarning, ErrResourceExists, msg)
 		// return errors.New(msg)
+		recordNotControlled("Secret", &secret, onionService)
 		logger.Info("Secret already exists and is not controlled by",
 			"secret", secret.Name,
 			"controller", onionService.Name)
@@ -119,6 +119,7 @@ func (r *OnionServiceReconciler) reconcileSecretAuthorizedClients(ctx context.Co
 
 	if !metav1.IsControlledBy(&secret.ObjectMeta, onionService) {
 		// TODO: generate MessageResourceExists event
+		recordNotControlled("Secret", &secret, onionService)
 		logger.Info("Secret already exists and is not controlled by",
 			"secret", secret.Name,
 			"controller", onionService.Name)
@@ -63,6 +63,7 @@ func (r *OnionServiceReconciler) reconcileService(ctx context.Context, onionServ
 	}
 
 	if !metav1.IsControlledBy(&service.ObjectMeta, onionService) {
+		recordNotControlled("Service", &service, onionService)
 		logger.Info("Service already exists and is not controlled by",
 			"service", service.Name,
 			"controller", onionService.Name)
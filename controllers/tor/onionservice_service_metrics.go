@@ -63,6 +63,7 @@ func (r *OnionServiceReconciler) reconcileMetricsService(ctx context.Context, on
 	}
 
 	if !metav1.IsControlledBy(&service.ObjectMeta, onionService) {
+		recordNotControlled("Service", &service, onionService)
 		logger.Info("Service already exists and is not controlled by",
 			"service", service.Name,
 			"controller", onionService.Name)
@@ -97,11 +98,18 @@ func osTorMetricsService(onion *torv1alpha2.OnionService) *corev1.Service {
 		},
 		Spec: corev1.ServiceSpec{
 			Selector: onion.ServiceSelector(),
-			Ports: []corev1.ServicePort{{
-				Name:       "metrics",
-				TargetPort: intstr.FromInt(metricsPort),
-				Port:       metricsPort,
-			}},
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "metrics",
+					TargetPort: intstr.FromInt(metricsPort),
+					Port:       metricsPort,
+				},
+				{
+					Name:       "agent-metrics",
+					TargetPort: intstr.FromInt(agentMetricsPort),
+					Port:       agentMetricsPort,
+				},
+			},
 		},
 	}
 }
@@ -59,7 +59,7 @@ func (r *OnionServiceReconciler) reconcileServiceMonitor(ctx context.Context, on
 	newService := osTorServiceMonitor(onionService)
 
 	if apierrors.IsNotFound(err) {
-		if !onionService.Spec.ServiceMonitor {
+		if !onionService.Monitoring().ServiceMonitorEnabled() {
 			// ServiceMonitor is not requested, skipping
 			return nil
 		}
@@ -75,6 +75,7 @@ func (r *OnionServiceReconciler) reconcileServiceMonitor(ctx context.Context, on
 	}
 
 	if !metav1.IsControlledBy(&service.ObjectMeta, onionService) {
+		recordNotControlled("ServiceMonitor", &service, onionService)
 		logger.Info("ServiceMonitor already exists and is not controlled by",
 			"service", service.Name,
 			"controller", onionService.Name)
@@ -82,7 +83,7 @@ func (r *OnionServiceReconciler) reconcileServiceMonitor(ctx context.Context, on
 		return nil
 	}
 
-	if !onionService.Spec.ServiceMonitor {
+	if !onionService.Monitoring().ServiceMonitorEnabled() {
 		// ServiceMonitor is not requested but exists, deleting
 		err = r.Delete(ctx, &service)
 		if err != nil {
@@ -110,7 +111,7 @@ func osTorServiceMonitor(onion *torv1alpha2.OnionService) *monitoringv1.ServiceM
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      onion.ServiceMetricsName(),
 			Namespace: onion.Namespace,
-			Labels:    onion.ServiceMetricsSelector(),
+			Labels:    monitorLabels(onion.ServiceMetricsSelector(), onion.Monitoring()),
 			OwnerReferences: []metav1.OwnerReference{
 				*metav1.NewControllerRef(onion, schema.GroupVersionKind{
 					Group:   torv1alpha2.GroupVersion.Group,
@@ -123,12 +124,7 @@ func osTorServiceMonitor(onion *torv1alpha2.OnionService) *monitoringv1.ServiceM
 			Selector: metav1.LabelSelector{
 				MatchLabels: onion.ServiceMetricsSelector(),
 			},
-			Endpoints: []monitoringv1.Endpoint{
-				{
-					Port: "metrics",
-					Path: "/metrics",
-				},
-			},
+			Endpoints: serviceMonitorEndpoints(onion.Monitoring(), "metrics", "agent-metrics"),
 			NamespaceSelector: monitoringv1.NamespaceSelector{
 				MatchNames: []string{
 					onion.Namespace,
@@ -62,6 +62,7 @@ func (r *OnionServiceReconciler) reconcileServiceAccount(ctx context.Context, on
 	}
 
 	if !metav1.IsControlledBy(&serviceAccount.ObjectMeta, onionService) {
+		recordNotControlled("ServiceAccount", &serviceAccount, onionService)
 		logger.Info("ServiceAccount already exists and is not controlled by",
 			"serviceAccount", serviceAccount.Name,
 			"controller", onionService.Name)
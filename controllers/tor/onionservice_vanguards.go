@@ -0,0 +1,133 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tor
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	configv2 "github.com/bugfest/tor-controller/apis/config/v2"
+	torv1alpha2 "github.com/bugfest/tor-controller/apis/tor/v1alpha2"
+)
+
+const (
+	// The add-on is shipped in the onionbalance manager image, which already
+	// provides python and stem
+	vanguardsScript = `printf '%s' "$VANGUARDS_CONFIG" > /tmp/vanguards.conf && exec vanguards --config /tmp/vanguards.conf`
+)
+
+// vanguardsContainer returns the vanguards add-on sidecar. It talks to tor
+// through the control socket in the shared control volume.
+func vanguardsContainer(onion *torv1alpha2.OnionService, projectConfig *configv2.ProjectConfig) corev1.Container {
+	return corev1.Container{
+		Name:    "vanguards",
+		Image:   projectConfig.TorOnionbalanceManager.ImageRef(),
+		Command: []string{"/bin/sh", "-c", vanguardsScript},
+		Env: []corev1.EnvVar{
+			{
+				Name:  "VANGUARDS_CONFIG",
+				Value: vanguardsConfig(&onion.Spec.Vanguards),
+			},
+		},
+		ImagePullPolicy: projectConfig.TorOnionbalanceManager.PullPolicy(),
+		SecurityContext: componentSecurityContext(&projectConfig.TorOnionbalanceManager.ComponentDefaults),
+		Resources:       *projectConfig.TorOnionbalanceManager.Resources.DeepCopy(),
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      torControlVolume,
+				MountPath: torv1alpha2.TorControlDir,
+			},
+			{
+				// the config and state files
+				Name:      tmpVolume,
+				MountPath: "/tmp",
+			},
+		},
+	}
+}
+
+// vanguardsConfig renders the add-on configuration file. Unset options keep
+// the add-on defaults.
+func vanguardsConfig(vanguards *torv1alpha2.VanguardsSpec) string {
+	global := []string{
+		"[Global]",
+		fmt.Sprintf("control_socket = %s/control.sock", torv1alpha2.TorControlDir),
+		"state_file = /tmp/vanguards.state",
+	}
+
+	layers := []string{"[Vanguards]"}
+
+	if vanguards.Layer2Size > 0 {
+		layers = append(layers, fmt.Sprintf("num_layer2_guards = %d", vanguards.Layer2Size))
+	}
+
+	if vanguards.Layer3Size > 0 {
+		layers = append(layers, fmt.Sprintf("num_layer3_guards = %d", vanguards.Layer3Size))
+	}
+
+	lifetimes := []struct {
+		option   string
+		duration *metav1.Duration
+	}{
+		{"min_layer2_lifetime_hours", vanguards.Rotation.MinLayer2Lifetime},
+		{"max_layer2_lifetime_hours", vanguards.Rotation.MaxLayer2Lifetime},
+		{"min_layer3_lifetime_hours", vanguards.Rotation.MinLayer3Lifetime},
+		{"max_layer3_lifetime_hours", vanguards.Rotation.MaxLayer3Lifetime},
+	}
+
+	for _, lifetime := range lifetimes {
+		if lifetime.duration == nil {
+			continue
+		}
+
+		// The add-on works with hours, one at least
+		hours := math.Max(1, math.Round(lifetime.duration.Hours()))
+		layers = append(layers, fmt.Sprintf("%s = %d", lifetime.option, int(hours)))
+	}
+
+	return strings.Join(global, "\n") + "\n\n" + strings.Join(layers, "\n") + "\n"
+}
+
+// setVanguardsCondition records in the status whether vanguards are
+// configured. It reflects the spec, not whether the add-on is running.
+func setVanguardsCondition(onion *torv1alpha2.OnionService) {
+	condition := metav1.Condition{
+		Type:               torv1alpha2.OnionServiceVanguardsConfigured,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: onion.Generation,
+		Reason:             "Disabled",
+		Message:            "vanguards are disabled",
+	}
+
+	switch {
+	case onion.Spec.Vanguards.LiteEnabled():
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "VanguardsLite"
+		condition.Message = "tor built-in vanguards-lite is configured"
+	case onion.Spec.Vanguards.FullEnabled():
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "VanguardsAddon"
+		condition.Message = "vanguards add-on is configured next to tor"
+	}
+
+	meta.SetStatusCondition(&onion.Status.Conditions, condition)
+}
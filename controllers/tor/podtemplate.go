@@ -0,0 +1,172 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tor
+
+import (
+	"encoding/json"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+
+	"github.com/cockroachdb/errors"
+)
+
+// mergePodTemplate overlays the pod template from the user's resource on
+// the one generated by the controller, with strategic merge patch semantics.
+// Containers and volumes merge by name, so a user container named like a
+// generated one only overrides the fields it sets (env, probes...) instead
+// of ending up duplicated. The volume mounts of such containers merge by
+// name as well, while a user volume replaces the generated one with the same
+// name: merging two volume sources would make an invalid volume.
+func mergePodTemplate(generated, user *corev1.PodTemplateSpec) (corev1.PodTemplateSpec, error) {
+	var merged corev1.PodTemplateSpec
+
+	original := generated.DeepCopy()
+	original.Spec.Volumes = volumesNotIn(original.Spec.Volumes, user.Spec.Volumes)
+	overlayVolumeMountsByName(original.Spec.Containers, user.Spec.Containers)
+	overlayVolumeMountsByName(original.Spec.InitContainers, user.Spec.InitContainers)
+
+	originalJSON, err := json.Marshal(original)
+	if err != nil {
+		return merged, errors.Wrap(err, "failed to encode the generated pod template")
+	}
+
+	patchJSON, err := podTemplatePatch(user)
+	if err != nil {
+		return merged, err
+	}
+
+	mergedJSON, err := strategicpatch.StrategicMergePatch(originalJSON, patchJSON, corev1.PodTemplateSpec{})
+	if err != nil {
+		return merged, errors.Wrap(err, "failed to merge the pod template")
+	}
+
+	err = json.Unmarshal(mergedJSON, &merged)
+	if err != nil {
+		return merged, errors.Wrap(err, "failed to decode the merged pod template")
+	}
+
+	return merged, nil
+}
+
+// overlayVolumeMountsByName drops the generated volume mounts that a user
+// container with the same name mounts again, possibly elsewhere. Strategic
+// merge patches key volume mounts by path, which would keep both.
+func overlayVolumeMountsByName(generated, user []corev1.Container) {
+	for _, userContainer := range user {
+		overlaid := map[string]bool{}
+		for _, mount := range userContainer.VolumeMounts {
+			overlaid[mount.Name] = true
+		}
+
+		for i := range generated {
+			if generated[i].Name != userContainer.Name {
+				continue
+			}
+
+			mounts := []corev1.VolumeMount{}
+
+			for _, mount := range generated[i].VolumeMounts {
+				if !overlaid[mount.Name] {
+					mounts = append(mounts, mount)
+				}
+			}
+
+			generated[i].VolumeMounts = mounts
+		}
+	}
+}
+
+// hasVolumeMount reports whether the container mounts the volume.
+func hasVolumeMount(container *corev1.Container, volume string) bool {
+	for _, mount := range container.VolumeMounts {
+		if mount.Name == volume {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasContainer(containers []corev1.Container, name string) bool {
+	for i := range containers {
+		if containers[i].Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// volumesNotIn returns the volumes that aren't replaced by a volume with the
+// same name.
+func volumesNotIn(volumes, replacements []corev1.Volume) []corev1.Volume {
+	replaced := map[string]bool{}
+	for _, volume := range replacements {
+		replaced[volume.Name] = true
+	}
+
+	kept := []corev1.Volume{}
+
+	for _, volume := range volumes {
+		if !replaced[volume.Name] {
+			kept = append(kept, volume)
+		}
+	}
+
+	return kept
+}
+
+// podTemplatePatch encodes the user's template as a patch. The fields that
+// aren't set encode as null, which would delete them from the generated
+// template, so they are left out.
+func podTemplatePatch(user *corev1.PodTemplateSpec) ([]byte, error) {
+	userJSON, err := json.Marshal(user)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode the pod template")
+	}
+
+	var patch map[string]interface{}
+
+	err = json.Unmarshal(userJSON, &patch)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode the pod template")
+	}
+
+	patchJSON, err := json.Marshal(withoutNulls(patch))
+
+	return patchJSON, errors.Wrap(err, "failed to encode the pod template patch")
+}
+
+func withoutNulls(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if field == nil {
+				delete(v, key)
+			} else {
+				v[key] = withoutNulls(field)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = withoutNulls(item)
+		}
+	}
+
+	return value
+}
@@ -0,0 +1,309 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tor
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func generatedTestPodTemplate() *corev1.PodTemplateSpec {
+	return &corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{"app": "tor"},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:  "tor",
+					Image: "tor:latest",
+					Env:   []corev1.EnvVar{{Name: "A", Value: "generated"}},
+					VolumeMounts: []corev1.VolumeMount{
+						{Name: torConfigVolume, MountPath: "/run/tor"},
+						{Name: torDataVolume, MountPath: "/var/lib/tor"},
+					},
+				},
+			},
+			Volumes: []corev1.Volume{
+				{
+					Name: torConfigVolume,
+					VolumeSource: corev1.VolumeSource{
+						ConfigMap: &corev1.ConfigMapVolumeSource{
+							LocalObjectReference: corev1.LocalObjectReference{Name: "generated"},
+						},
+					},
+				},
+				{
+					Name: torDataVolume,
+					VolumeSource: corev1.VolumeSource{
+						EmptyDir: &corev1.EmptyDirVolumeSource{},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestMergePodTemplate(t *testing.T) {
+	tests := []struct {
+		name   string
+		user   corev1.PodTemplateSpec
+		modify func(expected *corev1.PodTemplateSpec)
+	}{
+		{
+			name:   "empty user template",
+			user:   corev1.PodTemplateSpec{},
+			modify: func(expected *corev1.PodTemplateSpec) {},
+		},
+		{
+			name: "user container overrides a generated one",
+			user: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "tor",
+							Env:  []corev1.EnvVar{{Name: "B", Value: "user"}},
+						},
+					},
+				},
+			},
+			modify: func(expected *corev1.PodTemplateSpec) {
+				expected.Spec.Containers[0].Env = []corev1.EnvVar{
+					{Name: "B", Value: "user"},
+					{Name: "A", Value: "generated"},
+				}
+			},
+		},
+		{
+			name: "user container overrides a generated env var",
+			user: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:  "tor",
+							Image: "tor:custom",
+							Env:   []corev1.EnvVar{{Name: "A", Value: "user"}},
+						},
+					},
+				},
+			},
+			modify: func(expected *corev1.PodTemplateSpec) {
+				expected.Spec.Containers[0].Image = "tor:custom"
+				expected.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "A", Value: "user"}}
+			},
+		},
+		{
+			name: "user sidecar is added",
+			user: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "sidecar", Image: "sidecar:latest"}},
+				},
+			},
+			modify: func(expected *corev1.PodTemplateSpec) {
+				// The items of the patch come first
+				expected.Spec.Containers = append([]corev1.Container{{Name: "sidecar", Image: "sidecar:latest"}},
+					expected.Spec.Containers...)
+			},
+		},
+		{
+			name: "user volume replaces the generated one",
+			user: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Volumes: []corev1.Volume{
+						{
+							Name: torConfigVolume,
+							VolumeSource: corev1.VolumeSource{
+								Secret: &corev1.SecretVolumeSource{SecretName: "user"},
+							},
+						},
+					},
+				},
+			},
+			modify: func(expected *corev1.PodTemplateSpec) {
+				expected.Spec.Volumes[0].VolumeSource = corev1.VolumeSource{
+					Secret: &corev1.SecretVolumeSource{SecretName: "user"},
+				}
+			},
+		},
+		{
+			name: "user volume mount moves the generated one",
+			user: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:         "tor",
+							VolumeMounts: []corev1.VolumeMount{{Name: torDataVolume, MountPath: "/data"}},
+						},
+					},
+				},
+			},
+			modify: func(expected *corev1.PodTemplateSpec) {
+				expected.Spec.Containers[0].VolumeMounts = []corev1.VolumeMount{
+					{Name: torDataVolume, MountPath: "/data"},
+					{Name: torConfigVolume, MountPath: "/run/tor"},
+				}
+			},
+		},
+		{
+			name: "user labels are added",
+			user: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"team": "onion"},
+				},
+			},
+			modify: func(expected *corev1.PodTemplateSpec) {
+				expected.Labels["team"] = "onion"
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			generated := generatedTestPodTemplate()
+
+			expected := generatedTestPodTemplate()
+			tt.modify(expected)
+
+			got, err := mergePodTemplate(generated, &tt.user)
+			if err != nil {
+				t.Fatalf("mergePodTemplate() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, *expected) {
+				t.Errorf("mergePodTemplate() = %+v, want %+v", got, *expected)
+			}
+
+			if !reflect.DeepEqual(generated, generatedTestPodTemplate()) {
+				t.Errorf("mergePodTemplate() modified the generated template")
+			}
+		})
+	}
+}
+
+func TestOverlayVolumeMountsByName(t *testing.T) {
+	mounts := []corev1.VolumeMount{
+		{Name: "a", MountPath: "/a"},
+		{Name: "b", MountPath: "/b"},
+	}
+
+	tests := []struct {
+		name      string
+		user      []corev1.Container
+		container string
+		want      []corev1.VolumeMount
+	}{
+		{
+			name:      "no user containers",
+			container: "tor",
+			want:      mounts,
+		},
+		{
+			name: "other container",
+			user: []corev1.Container{
+				{Name: "sidecar", VolumeMounts: []corev1.VolumeMount{{Name: "a", MountPath: "/other"}}},
+			},
+			container: "tor",
+			want:      mounts,
+		},
+		{
+			name: "same container mounting a volume elsewhere",
+			user: []corev1.Container{
+				{Name: "tor", VolumeMounts: []corev1.VolumeMount{{Name: "a", MountPath: "/other"}}},
+			},
+			container: "tor",
+			want:      []corev1.VolumeMount{{Name: "b", MountPath: "/b"}},
+		},
+		{
+			name: "same container mounting every volume",
+			user: []corev1.Container{
+				{Name: "tor", VolumeMounts: mounts},
+			},
+			container: "tor",
+			want:      []corev1.VolumeMount{},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			generated := []corev1.Container{
+				{
+					Name:         tt.container,
+					VolumeMounts: append([]corev1.VolumeMount{}, mounts...),
+				},
+			}
+
+			overlayVolumeMountsByName(generated, tt.user)
+
+			if !reflect.DeepEqual(generated[0].VolumeMounts, tt.want) {
+				t.Errorf("overlayVolumeMountsByName() = %v, want %v", generated[0].VolumeMounts, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithoutNulls(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  interface{}
+	}{
+		{
+			name:  "scalar",
+			value: "value",
+			want:  "value",
+		},
+		{
+			name:  "null fields",
+			value: map[string]interface{}{"a": nil, "b": "value"},
+			want:  map[string]interface{}{"b": "value"},
+		},
+		{
+			name: "nested null fields",
+			value: map[string]interface{}{
+				"spec": map[string]interface{}{"resources": nil, "image": "tor"},
+			},
+			want: map[string]interface{}{
+				"spec": map[string]interface{}{"image": "tor"},
+			},
+		},
+		{
+			name: "null fields in lists",
+			value: []interface{}{
+				map[string]interface{}{"name": "tor", "env": nil},
+				"value",
+			},
+			want: []interface{}{
+				map[string]interface{}{"name": "tor"},
+				"value",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if got := withoutNulls(tt.value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("withoutNulls() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
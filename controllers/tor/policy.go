@@ -0,0 +1,234 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tor
+
+import (
+	"context"
+	"reflect"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	k8slog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1 "k8s.io/api/core/v1"
+
+	configv2 "github.com/bugfest/tor-controller/apis/config/v2"
+	torv1alpha2 "github.com/bugfest/tor-controller/apis/tor/v1alpha2"
+	"github.com/cockroachdb/errors"
+)
+
+//+kubebuilder:rbac:groups=tor.k8s.torproject.org,resources=torcontrollerpolicies,verbs=get;list;watch
+
+// projectConfigFor returns the ProjectConfig with the TorControllerPolicies
+// of the namespace applied on top of its component defaults. Policies apply
+// in name order. The TorControllerPolicy CRD is optional: without it the
+// ProjectConfig is returned as is.
+func projectConfigFor(
+	ctx context.Context, reader client.Reader, projectConfig *configv2.ProjectConfig, namespace string,
+) (*configv2.ProjectConfig, error) {
+	var policies torv1alpha2.TorControllerPolicyList
+
+	err := reader.List(ctx, &policies, client.InNamespace(namespace))
+	if meta.IsNoMatchError(err) {
+		return projectConfig, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "failed to list TorControllerPolicies in %s", namespace)
+	}
+
+	sort.Slice(policies.Items, func(i, j int) bool {
+		return policies.Items[i].Name < policies.Items[j].Name
+	})
+
+	config := projectConfig.DeepCopy()
+
+	for i := range policies.Items {
+		spec := &policies.Items[i].Spec
+
+		config.TorDaemon.ComponentDefaults = mergeComponentDefaults(&config.TorDaemon.ComponentDefaults, &spec.TorDaemon)
+		config.TorDaemonManager.ComponentDefaults = mergeComponentDefaults(&config.TorDaemonManager.ComponentDefaults, &spec.TorDaemonManager)
+		config.TorOnionbalanceManager.ComponentDefaults = mergeComponentDefaults(
+			&config.TorOnionbalanceManager.ComponentDefaults, &spec.TorOnionbalanceManager)
+	}
+
+	return config, nil
+}
+
+// mergeComponentDefaults returns the defaults with the fields set in the
+// override replaced. Node selectors are merged key by key.
+func mergeComponentDefaults(defaults, override *configv2.ComponentDefaults) configv2.ComponentDefaults {
+	merged := defaults.DeepCopy()
+	override = override.DeepCopy()
+
+	if !resourcesEmpty(&override.Resources) {
+		merged.Resources = override.Resources
+	}
+
+	if override.SecurityContext != nil {
+		merged.SecurityContext = overlaySecurityContext(merged.SecurityContext, override.SecurityContext)
+	}
+
+	for k, v := range override.NodeSelector {
+		if merged.NodeSelector == nil {
+			merged.NodeSelector = map[string]string{}
+		}
+
+		merged.NodeSelector[k] = v
+	}
+
+	if len(override.Tolerations) > 0 {
+		merged.Tolerations = override.Tolerations
+	}
+
+	if len(override.ImagePullSecrets) > 0 {
+		merged.ImagePullSecrets = override.ImagePullSecrets
+	}
+
+	if override.PriorityClassName != "" {
+		merged.PriorityClassName = override.PriorityClassName
+	}
+
+	return *merged
+}
+
+// applyComponentDefaults sets the pod-level defaults of the components
+// running in a generated pod. When several components set a priority class,
+// the first one wins.
+func applyComponentDefaults(spec *corev1.PodSpec, components ...*configv2.ComponentDefaults) {
+	for _, defaults := range components {
+		for k, v := range defaults.NodeSelector {
+			if spec.NodeSelector == nil {
+				spec.NodeSelector = map[string]string{}
+			}
+
+			if _, ok := spec.NodeSelector[k]; !ok {
+				spec.NodeSelector[k] = v
+			}
+		}
+
+		for _, toleration := range defaults.Tolerations {
+			if !hasToleration(spec.Tolerations, &toleration) {
+				spec.Tolerations = append(spec.Tolerations, toleration)
+			}
+		}
+
+		for _, secret := range defaults.ImagePullSecrets {
+			if !hasImagePullSecret(spec.ImagePullSecrets, secret.Name) {
+				spec.ImagePullSecrets = append(spec.ImagePullSecrets, secret)
+			}
+		}
+
+		if spec.PriorityClassName == "" {
+			spec.PriorityClassName = defaults.PriorityClassName
+		}
+	}
+}
+
+// containerResources returns the resources set in the resource, or the
+// component defaults.
+func containerResources(resources corev1.ResourceRequirements, defaults *configv2.ComponentDefaults) corev1.ResourceRequirements {
+	if resourcesEmpty(&resources) {
+		return *defaults.Resources.DeepCopy()
+	}
+
+	return resources
+}
+
+func resourcesEmpty(resources *corev1.ResourceRequirements) bool {
+	return len(resources.Limits) == 0 && len(resources.Requests) == 0
+}
+
+func hasToleration(tolerations []corev1.Toleration, toleration *corev1.Toleration) bool {
+	for i := range tolerations {
+		if reflect.DeepEqual(tolerations[i], *toleration) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasImagePullSecret(secrets []corev1.LocalObjectReference, name string) bool {
+	for _, secret := range secrets {
+		if secret.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// watchPolicies reconciles every object of the list kind in the namespace
+// of a TorControllerPolicy when it changes. The watch is skipped when the
+// CRD isn't installed.
+func watchPolicies(mgr ctrl.Manager, bldr *builder.Builder, list client.ObjectList) *builder.Builder {
+	_, err := mgr.GetRESTMapper().RESTMapping(schema.GroupKind{
+		Group: torv1alpha2.GroupVersion.Group,
+		Kind:  "TorControllerPolicy",
+	}, torv1alpha2.GroupVersion.Version)
+	if err != nil {
+		k8slog.Log.Info("TorControllerPolicy CRD not found, namespace defaults won't be watched")
+
+		return bldr
+	}
+
+	return bldr.Watches(
+		&source.Kind{Type: &torv1alpha2.TorControllerPolicy{}},
+		handler.EnqueueRequestsFromMapFunc(func(policy client.Object) []reconcile.Request {
+			return namespaceRequests(mgr.GetClient(), list, policy.GetNamespace())
+		}),
+	)
+}
+
+func namespaceRequests(reader client.Reader, list client.ObjectList, namespace string) []reconcile.Request {
+	objects, ok := list.DeepCopyObject().(client.ObjectList)
+	if !ok {
+		return nil
+	}
+
+	err := reader.List(context.Background(), objects, client.InNamespace(namespace))
+	if err != nil {
+		k8slog.Log.Error(err, "unable to list objects", "namespace", namespace)
+
+		return nil
+	}
+
+	items, err := meta.ExtractList(objects)
+	if err != nil {
+		k8slog.Log.Error(err, "unable to extract objects", "namespace", namespace)
+
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+
+	for _, item := range items {
+		if object, ok := item.(client.Object); ok {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: client.ObjectKeyFromObject(object),
+			})
+		}
+	}
+
+	return requests
+}
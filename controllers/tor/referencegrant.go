@@ -0,0 +1,136 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tor
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	k8slog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corev1 "k8s.io/api/core/v1"
+
+	torv1alpha2 "github.com/bugfest/tor-controller/apis/tor/v1alpha2"
+	"github.com/cockroachdb/errors"
+)
+
+//+kubebuilder:rbac:groups=tor.k8s.torproject.org,resources=torreferencegrants,verbs=get;list;watch
+
+var (
+	onionServiceGroupKind = schema.GroupKind{Group: torv1alpha2.GroupVersion.Group, Kind: "OnionService"}
+	serviceGroupKind      = schema.GroupKind{Group: corev1.GroupName, Kind: "Service"}
+)
+
+// referenceGranted tells whether a TorReferenceGrant in the namespace of to
+// allows the from kind in fromNamespace to reference it. References within
+// a namespace are always allowed. Without the TorReferenceGrant CRD, cross
+// namespace references are denied.
+func referenceGranted(
+	ctx context.Context,
+	reader client.Reader,
+	from schema.GroupKind,
+	fromNamespace string,
+	to schema.GroupKind,
+	toNamespace, toName string,
+) (bool, error) {
+	if fromNamespace == toNamespace {
+		return true, nil
+	}
+
+	var grants torv1alpha2.TorReferenceGrantList
+
+	err := reader.List(ctx, &grants, client.InNamespace(toNamespace))
+	if meta.IsNoMatchError(err) {
+		return false, nil
+	} else if err != nil {
+		return false, errors.Wrapf(err, "failed to list TorReferenceGrants in %s", toNamespace)
+	}
+
+	for i := range grants.Items {
+		spec := &grants.Items[i].Spec
+
+		if grantsFrom(spec, from, fromNamespace) && grantsTo(spec, to, toName) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func grantsFrom(spec *torv1alpha2.TorReferenceGrantSpec, from schema.GroupKind, namespace string) bool {
+	for _, grantFrom := range spec.From {
+		if grantFrom.Group == from.Group && grantFrom.Kind == from.Kind && grantFrom.Namespace == namespace {
+			return true
+		}
+	}
+
+	return false
+}
+
+func grantsTo(spec *torv1alpha2.TorReferenceGrantSpec, to schema.GroupKind, name string) bool {
+	for _, grantTo := range spec.To {
+		if grantTo.Group == to.Group && grantTo.Kind == to.Kind && (grantTo.Name == "" || grantTo.Name == name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// watchReferenceGrants reconciles every object of the list kind in the
+// namespaces a TorReferenceGrant allows when it changes. The watch is skipped
+// when the CRD isn't installed.
+func watchReferenceGrants(
+	mgr ctrl.Manager, bldr *builder.Builder, from schema.GroupKind, list client.ObjectList,
+) *builder.Builder {
+	_, err := mgr.GetRESTMapper().RESTMapping(schema.GroupKind{
+		Group: torv1alpha2.GroupVersion.Group,
+		Kind:  "TorReferenceGrant",
+	}, torv1alpha2.GroupVersion.Version)
+	if err != nil {
+		k8slog.Log.Info("TorReferenceGrant CRD not found, cross namespace references are disabled")
+
+		return bldr
+	}
+
+	return bldr.Watches(
+		&source.Kind{Type: &torv1alpha2.TorReferenceGrant{}},
+		handler.EnqueueRequestsFromMapFunc(func(object client.Object) []reconcile.Request {
+			grant, ok := object.(*torv1alpha2.TorReferenceGrant)
+			if !ok {
+				return nil
+			}
+
+			requests := []reconcile.Request{}
+
+			for _, grantFrom := range grant.Spec.From {
+				if grantFrom.Group == from.Group && grantFrom.Kind == from.Kind {
+					requests = append(requests, namespaceRequests(mgr.GetClient(), list, grantFrom.Namespace)...)
+				}
+			}
+
+			return requests
+		}),
+	)
+}
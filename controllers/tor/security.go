@@ -0,0 +1,156 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tor
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	configv2 "github.com/bugfest/tor-controller/apis/config/v2"
+)
+
+// restrictedUserID is the unprivileged user the tor and agent containers run
+// as unless the component defaults or the pod template say otherwise. It
+// matches the USER of the tor images.
+const restrictedUserID int64 = 1001
+
+// applyPodSecurityDefaults fills in the pod-wide part of the restricted
+// security profile. Anything already set in the user's template is kept. The
+// user and group are set on the generated containers only, so the sidecars in
+// the template keep the ones of their images. The service account token is
+// only mounted for the agents, which talk to the API server.
+func applyPodSecurityDefaults(spec *corev1.PodSpec, automountServiceAccountToken bool) {
+	if spec.AutomountServiceAccountToken == nil {
+		spec.AutomountServiceAccountToken = &automountServiceAccountToken
+	}
+
+	if spec.SecurityContext == nil {
+		spec.SecurityContext = &corev1.PodSecurityContext{}
+	}
+
+	securityContext := spec.SecurityContext
+
+	// Makes the mounted secrets and emptyDirs readable by the containers
+	if securityContext.FSGroup == nil {
+		fsGroup := restrictedUserID
+		securityContext.FSGroup = &fsGroup
+	}
+
+	if securityContext.SeccompProfile == nil {
+		securityContext.SeccompProfile = &corev1.SeccompProfile{
+			Type: corev1.SeccompProfileTypeRuntimeDefault,
+		}
+	}
+}
+
+// restrictedSecurityContext returns the restricted security profile for the
+// containers managed by the controller. They write only to emptyDir volumes,
+// so the root filesystem is read-only.
+func restrictedSecurityContext() *corev1.SecurityContext {
+	allowPrivilegeEscalation := false
+	readOnlyRootFilesystem := true
+	runAsNonRoot := true
+	runAsUser := restrictedUserID
+	runAsGroup := restrictedUserID
+
+	return &corev1.SecurityContext{
+		AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+		ReadOnlyRootFilesystem:   &readOnlyRootFilesystem,
+		RunAsNonRoot:             &runAsNonRoot,
+		RunAsUser:                &runAsUser,
+		RunAsGroup:               &runAsGroup,
+		Capabilities: &corev1.Capabilities{
+			Drop: []corev1.Capability{"ALL"},
+		},
+	}
+}
+
+// componentSecurityContext returns the restricted security profile with the
+// fields set in the component defaults overridden.
+func componentSecurityContext(defaults *configv2.ComponentDefaults) *corev1.SecurityContext {
+	return overlaySecurityContext(restrictedSecurityContext(), defaults.SecurityContext)
+}
+
+// overlaySecurityContext returns a copy of the security context with the
+// fields set in the override replaced.
+func overlaySecurityContext(securityContext, override *corev1.SecurityContext) *corev1.SecurityContext {
+	merged := securityContext.DeepCopy()
+	if merged == nil {
+		merged = &corev1.SecurityContext{}
+	}
+
+	if override == nil {
+		return merged
+	}
+
+	override = override.DeepCopy()
+
+	if override.Capabilities != nil {
+		merged.Capabilities = override.Capabilities
+	}
+
+	if override.Privileged != nil {
+		merged.Privileged = override.Privileged
+	}
+
+	if override.SELinuxOptions != nil {
+		merged.SELinuxOptions = override.SELinuxOptions
+	}
+
+	if override.WindowsOptions != nil {
+		merged.WindowsOptions = override.WindowsOptions
+	}
+
+	if override.RunAsUser != nil {
+		merged.RunAsUser = override.RunAsUser
+	}
+
+	if override.RunAsGroup != nil {
+		merged.RunAsGroup = override.RunAsGroup
+	}
+
+	if override.RunAsNonRoot != nil {
+		merged.RunAsNonRoot = override.RunAsNonRoot
+	}
+
+	if override.ReadOnlyRootFilesystem != nil {
+		merged.ReadOnlyRootFilesystem = override.ReadOnlyRootFilesystem
+	}
+
+	if override.AllowPrivilegeEscalation != nil {
+		merged.AllowPrivilegeEscalation = override.AllowPrivilegeEscalation
+	}
+
+	if override.ProcMount != nil {
+		merged.ProcMount = override.ProcMount
+	}
+
+	if override.SeccompProfile != nil {
+		merged.SeccompProfile = override.SeccompProfile
+	}
+
+	return merged
+}
+
+// emptyDirVolume returns a scratch volume for a writable path.
+func emptyDirVolume(name string) corev1.Volume {
+	return corev1.Volume{
+		Name: name,
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{},
+		},
+	}
+}
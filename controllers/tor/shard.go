@@ -0,0 +1,102 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tor
+
+import (
+	"context"
+	"hash/fnv"
+	"strconv"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	configv2 "github.com/bugfest/tor-controller/apis/config/v2"
+)
+
+// shardLabel pins a resource to a shard, instead of the hash of its key.
+const shardLabel = "tor.k8s.torproject.org/shard"
+
+// shardOf returns the shard owning object.
+func shardOf(sharding *configv2.ShardingConfig, object client.Object) int {
+	if value, ok := object.GetLabels()[shardLabel]; ok {
+		shard, err := strconv.Atoi(value)
+		if err == nil && shard >= 0 && shard < sharding.Shards {
+			return shard
+		}
+	}
+
+	key := object.GetNamespace()
+	if sharding.Key == configv2.ShardKeyName {
+		key += "/" + object.GetName()
+	}
+
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(key))
+
+	return int(hash.Sum32() % uint32(sharding.Shards))
+}
+
+// ownsShard tells whether this replica reconciles object. Everything is owned
+// when sharding is disabled.
+func ownsShard(sharding *configv2.ShardingConfig, object client.Object) bool {
+	if sharding == nil || sharding.Shards <= 1 {
+		return true
+	}
+
+	return shardOf(sharding, object) == sharding.Shard
+}
+
+// withSharding wraps reconciler so it skips the resources of other shards.
+// object is an empty resource of the reconciled kind.
+func withSharding(
+	mgr ctrl.Manager,
+	sharding *configv2.ShardingConfig,
+	object client.Object,
+	reconciler reconcile.Reconciler,
+) reconcile.Reconciler {
+	if sharding == nil || sharding.Shards <= 1 {
+		return reconciler
+	}
+
+	return &shardedReconciler{
+		reader:     mgr.GetClient(),
+		reconciler: reconciler,
+		object:     object,
+		sharding:   sharding,
+	}
+}
+
+type shardedReconciler struct {
+	reader     client.Reader
+	reconciler reconcile.Reconciler
+	object     client.Object
+	sharding   *configv2.ShardingConfig
+}
+
+func (s *shardedReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	object, _ := s.object.DeepCopyObject().(client.Object)
+
+	// Missing resources are handled by the wrapped reconciler
+	err := s.reader.Get(ctx, req.NamespacedName, object)
+	if err == nil && !ownsShard(s.sharding, object) {
+		return ctrl.Result{}, nil
+	}
+
+	//nolint:wrapcheck // the wrapped reconciler errors are already wrapped
+	return s.reconciler.Reconcile(ctx, req)
+}
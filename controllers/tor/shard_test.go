@@ -0,0 +1,157 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tor
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	configv2 "github.com/bugfest/tor-controller/apis/config/v2"
+)
+
+func TestShardOf(t *testing.T) {
+	tests := []struct {
+		name      string
+		key       configv2.ShardKey
+		namespace string
+		objName   string
+		labels    map[string]string
+		want      int
+	}{
+		{
+			name:      "namespace key",
+			key:       configv2.ShardKeyNamespace,
+			namespace: "default",
+			objName:   "a",
+			want:      2,
+		},
+		{
+			name:      "namespace key ignores the name",
+			key:       configv2.ShardKeyNamespace,
+			namespace: "default",
+			objName:   "b",
+			want:      2,
+		},
+		{
+			name:      "name key",
+			key:       configv2.ShardKeyName,
+			namespace: "default",
+			objName:   "a",
+			want:      2,
+		},
+		{
+			name:      "name key spreads a namespace",
+			key:       configv2.ShardKeyName,
+			namespace: "default",
+			objName:   "b",
+			want:      3,
+		},
+		{
+			name:      "pinned label",
+			key:       configv2.ShardKeyNamespace,
+			namespace: "default",
+			objName:   "a",
+			labels:    map[string]string{shardLabel: "1"},
+			want:      1,
+		},
+		{
+			name:      "out of range label",
+			key:       configv2.ShardKeyNamespace,
+			namespace: "default",
+			objName:   "a",
+			labels:    map[string]string{shardLabel: "4"},
+			want:      2,
+		},
+		{
+			name:      "negative label",
+			key:       configv2.ShardKeyNamespace,
+			namespace: "default",
+			objName:   "a",
+			labels:    map[string]string{shardLabel: "-1"},
+			want:      2,
+		},
+		{
+			name:      "invalid label",
+			key:       configv2.ShardKeyNamespace,
+			namespace: "default",
+			objName:   "a",
+			labels:    map[string]string{shardLabel: "one"},
+			want:      2,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			sharding := &configv2.ShardingConfig{Shards: 4, Key: tt.key}
+			object := &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      tt.objName,
+					Namespace: tt.namespace,
+					Labels:    tt.labels,
+				},
+			}
+
+			if got := shardOf(sharding, object); got != tt.want {
+				t.Errorf("shardOf() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOwnsShard(t *testing.T) {
+	object := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "default"},
+	}
+
+	tests := []struct {
+		name     string
+		sharding *configv2.ShardingConfig
+		want     bool
+	}{
+		{
+			name: "sharding disabled",
+			want: true,
+		},
+		{
+			name:     "single shard",
+			sharding: &configv2.ShardingConfig{Shards: 1},
+			want:     true,
+		},
+		{
+			name:     "own shard",
+			sharding: &configv2.ShardingConfig{Shards: 4, Shard: 2},
+			want:     true,
+		},
+		{
+			name:     "other shard",
+			sharding: &configv2.ShardingConfig{Shards: 4, Shard: 1},
+			want:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ownsShard(tt.sharding, object); got != tt.want {
+				t.Errorf("ownsShard() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
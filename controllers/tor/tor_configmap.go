@@ -19,6 +19,8 @@ package tor
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"strings"
 	"text/template"
 
@@ -71,11 +73,21 @@ DataDirectory /var/lib/tor/data
 +SocksPolicy {{ StringsJoin .Tor.Spec.Client.Socks.Policy "," }}
 {{- end }}
 
-{{- if .Tor.Spec.Control.Enable }}
+{{- if or .Tor.ControlPortEnabled .Tor.Spec.Control.Socket.Enable }}
 # Control
+{{- if .Tor.ControlPortEnabled }}
 {{- range $idx, $addr := .Tor.Spec.Control.Address }}
 +ControlPort {{ $addr }}:{{ $.Tor.Spec.Control.Port }} {{ StringsJoin $.Tor.Spec.Control.Flags "," }}
 {{- end }}
+{{- end }}
+{{- if .Tor.Spec.Control.Socket.Enable }}
++ControlSocket unix:{{ .ControlDir }}/control.sock GroupWritable RelaxDirModeCheck
+{{- end }}
+{{- if .Tor.Spec.Control.CookieAuthentication }}
+CookieAuthentication 1
+CookieAuthFile {{ .ControlDir }}/control_auth_cookie
+CookieAuthFileGroupReadable 1
+{{- end }}
 {{- range .ControlHashedPasswords }}
 +HashedControlPassword {{ . }}
 {{- end }}
@@ -103,6 +115,7 @@ DataDirectory /var/lib/tor/data
 type torConfig struct {
 	Tor                    *torv1alpha2.Tor
 	ControlHashedPasswords []string
+	ControlDir             string
 }
 
 func (r *Reconciler) reconcileConfigMap(ctx context.Context, tor *torv1alpha2.Tor) error {
@@ -120,10 +133,20 @@ func (r *Reconciler) reconcileConfigMap(ctx context.Context, tor *torv1alpha2.To
 		return nil
 	}
 
+	torfile, err := r.renderTorConfig(ctx, tor)
+	if err != nil {
+		return err
+	}
+
+	configHash, err := r.torConfigHash(ctx, tor)
+	if err != nil {
+		return err
+	}
+
 	var configmap corev1.ConfigMap
-	err := r.Get(ctx, types.NamespacedName{Name: configMapName, Namespace: namespace}, &configmap)
+	err = r.Get(ctx, types.NamespacedName{Name: configMapName, Namespace: namespace}, &configmap)
 
-	newConfigMap := torConfigMap(tor)
+	newConfigMap := torConfigMap(tor, torfile, configHash)
 	if apierrors.IsNotFound(err) {
 		err := r.Create(ctx, newConfigMap)
 		if err != nil {
@@ -137,6 +160,7 @@ func (r *Reconciler) reconcileConfigMap(ctx context.Context, tor *torv1alpha2.To
 
 	if !metav1.IsControlledBy(&configmap.ObjectMeta, tor) {
 		// TODO: generate MessageResourceExists event
+		recordNotControlled("ConfigMap", &configmap, tor)
 		logger.Info("ConfigMap already exists and is not controlled by",
 			"configmap", configmap.Name,
 			"controller", tor.Name)
@@ -144,13 +168,65 @@ func (r *Reconciler) reconcileConfigMap(ctx context.Context, tor *torv1alpha2.To
 		return nil
 	}
 
+	// The config hash covers everything rendered into the torfile, so we
+	// only need to update the ConfigMap when it changes
+	if configmap.Annotations[torConfigHashAnnotation] != configHash {
+		logger.Info("Updating ConfigMap", "configmap", configmap.Name)
+
+		err := r.Update(ctx, newConfigMap)
+		if err != nil {
+			return errors.Wrapf(err, "failed to update configmap %s/%s", namespace, configMapName)
+		}
+	}
+
 	return nil
 }
 
-func torConfigFile(tor *torv1alpha2.Tor) string {
+// torConfigHash returns a digest of the tor configuration: the rendered
+// torfile (control passwords included) and the content of every ConfigMapKeyRef.
+// It is stamped on the ConfigMap and on the pod template so any change
+// triggers a rollout (or a reload, see ConfigReloadStrategy).
+func (r *Reconciler) torConfigHash(ctx context.Context, tor *torv1alpha2.Tor) (string, error) {
+	torfile, err := r.renderTorConfig(ctx, tor)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(torfile))
+
+	for _, configMapKeyRef := range tor.Spec.ConfigMapKeyRef {
+		var configmap corev1.ConfigMap
+
+		err := r.Get(ctx, types.NamespacedName{Name: configMapKeyRef.Name, Namespace: tor.Namespace}, &configmap)
+		if apierrors.IsNotFound(err) {
+			// The pod won't start without it; we will be notified once it's created
+			continue
+		} else if err != nil {
+			return "", errors.Wrapf(err, "failed to get configmap %s/%s", tor.Namespace, configMapKeyRef.Name)
+		}
+
+		hash.Write([]byte(configMapKeyRef.Name + "/" + configMapKeyRef.Key + "\n"))
+		hash.Write([]byte(configmap.Data[configMapKeyRef.Key]))
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func (r *Reconciler) renderTorConfig(ctx context.Context, tor *torv1alpha2.Tor) (string, error) {
+	hashedPasswords, err := r.getTorControlHashedPasswords(ctx, tor)
+	if err != nil {
+		return "", err
+	}
+
+	return torConfigFile(tor, hashedPasswords), nil
+}
+
+func torConfigFile(tor *torv1alpha2.Tor, hashedPasswords []string) string {
 	config := torConfig{
 		Tor:                    tor,
-		ControlHashedPasswords: getTorControlHashedPasswords(tor),
+		ControlHashedPasswords: hashedPasswords,
+		ControlDir:             torv1alpha2.TorControlDir,
 	}
 
 	configTemplate := template.Must(
@@ -169,11 +245,14 @@ func torConfigFile(tor *torv1alpha2.Tor) string {
 	return tmp.String()
 }
 
-func torConfigMap(tor *torv1alpha2.Tor) *corev1.ConfigMap {
+func torConfigMap(tor *torv1alpha2.Tor, torfile, configHash string) *corev1.ConfigMap {
 	return &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      tor.ConfigMapName(),
 			Namespace: tor.Namespace,
+			Annotations: map[string]string{
+				torConfigHashAnnotation: configHash,
+			},
 			OwnerReferences: []metav1.OwnerReference{
 				*metav1.NewControllerRef(tor, schema.GroupVersionKind{
 					Group:   torv1alpha2.GroupVersion.Group,
@@ -183,20 +262,7 @@ func torConfigMap(tor *torv1alpha2.Tor) *corev1.ConfigMap {
 			},
 		},
 		Data: map[string]string{
-			"torfile": torConfigFile(tor),
+			"torfile": torfile,
 		},
 	}
 }
-
-func getTorControlHashedPasswords(tor *torv1alpha2.Tor) []string {
-	hashes := []string{}
-
-	for _, secret := range tor.Spec.Control.Secret {
-		hash, err := doHashPassword(secret)
-		if err == nil {
-			hashes = append(hashes, hash)
-		}
-	}
-
-	return hashes
-}
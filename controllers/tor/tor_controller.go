@@ -22,9 +22,14 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	k8slog "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	corev1 "k8s.io/api/core/v1"
 
@@ -43,6 +48,8 @@ type Reconciler struct {
 //+kubebuilder:rbac:groups=tor.k8s.torproject.org,resources=tors,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=tor.k8s.torproject.org,resources=tors/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=tor.k8s.torproject.org,resources=tors/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -120,6 +127,16 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 		return ctrl.Result{}, err
 	}
 
+	err = r.reconcilePodMonitor(ctx, &tor)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	err = r.reconcileNetworkPolicy(ctx, &tor)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
 	// Finally, we update the status block of the Tor resource to reflect the
 	// current state of the world
 	torCopy := tor.DeepCopy()
@@ -133,6 +150,7 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 	}
 
 	torCopy.Status.Config = "updateme"
+	setControlSecretCondition(torCopy)
 
 	if err := r.Status().Update(ctx, torCopy); err != nil {
 		logger.Error(err, "unable to update Tor status")
@@ -140,6 +158,11 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 		return ctrl.Result{}, errors.Wrap(err, "unable to update Tor status")
 	}
 
+	// Come back when the generated control password must be rotated
+	if requeueAfter := r.controlRotationRequeueAfter(ctx, &tor); requeueAfter > 0 {
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
+	}
+
 	return ctrl.Result{}, nil
 }
 
@@ -147,13 +170,81 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
 	pred := predicate.GenerationChangedPredicate{}
 
-	err := ctrl.NewControllerManagedBy(mgr).
-		For(&torv1alpha2.Tor{}).
-		WithEventFilter(pred).
-		Complete(r)
+	// ConfigMaps and Secrets referenced by a Tor resource are part of its
+	// configuration: changes in them must trigger a reconcile too
+	bldr := ctrl.NewControllerManagedBy(mgr).
+		For(&torv1alpha2.Tor{}, builder.WithPredicates(pred)).
+		Watches(
+			&source.Kind{Type: &corev1.ConfigMap{}},
+			handler.EnqueueRequestsFromMapFunc(r.findTorsForConfigMap),
+		).
+		Watches(
+			&source.Kind{Type: &corev1.Secret{}},
+			handler.EnqueueRequestsFromMapFunc(r.findTorsForSecret),
+		).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.ProjectConfig.MaxConcurrentReconciles.Tor})
+
+	err := watchPolicies(mgr, bldr, &torv1alpha2.TorList{}).
+		Complete(withSharding(mgr, r.ProjectConfig.Sharding, &torv1alpha2.Tor{}, r))
 	if err != nil {
 		return errors.Wrap(err, "unable to create controller")
 	}
 
 	return nil
 }
+
+// findTorsForConfigMap maps a ConfigMap to the Tor resources referencing it.
+func (r *Reconciler) findTorsForConfigMap(object client.Object) []reconcile.Request {
+	return r.findTors(object.GetNamespace(), func(tor *torv1alpha2.Tor) bool {
+		for _, configMapKeyRef := range tor.Spec.ConfigMapKeyRef {
+			if configMapKeyRef.Name == object.GetName() {
+				return true
+			}
+		}
+
+		return false
+	})
+}
+
+// findTorsForSecret maps a Secret to the Tor resources using it as control password.
+func (r *Reconciler) findTorsForSecret(object client.Object) []reconcile.Request {
+	return r.findTors(object.GetNamespace(), func(tor *torv1alpha2.Tor) bool {
+		if tor.SecretName() == object.GetName() {
+			return true
+		}
+
+		for _, secretRef := range tor.Spec.Control.SecretRef {
+			if secretRef.Name == object.GetName() {
+				return true
+			}
+		}
+
+		return false
+	})
+}
+
+func (r *Reconciler) findTors(namespace string, match func(*torv1alpha2.Tor) bool) []reconcile.Request {
+	var torList torv1alpha2.TorList
+
+	err := r.List(context.Background(), &torList, client.InNamespace(namespace))
+	if err != nil {
+		k8slog.Log.Error(err, "unable to list Tors", "namespace", namespace)
+
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+
+	for i := range torList.Items {
+		if match(&torList.Items[i]) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      torList.Items[i].Name,
+					Namespace: torList.Items[i].Namespace,
+				},
+			})
+		}
+	}
+
+	return requests
+}
@@ -49,12 +49,24 @@ func (r *Reconciler) reconcileDeployment(ctx context.Context, tor *torv1alpha2.T
 		return nil
 	}
 
-	var deployment appsv1.Deployment
-	err := r.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: namespace}, &deployment)
+	configHash, err := r.torConfigHash(ctx, tor)
+	if err != nil {
+		return err
+	}
+
+	projectConfig, err := projectConfigFor(ctx, r, &r.ProjectConfig, namespace)
+	if err != nil {
+		return err
+	}
 
 	// If the deployment doesn't exist, we'll create it
-	projectConfig := r.ProjectConfig
-	newDeployment := torDeployment(tor, &projectConfig)
+	newDeployment, err := torDeployment(tor, projectConfig, configHash)
+	if err != nil {
+		return errors.Wrapf(err, "failed to build Deployment %s/%s", namespace, deploymentName)
+	}
+
+	var deployment appsv1.Deployment
+	err = r.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: namespace}, &deployment)
 
 	if apierrors.IsNotFound(err) {
 		err := r.Create(ctx, newDeployment)
@@ -73,6 +85,7 @@ func (r *Reconciler) reconcileDeployment(ctx context.Context, tor *torv1alpha2.T
 	// If the Deployment is not controlled by this Foo resource, we should log
 	// a warning to the event recorder and ret
 	if !metav1.IsControlledBy(&deployment.ObjectMeta, tor) {
+		recordNotControlled("Deployment", &deployment, tor)
 		logger.Info("Deployment already exists and not controlled by - skipping update",
 			"deployment", deployment.Name,
 			"controller", tor.Name)
@@ -91,7 +104,21 @@ func (r *Reconciler) reconcileDeployment(ctx context.Context, tor *torv1alpha2.T
 	return nil
 }
 
-func torDeployment(tor *torv1alpha2.Tor, projectConfig *configv2.ProjectConfig) *appsv1.Deployment {
+// torReloaderScript polls tor's config files and sends SIGHUP to tor when
+// their content changes.
+const torReloaderScript = `last=""
+while true; do
+  current=$(cat /run/tor/torfile /config/*/*.conf 2>/dev/null | md5sum)
+  if [ -n "$last" ] && [ "$current" != "$last" ]; then
+    echo "config changed, reloading tor"
+    pkill -HUP -x tor
+  fi
+  last="$current"
+  sleep 10
+done
+`
+
+func torDeployment(tor *torv1alpha2.Tor, projectConfig *configv2.ProjectConfig, configHash string) (*appsv1.Deployment, error) {
 	// new deployment
 	if tor.Spec.Replicas == 0 {
 		tor.Spec.Replicas = 1
@@ -114,6 +141,10 @@ func torDeployment(tor *torv1alpha2.Tor, projectConfig *configv2.ProjectConfig)
 			Name:      torDataVolume,
 			MountPath: torDataMountDir,
 		},
+		{
+			Name:      tmpVolume,
+			MountPath: "/tmp",
+		},
 	}
 
 	torArgs := append(
@@ -136,18 +167,9 @@ func torDeployment(tor *torv1alpha2.Tor, projectConfig *configv2.ProjectConfig)
 				},
 			},
 		},
-		{
-			Name: torServiceVolume,
-			VolumeSource: corev1.VolumeSource{
-				EmptyDir: &corev1.EmptyDirVolumeSource{},
-			},
-		},
-		{
-			Name: torDataVolume,
-			VolumeSource: corev1.VolumeSource{
-				EmptyDir: &corev1.EmptyDirVolumeSource{},
-			},
-		},
+		emptyDirVolume(torServiceVolume),
+		emptyDirVolume(torDataVolume),
+		emptyDirVolume(tmpVolume),
 	}
 
 	for i, ConfigMapKeyRef := range tor.Spec.ConfigMapKeyRef {
@@ -175,32 +197,94 @@ func torDeployment(tor *torv1alpha2.Tor, projectConfig *configv2.ProjectConfig)
 		})
 	}
 
-	// Fetch Pod Template
-	podTemplate := tor.PodTemplate()
+	var controlVolumeMount *corev1.VolumeMount
 
-	// Add Labels to template
-	if podTemplate.ObjectMeta.Labels == nil {
-		// Set deployment labels
-		podTemplate.ObjectMeta.Labels = tor.DeploymentLabels()
-	} else {
-		// Add tor labels to existing template labels
-		for k, v := range tor.DeploymentLabels() {
-			// TODO: should we throw an error if a label was already set?
-			podTemplate.ObjectMeta.Labels[k] = v
+	if tor.ControlVolumeEnabled() {
+		controlVolumeMount = &corev1.VolumeMount{
+			Name:      torControlVolume,
+			MountPath: torv1alpha2.TorControlDir,
 		}
+
+		volumes = append(volumes, emptyDirVolume(torControlVolume))
+
+		torVolumeMounts = append(torVolumeMounts, *controlVolumeMount)
+	}
+
+	generated := corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: tor.DeploymentLabels(),
+		},
+		Spec: corev1.PodSpec{
+			Volumes: volumes,
+			Containers: []corev1.Container{
+				{
+					Name:            "tor",
+					Image:           projectConfig.TorDaemon.ImageRef(),
+					Args:            torArgs,
+					ImagePullPolicy: projectConfig.TorDaemon.PullPolicy(),
+					SecurityContext: componentSecurityContext(&projectConfig.TorDaemon.ComponentDefaults),
+					VolumeMounts:    torVolumeMounts,
+					Ports:           getTorContainerPortList(tor),
+					Resources:       containerResources(tor.Resources(), &projectConfig.TorDaemon.ComponentDefaults),
+				},
+			},
+		},
+	}
+
+	applyComponentDefaults(&generated.Spec, &projectConfig.TorDaemon.ComponentDefaults)
+
+	if tor.Spec.ConfigReloadStrategy == torv1alpha2.ConfigReloadReload {
+		// Mounted ConfigMaps are refreshed in place by the kubelet; the
+		// reloader sidecar sends SIGHUP to tor whenever their content changes
+		shareProcessNamespace := true
+		generated.Spec.ShareProcessNamespace = &shareProcessNamespace
+		generated.Spec.Containers = append(generated.Spec.Containers, corev1.Container{
+			Name:            "tor-reloader",
+			Image:           projectConfig.TorDaemon.ImageRef(),
+			Command:         []string{"/bin/sh", "-c", torReloaderScript},
+			ImagePullPolicy: projectConfig.TorDaemon.PullPolicy(),
+			SecurityContext: componentSecurityContext(&projectConfig.TorDaemon.ComponentDefaults),
+			VolumeMounts:    torVolumeMounts,
+		})
+	}
+
+	// Merge the user's Pod Template, which can tweak the generated containers
+	userTemplate := tor.PodTemplate()
+
+	podTemplate, err := mergePodTemplate(&generated, &userTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	if controlVolumeMount != nil {
+		// The control socket and cookie are shared with the sidecar containers
+		// defined in the template, so they can use the control port without
+		// exposing it on the network
+		for i := range podTemplate.Spec.Containers {
+			if !hasVolumeMount(&podTemplate.Spec.Containers[i], torControlVolume) {
+				podTemplate.Spec.Containers[i].VolumeMounts = append(podTemplate.Spec.Containers[i].VolumeMounts, *controlVolumeMount)
+			}
+		}
+	}
+
+	// The deployment labels back the selector, so they win over the
+	// template ones
+	for k, v := range tor.DeploymentLabels() {
+		podTemplate.ObjectMeta.Labels[k] = v
 	}
 
 	podTemplate.Spec.ServiceAccountName = tor.ServiceAccountName()
-	podTemplate.Spec.Volumes = append(podTemplate.Spec.Volumes, volumes...)
-	podTemplate.Spec.Containers = append(podTemplate.Spec.Containers, corev1.Container{
-		Name:            "tor",
-		Image:           projectConfig.TorDaemon.Image,
-		Args:            torArgs,
-		ImagePullPolicy: corev1.PullAlways,
-		VolumeMounts:    torVolumeMounts,
-		Ports:           getTorContainerPortList(tor),
-		Resources:       tor.Resources(),
-	})
+	// Plain tor doesn't talk to the API server
+	applyPodSecurityDefaults(&podTemplate.Spec, false)
+
+	if tor.Spec.ConfigReloadStrategy != torv1alpha2.ConfigReloadReload {
+		// Any change in the config hash rolls the deployment out
+		if podTemplate.ObjectMeta.Annotations == nil {
+			podTemplate.ObjectMeta.Annotations = map[string]string{}
+		}
+
+		podTemplate.ObjectMeta.Annotations[torConfigHashAnnotation] = configHash
+	}
 
 	return &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
@@ -221,7 +305,7 @@ func torDeployment(tor *torv1alpha2.Tor, projectConfig *configv2.ProjectConfig)
 			Replicas: &tor.Spec.Replicas,
 			Template: podTemplate,
 		},
-	}
+	}, nil
 }
 
 func getTorContainerPortList(tor *torv1alpha2.Tor) []corev1.ContainerPort {
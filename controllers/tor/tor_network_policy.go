@@ -0,0 +1,146 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tor
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	k8slog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/cockroachdb/errors"
+
+	torv1alpha2 "github.com/bugfest/tor-controller/apis/tor/v1alpha2"
+)
+
+func (r *Reconciler) reconcileNetworkPolicy(ctx context.Context, tor *torv1alpha2.Tor) error {
+	logger := k8slog.FromContext(ctx)
+
+	policyName := tor.NetworkPolicyName()
+	namespace := tor.Namespace
+
+	var policy networkingv1.NetworkPolicy
+	err := r.Get(ctx, types.NamespacedName{Name: policyName, Namespace: namespace}, &policy)
+
+	if apierrors.IsNotFound(err) {
+		if !tor.Spec.NetworkPolicy.Enable {
+			// NetworkPolicy is not requested, skipping
+			return nil
+		}
+	} else if err != nil {
+		return errors.Wrapf(err, "failed to get NetworkPolicy %s", policyName)
+	}
+
+	if err == nil && !metav1.IsControlledBy(&policy.ObjectMeta, tor) {
+		recordNotControlled("NetworkPolicy", &policy, tor)
+		logger.Info("NetworkPolicy already exists and is not controlled by",
+			"NetworkPolicy", policy.Name,
+			"controller", tor.Name)
+
+		return nil
+	}
+
+	if !tor.Spec.NetworkPolicy.Enable {
+		// NetworkPolicy is not requested but exists, deleting
+		err = r.Delete(ctx, &policy)
+		if err != nil {
+			return errors.Wrapf(err, "failed to delete NetworkPolicy %s", policyName)
+		}
+
+		return nil
+	}
+
+	newPolicy := torTorNetworkPolicy(tor)
+	if apierrors.IsNotFound(err) {
+		err := r.Create(ctx, newPolicy)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create NetworkPolicy %s", policyName)
+		}
+
+		return nil
+	}
+
+	// If the policy specs don't match, update
+	if !networkPolicyEqual(&policy, newPolicy) {
+		err := r.Update(ctx, newPolicy)
+		if err != nil {
+			return errors.Wrapf(err, "failed to update NetworkPolicy %s", policyName)
+		}
+	}
+
+	return nil
+}
+
+// torTorNetworkPolicy lets the tor pods reach the tor network and be
+// scraped. Client ports accept connections from the same namespace, the relay
+// port from anywhere.
+func torTorNetworkPolicy(tor *torv1alpha2.Tor) *networkingv1.NetworkPolicy {
+	ingress := metricsIngress(&tor.Spec.NetworkPolicy, int(tor.Spec.Metrics.Port))
+
+	clients := networkingv1.NetworkPolicyIngressRule{
+		From: []networkingv1.NetworkPolicyPeer{
+			{PodSelector: &metav1.LabelSelector{}},
+		},
+	}
+
+	for _, port := range tor.GetAllPorts() {
+		if !port.Port.Enable {
+			continue
+		}
+
+		policyPort := networkPolicyPort(corev1.Protocol(port.Protocol), intstr.FromInt(int(port.Port.Port)))
+
+		switch port.Name {
+		case "metrics":
+			// Only from the metrics namespaces
+		case "server":
+			ingress = append(ingress, networkingv1.NetworkPolicyIngressRule{
+				Ports: []networkingv1.NetworkPolicyPort{policyPort},
+			})
+		default:
+			clients.Ports = append(clients.Ports, policyPort)
+		}
+	}
+
+	if len(clients.Ports) > 0 {
+		ingress = append(ingress, clients)
+	}
+
+	return torNetworkPolicy(
+		metav1.ObjectMeta{
+			Name:      tor.NetworkPolicyName(),
+			Namespace: tor.Namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(tor, schema.GroupVersionKind{
+					Group:   torv1alpha2.GroupVersion.Group,
+					Version: torv1alpha2.GroupVersion.Version,
+					Kind:    "Tor",
+				}),
+			},
+		},
+		tor.DeploymentLabels(),
+		&tor.Spec.NetworkPolicy,
+		ingress,
+		internetEgress(),
+	)
+}
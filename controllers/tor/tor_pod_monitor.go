@@ -0,0 +1,124 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tor
+
+import (
+	"context"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	k8slog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/cockroachdb/errors"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+
+	torv1alpha2 "github.com/bugfest/tor-controller/apis/tor/v1alpha2"
+)
+
+func (r *Reconciler) reconcilePodMonitor(ctx context.Context, tor *torv1alpha2.Tor) error {
+	logger := k8slog.FromContext(ctx)
+
+	if !podMonitoringInstalled(ctx, r) {
+		// PodMonitor cannot be created; monitoring CRDs are not installed
+		return nil
+	}
+
+	monitorName := tor.ServiceMetricsName()
+	namespace := tor.Namespace
+
+	var monitor monitoringv1.PodMonitor
+	err := r.Get(ctx, types.NamespacedName{Name: monitorName, Namespace: namespace}, &monitor)
+
+	newMonitor := torPodMonitor(tor)
+
+	if apierrors.IsNotFound(err) {
+		if !tor.Monitoring().PodMonitorEnabled() {
+			// PodMonitor is not requested, skipping
+			return nil
+		}
+
+		err := r.Create(ctx, newMonitor)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create PodMonitor %s", monitorName)
+		}
+
+		monitor = *newMonitor
+	} else if err != nil {
+		return errors.Wrapf(err, "failed to get PodMonitor %s", monitorName)
+	}
+
+	if !metav1.IsControlledBy(&monitor.ObjectMeta, tor) {
+		recordNotControlled("PodMonitor", &monitor, tor)
+		logger.Info("PodMonitor already exists and is not controlled by",
+			"PodMonitor", monitor.Name,
+			"controller", tor.Name)
+
+		return nil
+	}
+
+	if !tor.Monitoring().PodMonitorEnabled() {
+		// PodMonitor is not requested but exists, deleting
+		err = r.Delete(ctx, &monitor)
+		if err != nil {
+			return errors.Wrapf(err, "failed to delete PodMonitor %s", monitorName)
+		}
+
+		return nil
+	}
+
+	// If the monitor specs don't match, update
+	if !podMonitorEqual(&monitor, newMonitor) {
+		err := r.Update(ctx, newMonitor)
+		if err != nil {
+			return errors.Wrapf(err, "failed to update PodMonitor %s", monitorName)
+		}
+	}
+
+	return nil
+}
+
+// torPodMonitor scrapes every tor pod directly, instead of going through the
+// metrics Service.
+func torPodMonitor(onion *torv1alpha2.Tor) *monitoringv1.PodMonitor {
+	return &monitoringv1.PodMonitor{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      onion.ServiceMetricsName(),
+			Namespace: onion.Namespace,
+			Labels:    monitorLabels(onion.ServiceMetricsSelector(), onion.Monitoring()),
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(onion, schema.GroupVersionKind{
+					Group:   torv1alpha2.GroupVersion.Group,
+					Version: torv1alpha2.GroupVersion.Version,
+					Kind:    "Tor",
+				}),
+			},
+		},
+		Spec: monitoringv1.PodMonitorSpec{
+			Selector: metav1.LabelSelector{
+				MatchLabels: onion.DeploymentLabels(),
+			},
+			PodMetricsEndpoints: podMetricsEndpoints(onion.Monitoring(), "metrics"),
+			NamespaceSelector: monitoringv1.NamespaceSelector{
+				MatchNames: []string{
+					onion.Namespace,
+				},
+			},
+		},
+	}
+}
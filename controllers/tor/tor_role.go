@@ -62,6 +62,7 @@ func (r *Reconciler) reconcileRole(ctx context.Context, tor *torv1alpha2.Tor) er
 	}
 
 	if !metav1.IsControlledBy(&role.ObjectMeta, tor) {
+		recordNotControlled("Role", &role, tor)
 		logger.Info("Role already exists and is not controlled by",
 			"role", role.Name,
 			"controller", tor.Name)
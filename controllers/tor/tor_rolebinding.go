@@ -62,6 +62,7 @@ func (r *Reconciler) reconcileRolebinding(ctx context.Context, tor *torv1alpha2.
 	}
 
 	if !metav1.IsControlledBy(&roleBinding.ObjectMeta, tor) {
+		recordNotControlled("RoleBinding", &roleBinding, tor)
 		logger.Info("RoleBinding already exists and is not controlled by",
 			"roleBinding", roleBinding.Name,
 			"controller", tor.Name)
@@ -18,9 +18,13 @@ package tor
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
@@ -34,6 +38,11 @@ import (
 
 const (
 	passwordLength = 16
+
+	controlPasswordKey = "control"
+	controlSaltKey     = "salt"
+
+	controlRotatedAtAnnotation = "tor.k8s.torproject.org/control-rotated-at"
 )
 
 func (r *Reconciler) reconcileControlSecret(ctx context.Context, tor *torv1alpha2.Tor) error {
@@ -51,44 +60,30 @@ func (r *Reconciler) reconcileControlSecret(ctx context.Context, tor *torv1alpha
 		return nil
 	}
 
+	if len(tor.Spec.Control.Secret) > 0 {
+		logger.Info("spec.control.secret is deprecated and ignored, use spec.control.secretRef instead",
+			"tor", tor.Name)
+	}
+
 	var secret corev1.Secret
 	err := r.Get(ctx, types.NamespacedName{Name: secretName, Namespace: namespace}, &secret)
 
-	password := generateRandomPassword()
-	newSecret := torSecret(tor, password)
-
 	if apierrors.IsNotFound(err) {
-		err := r.Create(ctx, newSecret)
+		salt, err := generateSalt()
 		if err != nil {
-			return errors.Wrapf(err, "failed to create secret %#v", newSecret)
+			return err
 		}
 
-		secret = *newSecret
-	} else if err != nil {
-		return errors.Wrapf(err, "failed to get secret %s", secretName)
-	}
+		newSecret := torSecret(tor, generateRandomPassword(), salt)
 
-	var tmpSecret corev1.Secret
-	for _, secretRef := range tor.Spec.Control.SecretRef {
-		err := r.Get(ctx, types.NamespacedName{Name: secretRef.Name, Namespace: namespace}, &tmpSecret)
+		err = r.Create(ctx, newSecret)
 		if err != nil {
-			return errors.Wrapf(err, "failed to get secret %s", secretRef.Name)
+			return errors.Wrapf(err, "failed to create secret %s/%s", namespace, secretName)
 		}
 
-		for k := range tmpSecret.Data {
-			if k == secretRef.Key {
-				// Adds all the referenced secrets to Tor.Spec.Control.Secret for later use
-				tor.Spec.Control.Secret = append(tor.Spec.Control.Secret, string(tmpSecret.Data[k]))
-			}
-		}
-	}
-
-	if len(tor.Spec.Control.Secret) == 0 && len(tor.Spec.Control.SecretRef) == 0 {
-		// If the user did not define any password in the Control spec,
-		// update Control Secrets with the generated one
-		for _, s := range secret.Data {
-			tor.Spec.Control.Secret = append(tor.Spec.Control.Secret, string(s))
-		}
+		secret = *newSecret
+	} else if err != nil {
+		return errors.Wrapf(err, "failed to get secret %s", secretName)
 	}
 
 	if !metav1.IsControlledBy(&secret.ObjectMeta, tor) {
@@ -97,6 +92,7 @@ func (r *Reconciler) reconcileControlSecret(ctx context.Context, tor *torv1alpha
 		// msg := fmt.Sprintf(MessageResourceExists, service.Name)
 		// bc.recorder.Event(OnionBalancedService, corev1.EventTypeWarning, ErrResourceExists, msg)
 		// return errors.New(msg)
+		recordNotControlled("Secret", &secret, tor)
 		logger.Info("Secret already exists and is not controlled by",
 			"secret", secret.Name,
 			"controller", tor.Name)
@@ -104,10 +100,140 @@ func (r *Reconciler) reconcileControlSecret(ctx context.Context, tor *torv1alpha
 		return nil
 	}
 
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+
+	update := false
+
+	// Secrets created by older versions of the controller have no salt
+	if len(secret.Data[controlSaltKey]) == 0 {
+		salt, err := generateSalt()
+		if err != nil {
+			return err
+		}
+
+		secret.Data[controlSaltKey] = []byte(salt)
+		update = true
+	}
+
+	if controlRotationAfter(tor, &secret, time.Now()) == 0 {
+		logger.Info("Rotating control password", "secret", secret.Name)
+
+		if secret.Annotations == nil {
+			secret.Annotations = map[string]string{}
+		}
+
+		secret.Data[controlPasswordKey] = []byte(generateRandomPassword())
+		secret.Annotations[controlRotatedAtAnnotation] = time.Now().UTC().Format(time.RFC3339)
+		update = true
+	}
+
+	if update {
+		err := r.Update(ctx, &secret)
+		if err != nil {
+			return errors.Wrapf(err, "failed to update secret %s/%s", namespace, secretName)
+		}
+	}
+
 	return nil
 }
 
-func torSecret(tor *torv1alpha2.Tor, password string) *corev1.Secret {
+// controlRotationRequeueAfter returns when the Tor resource must be
+// reconciled again to rotate its generated control password, or 0 if it
+// doesn't need to.
+func (r *Reconciler) controlRotationRequeueAfter(ctx context.Context, tor *torv1alpha2.Tor) time.Duration {
+	var secret corev1.Secret
+
+	err := r.Get(ctx, types.NamespacedName{Name: tor.SecretName(), Namespace: tor.Namespace}, &secret)
+	if err != nil || !metav1.IsControlledBy(&secret.ObjectMeta, tor) {
+		return 0
+	}
+
+	return controlRotationAfter(tor, &secret, time.Now())
+}
+
+// controlRotationAfter returns the time left until the generated control
+// password must be rotated. It returns 0 when it's due, and -1 when rotation
+// doesn't apply.
+func controlRotationAfter(tor *torv1alpha2.Tor, secret *corev1.Secret, now time.Time) time.Duration {
+	rotation := tor.Spec.Control.Rotation
+
+	// Only the generated password is rotated
+	if !rotation.Enable || rotation.Interval.Duration <= 0 || len(tor.Spec.Control.SecretRef) > 0 {
+		return -1
+	}
+
+	rotatedAt := secret.CreationTimestamp.Time
+	if t, err := time.Parse(time.RFC3339, secret.Annotations[controlRotatedAtAnnotation]); err == nil {
+		rotatedAt = t
+	}
+
+	left := rotatedAt.Add(rotation.Interval.Duration).Sub(now)
+	if left < 0 {
+		return 0
+	}
+
+	return left
+}
+
+// getTorControlHashedPasswords returns the HashedControlPassword values for
+// all the control passwords of the Tor resource. Hashes are salted with the
+// salt stored in the generated secret, so they are stable across reconciles.
+func (r *Reconciler) getTorControlHashedPasswords(ctx context.Context, tor *torv1alpha2.Tor) ([]string, error) {
+	var secret corev1.Secret
+
+	err := r.Get(ctx, types.NamespacedName{Name: tor.SecretName(), Namespace: tor.Namespace}, &secret)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, errors.Wrapf(err, "failed to get secret %s", tor.SecretName())
+	}
+
+	salt, err := hex.DecodeString(string(secret.Data[controlSaltKey]))
+	if err != nil || len(salt) == 0 {
+		// The secret is not managed by us; fall back to a salt derived from
+		// the resource UID, which is stable for its whole lifetime
+		uidHash := sha256.Sum256([]byte(tor.UID))
+		salt = uidHash[:controlSaltLength]
+	}
+
+	passwords := []string{}
+
+	for _, secretRef := range tor.Spec.Control.SecretRef {
+		var refSecret corev1.Secret
+
+		err := r.Get(ctx, types.NamespacedName{Name: secretRef.Name, Namespace: tor.Namespace}, &refSecret)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get secret %s", secretRef.Name)
+		}
+
+		if password, ok := refSecret.Data[secretRef.Key]; ok {
+			passwords = append(passwords, string(password))
+		}
+	}
+
+	if len(passwords) == 0 {
+		// If the user did not define any password in the Control spec,
+		// use the generated one
+		if password, ok := secret.Data[controlPasswordKey]; ok {
+			passwords = append(passwords, string(password))
+		}
+	}
+
+	hashes := []string{}
+
+	for _, password := range passwords {
+		hash, err := doHashPassword(password, salt)
+		if err != nil {
+			return nil, err
+		}
+
+		hashes = append(hashes, hash)
+	}
+
+	return hashes, nil
+}
+
+func torSecret(tor *torv1alpha2.Tor, password, salt string) *corev1.Secret {
 	return &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      tor.SecretName(),
@@ -122,7 +248,8 @@ func torSecret(tor *torv1alpha2.Tor, password string) *corev1.Secret {
 		},
 		Type: "tor.k8s.torproject.org/control-password",
 		Data: map[string][]byte{
-			"control": []byte(password),
+			controlPasswordKey: []byte(password),
+			controlSaltKey:     []byte(salt),
 		},
 	}
 }
@@ -143,3 +270,21 @@ func generateRandomPassword() string {
 
 	return *pwd
 }
+
+// setControlSecretCondition reports a plaintext spec.control.secret, which is
+// ignored in favour of spec.control.secretRef or a generated password.
+func setControlSecretCondition(tor *torv1alpha2.Tor) {
+	if len(tor.Spec.Control.Secret) == 0 {
+		meta.RemoveStatusCondition(&tor.Status.Conditions, torv1alpha2.TorControlSecretIgnored)
+
+		return
+	}
+
+	meta.SetStatusCondition(&tor.Status.Conditions, metav1.Condition{
+		Type:               torv1alpha2.TorControlSecretIgnored,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: tor.Generation,
+		Reason:             "Deprecated",
+		Message:            "spec.control.secret is ignored, use spec.control.secretRef instead",
+	})
+}
@@ -69,6 +69,7 @@ func (r *Reconciler) reconcileService(ctx context.Context, tor *torv1alpha2.Tor)
 	}
 
 	if !metav1.IsControlledBy(&service.ObjectMeta, tor) {
+		recordNotControlled("Service", &service, tor)
 		logger.Info("service already exists and is not controlled by",
 			"service", service.Name,
 			"controller", tor.Name)
@@ -70,6 +70,7 @@ func (r *Reconciler) reconcileMetricsService(ctx context.Context, tor *torv1alph
 	}
 
 	if !metav1.IsControlledBy(&service.ObjectMeta, tor) {
+		recordNotControlled("Service", &service, tor)
 		logger.Info("Service already exists and is not controlled by",
 			"service", service.Name,
 			"controller", tor.Name)
@@ -58,7 +58,7 @@ func (r *Reconciler) reconcileServiceMonitor(ctx context.Context, tor *torv1alph
 	newService := torServiceMonitor(tor)
 
 	if apierrors.IsNotFound(err) {
-		if !tor.Spec.ServiceMonitor {
+		if !tor.Monitoring().ServiceMonitorEnabled() {
 			// ServiceMonitor is not requested, skipping
 			return nil
 		}
@@ -74,6 +74,7 @@ func (r *Reconciler) reconcileServiceMonitor(ctx context.Context, tor *torv1alph
 	}
 
 	if !metav1.IsControlledBy(&service.ObjectMeta, tor) {
+		recordNotControlled("ServiceMonitor", &service, tor)
 		logger.Info("ServiceMonitor already exists and is not controlled by",
 			"service", service.Name,
 			"controller", tor.Name)
@@ -81,7 +82,7 @@ func (r *Reconciler) reconcileServiceMonitor(ctx context.Context, tor *torv1alph
 		return nil
 	}
 
-	if !tor.Spec.ServiceMonitor {
+	if !tor.Monitoring().ServiceMonitorEnabled() {
 		// ServiceMonitor is not requested but exists, deleting
 		err = r.Delete(ctx, &service)
 		if err != nil {
@@ -109,7 +110,7 @@ func torServiceMonitor(onion *torv1alpha2.Tor) *monitoringv1.ServiceMonitor {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      onion.ServiceMetricsName(),
 			Namespace: onion.Namespace,
-			Labels:    onion.ServiceMetricsSelector(),
+			Labels:    monitorLabels(onion.ServiceMetricsSelector(), onion.Monitoring()),
 			OwnerReferences: []metav1.OwnerReference{
 				*metav1.NewControllerRef(onion, schema.GroupVersionKind{
 					Group:   torv1alpha2.GroupVersion.Group,
@@ -122,12 +123,7 @@ func torServiceMonitor(onion *torv1alpha2.Tor) *monitoringv1.ServiceMonitor {
 			Selector: metav1.LabelSelector{
 				MatchLabels: onion.ServiceMetricsSelector(),
 			},
-			Endpoints: []monitoringv1.Endpoint{
-				{
-					Port: "metrics",
-					Path: "/metrics",
-				},
-			},
+			Endpoints: serviceMonitorEndpoints(onion.Monitoring(), "metrics"),
 			NamespaceSelector: monitoringv1.NamespaceSelector{
 				MatchNames: []string{
 					onion.Namespace,
@@ -62,6 +62,7 @@ func (r *Reconciler) reconcileServiceAccount(ctx context.Context, tor *torv1alph
 	}
 
 	if !metav1.IsControlledBy(&serviceAccount.ObjectMeta, tor) {
+		recordNotControlled("ServiceAccount", &serviceAccount, tor)
 		logger.Info("ServiceAccount already exists and is not controlled by",
 			"serviceAccount", serviceAccount.Name,
 			"controller", tor.Name)
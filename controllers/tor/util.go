@@ -48,9 +48,18 @@ const (
 	torConfigVolume          = "tor-config"
 	torDataVolume            = "tor-data"
 	torServiceVolume         = "tor-service"
+	torControlVolume         = "tor-control"
 	torConfigExtraVolume     = "tor-config-extra"
 	obConfigVolume           = "ob-config"
 	onionBalanceConfigVolume = "onionbalance-config"
+	torRunVolume             = "tor-run"
+	tmpVolume                = "tmp"
+	unixSocketsVolume        = "onion-sockets"
+
+	torConfigHashAnnotation = "tor.k8s.torproject.org/config-hash"
+
+	// S2K_RFC2440_SPECIFIER_LEN-1
+	controlSaltLength = 8
 )
 
 type OnionV3 struct {
@@ -143,21 +152,18 @@ func GenerateOnionV3FromKeys(publicKey ed25519.PublicKey, privateKey ed25519.Pri
 // Source: https://gitlab.torproject.org/tpo/core/tor/-/blob/main/src/lib/defs/digest_sizes.h#L20
 // #define DIGEST_LEN 20
 
-func doHashPassword(input string) (string, error) {
+func doHashPassword(input string, salt []byte) (string, error) {
 	const (
-		OutputLen    = 256
-		SpecifierLen = 9
-		DigestLen    = 20
-		Iterations   = 96
+		OutputLen  = 256
+		DigestLen  = 20
+		Iterations = 96
 	)
 
-	// 1) Generate S2K_RFC2440_SPECIFIER_LEN-1 random bytes
+	// 1) Use the S2K_RFC2440_SPECIFIER_LEN-1 bytes of salt. It is stored
+	//    alongside the password so the resulting hash is stable across renders
 	// 2) Set last key byte to 96
-	salt := make([]byte, SpecifierLen-1)
-
-	_, err := rand.Read(salt)
-	if err != nil {
-		return "", errors.Wrap(err, "failed to generate random salt")
+	if len(salt) != controlSaltLength {
+		return "", errors.Newf("salt must be %d bytes long", controlSaltLength)
 	}
 
 	// Inspired by: https://stackoverflow.com/questions/48054399/get-the-hashed-tor-password-automated-in-python
@@ -168,8 +174,7 @@ func doHashPassword(input string) (string, error) {
 	d := sha1.New()
 
 	inb := []byte(input)
-	//nolint:gocritic // append result not assigned to the same slace by design
-	tmp := append(salt[:SpecifierLen-1], inb...)
+	tmp := append(append([]byte{}, salt...), inb...)
 	slen := len(tmp)
 
 	for count > 0 {
@@ -189,3 +194,15 @@ func doHashPassword(input string) (string, error) {
 		strings.ToUpper((hex.EncodeToString(d.Sum(nil)))),
 	), nil
 }
+
+// generateSalt returns a random salt for doHashPassword, hex encoded.
+func generateSalt() (string, error) {
+	salt := make([]byte, controlSaltLength)
+
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to generate random salt")
+	}
+
+	return hex.EncodeToString(salt), nil
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tor
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestDoHashPassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		salt     string
+		want     string
+		wantErr  bool
+	}{
+		{
+			// Output of `tor --hash-password testpass`
+			name:     "tor hash-password output",
+			password: "testpass",
+			salt:     "5417AE717521511A",
+			want:     "16:5417AE717521511A609921392778FFA8518EC089BF2162A199241AEB4A",
+		},
+		{
+			name:     "short salt",
+			password: "testpass",
+			salt:     "5417AE71",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			salt, err := hex.DecodeString(tt.salt)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := doHashPassword(tt.password, salt)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("doHashPassword() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("doHashPassword() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
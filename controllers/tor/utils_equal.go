@@ -22,6 +22,7 @@ import (
 	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 )
 
@@ -78,6 +79,34 @@ func monitorServiceEqual(sm1, sm2 *monitoringv1.ServiceMonitor) bool {
 	return true
 }
 
+func networkPolicyEqual(np1, np2 *networkingv1.NetworkPolicy) bool {
+	// Compare metadata
+	if !reflect.DeepEqual(np1.ObjectMeta, np2.ObjectMeta) {
+		return false
+	}
+
+	// Compare spec
+	if !reflect.DeepEqual(np1.Spec, np2.Spec) {
+		return false
+	}
+
+	return true
+}
+
+func podMonitorEqual(pm1, pm2 *monitoringv1.PodMonitor) bool {
+	// Compare metadata
+	if !reflect.DeepEqual(pm1.ObjectMeta, pm2.ObjectMeta) {
+		return false
+	}
+
+	// Compare spec
+	if !reflect.DeepEqual(pm1.Spec, pm2.Spec) {
+		return false
+	}
+
+	return true
+}
+
 func serviceAccountEqual(sa1, sa2 *corev1.ServiceAccount) bool {
 	// Compare metadata
 	if !reflect.DeepEqual(sa1.ObjectMeta, sa2.ObjectMeta) {
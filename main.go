@@ -17,20 +17,32 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"os"
+	"sort"
+	"strings"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	"github.com/cockroachdb/errors"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
 
 	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
@@ -56,6 +68,7 @@ func init() {
 	utilruntime.Must(configv2.AddToScheme(scheme))
 	utilruntime.Must(monitoringv1.AddToScheme(scheme))
 	utilruntime.Must(apiextensionsv1.AddToScheme(scheme))
+	utilruntime.Must(gatewayv1alpha2.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
@@ -63,6 +76,7 @@ func main() {
 	var (
 		configFile            string
 		disableLeaderElection bool
+		shard                 int
 		err                   error
 	)
 
@@ -72,6 +86,9 @@ func main() {
 			"Command-line flags override configuration from this file.")
 	flag.BoolVar(&disableLeaderElection, "no-leader-elect", false,
 		"Disable leader election for controller manager. ")
+	flag.IntVar(&shard, "shard", -1,
+		"Shard reconciled by this replica when sharding is enabled. "+
+			"Overrides the shard set in the configuration file.")
 
 	opts := zap.Options{
 		Development: true,
@@ -98,13 +115,55 @@ func main() {
 		setupLog.Info("Overriding LeaderElection (no-leader-elect)")
 	}
 
-	// Setup namespace if running in namespaced mode
-	if ctrlConfig.Namespace != "" {
-		setupLog.Info("Namespaced mode. Namespace=" + ctrlConfig.Namespace)
-		options.Namespace = ctrlConfig.Namespace
+	// Each shard elects its own leader
+	if sharding := ctrlConfig.Sharding; sharding != nil {
+		if shard >= 0 {
+			sharding.Shard = shard
+		}
+
+		if sharding.Shard < 0 || sharding.Shard >= sharding.Shards {
+			setupLog.Error(errors.Newf("shard %d out of %d shards", sharding.Shard, sharding.Shards),
+				"invalid sharding configuration")
+			os.Exit(1)
+		}
+
+		if sharding.Key == "" {
+			sharding.Key = configv2.ShardKeyNamespace
+		}
+
+		options.LeaderElectionID = fmt.Sprintf("%s-shard-%d", options.LeaderElectionID, sharding.Shard)
+
+		setupLog.Info(fmt.Sprintf("Sharded mode. Shard=%d/%d Key=%s", sharding.Shard, sharding.Shards, sharding.Key))
 	}
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), options)
+	// Endpoints are only read to resolve the named ports of headless Services,
+	// caching all of them isn't worth it
+	options.ClientDisableCacheFor = append(options.ClientDisableCacheFor, &corev1.Endpoints{})
+
+	// The classes are cluster-scoped and only read to tell whether an Ingress
+	// or a Gateway is served, the namespaced caches can't hold them
+	options.ClientDisableCacheFor = append(options.ClientDisableCacheFor,
+		&networkingv1.IngressClass{}, &gatewayv1alpha2.GatewayClass{})
+
+	restConfig := ctrl.GetConfigOrDie()
+
+	namespaces, err := watchedNamespaces(restConfig, &ctrlConfig)
+	if err != nil {
+		setupLog.Error(err, "unable to resolve the watched namespaces")
+		os.Exit(1)
+	}
+
+	// Setup namespaces if running in namespaced mode
+	switch {
+	case len(namespaces) == 1:
+		setupLog.Info("Namespaced mode. Namespace=" + namespaces[0])
+		options.Namespace = namespaces[0]
+	case len(namespaces) > 1:
+		setupLog.Info("Multi-namespace mode. Namespaces=" + strings.Join(namespaces, ","))
+		options.NewCache = cache.MultiNamespacedCacheBuilder(namespaces)
+	}
+
+	mgr, err := ctrl.NewManager(restConfig, options)
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
@@ -136,8 +195,49 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "Tor")
 		os.Exit(1)
 	}
+
+	if err = (&torcontrollers.OnionEndpointReconciler{
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		ProjectConfig: ctrlConfig,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "OnionEndpoint")
+		os.Exit(1)
+	}
+
+	if err = (&torcontrollers.IngressReconciler{
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		Recorder:      mgr.GetEventRecorderFor("tor-controller"),
+		ProjectConfig: ctrlConfig,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Ingress")
+		os.Exit(1)
+	}
+
+	if err = (&torcontrollers.GatewayReconciler{
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		ProjectConfig: ctrlConfig,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Gateway")
+		os.Exit(1)
+	}
+
+	if err = (&torcontrollers.OnionLocationReconciler{
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		Recorder:      mgr.GetEventRecorderFor("tor-controller"),
+		ProjectConfig: ctrlConfig,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "OnionLocation")
+		os.Exit(1)
+	}
 	//+kubebuilder:scaffold:builder
 
+	// Operator metrics, served along with the controller-runtime ones
+	torcontrollers.RegisterMetrics(mgr.GetClient(), ctrlConfig.Sharding)
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
@@ -155,3 +255,56 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// watchedNamespaces returns the namespaces set in the config plus the ones
+// matching its namespace selector, or none when watching the whole cluster.
+// Namespaces matching the selector after startup are not picked up until
+// the controller restarts.
+func watchedNamespaces(restConfig *rest.Config, ctrlConfig *configv2.ProjectConfig) ([]string, error) {
+	seen := map[string]bool{}
+
+	if ctrlConfig.Namespace != "" {
+		seen[ctrlConfig.Namespace] = true
+	}
+
+	for _, namespace := range ctrlConfig.Namespaces {
+		seen[namespace] = true
+	}
+
+	if ctrlConfig.NamespaceSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(ctrlConfig.NamespaceSelector)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid namespaceSelector")
+		}
+
+		// The manager cache is not started yet, use a direct client
+		directClient, err := client.New(restConfig, client.Options{Scheme: scheme})
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to create client")
+		}
+
+		var namespaceList corev1.NamespaceList
+
+		err = directClient.List(context.Background(), &namespaceList, client.MatchingLabelsSelector{Selector: selector})
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to list namespaces")
+		}
+
+		if len(namespaceList.Items) == 0 && len(seen) == 0 {
+			return nil, errors.Newf("no namespace matches the namespaceSelector %q", selector.String())
+		}
+
+		for i := range namespaceList.Items {
+			seen[namespaceList.Items[i].Name] = true
+		}
+	}
+
+	namespaces := make([]string, 0, len(seen))
+	for namespace := range seen {
+		namespaces = append(namespaces, namespace)
+	}
+
+	sort.Strings(namespaces)
+
+	return namespaces, nil
+}